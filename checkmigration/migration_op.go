@@ -20,6 +20,15 @@ const (
 	raiseStatement = "SELECT TODO / PANIC / RAISE / THROW;"
 )
 
+// ContainsRaiseStatement reports whether script still contains the placeholder of unimplemented reverse migrations
+// Scripts with the placeholder need manual work before they can be executed
+//
+// ContainsRaiseStatement 判断脚本是否仍包含未实现反向迁移的占位符
+// 含有占位符的脚本在执行前需要手动完善
+func ContainsRaiseStatement(script string) bool {
+	return strings.Contains(script, raiseStatement)
+}
+
 // MigrationKind represents the type and characteristics of a database migration operation
 // Contains substring patterns for identifying operation type and corresponding reverse operation
 // Used for automated reverse script generation and operation categorization
@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/go-xlan/go-migrate/checkmigration"
 	"github.com/stretchr/testify/require"
 )
 
@@ -88,3 +89,8 @@ func extractIndexAndTableFromCreateIndex(sql string) (index string, table string
 	}
 	return "", ""
 }
+
+func TestContainsRaiseStatement(t *testing.T) {
+	require.True(t, checkmigration.ContainsRaiseStatement("SELECT TODO / PANIC / RAISE / THROW; -- DROP INDEX; -- TODO"))
+	require.False(t, checkmigration.ContainsRaiseStatement("DROP INDEX `idx_users_rank`;"))
+}
@@ -0,0 +1,137 @@
+package checkmigration
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SplitStatements splits script into single statements on semicolons outside quotes
+// Removes line and block comments and blank statements, result statements have no trailing semicolon
+// Dollar-quoted bodies of PostgreSQL, e.g., $$ ... $$ and $fn$ ... $fn$, are kept whole
+//
+// SplitStatements 按引号外的分号将脚本拆分为单条语句
+// 移除行注释、块注释和空语句，结果语句不带结尾分号
+// PostgreSQL 的美元引号正文（例如 $$ ... $$ 和 $fn$ ... $fn$）保持完整
+func SplitStatements(script string) []string {
+	var results []string
+	var sb strings.Builder
+	var quote rune
+	var inComment bool
+	var inBlockComment bool
+	runes := []rune(script)
+	for idx := 0; idx < len(runes); idx++ {
+		ch := runes[idx]
+		switch {
+		case inComment:
+			if ch == '\n' {
+				inComment = false
+				sb.WriteRune(ch)
+			}
+		case inBlockComment:
+			if ch == '*' && idx+1 < len(runes) && runes[idx+1] == '/' {
+				inBlockComment = false
+				idx++
+				sb.WriteRune(' ')
+			}
+		case quote != 0:
+			sb.WriteRune(ch)
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+			sb.WriteRune(ch)
+		case ch == '-' && idx+1 < len(runes) && runes[idx+1] == '-':
+			inComment = true
+		case ch == '/' && idx+1 < len(runes) && runes[idx+1] == '*':
+			inBlockComment = true
+			idx++
+		case ch == '$':
+			tag := dollarQuoteRegexp.FindString(string(runes[idx:]))
+			if tag == "" {
+				sb.WriteRune(ch) // Positional parameter such as $1 // 位置参数，例如 $1
+				continue
+			}
+			// Keep everything up to the closing tag, or the rest when it is not closed
+			// 保留到结束标记为止的全部内容，未闭合时保留剩余全部内容
+			body, _, found := strings.Cut(string(runes[idx+len(tag):]), tag)
+			quoted := tag + body
+			if found {
+				quoted += tag
+			}
+			sb.WriteString(quoted)
+			idx += len([]rune(quoted)) - 1
+		case ch == ';':
+			if statement := strings.TrimSpace(sb.String()); statement != "" {
+				results = append(results, statement)
+			}
+			sb.Reset()
+		default:
+			sb.WriteRune(ch)
+		}
+	}
+	if statement := strings.TrimSpace(sb.String()); statement != "" {
+		results = append(results, statement)
+	}
+	return results
+}
+
+// dollarQuoteRegexp matches opening tag of PostgreSQL dollar quoting, e.g., $$ and $fn$
+//
+// dollarQuoteRegexp 匹配 PostgreSQL 美元引号的起始标记，例如 $$ 和 $fn$
+var dollarQuoteRegexp = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// ClassifyStatement matches statement against known migration kinds ignoring letter case
+// Uses the same kinds as NewMigrationOp so scripts and generated operations share one classification
+//
+// ClassifyStatement 忽略大小写将语句与已知迁移类型匹配
+// 使用与 NewMigrationOp 相同的类型，使脚本和生成的操作共享同一分类
+func ClassifyStatement(statement string) (*MigrationOp, bool) {
+	migrationOp, match := NewMigrationOp(NormalizeStatement(statement))
+	if !match {
+		return nil, false
+	}
+	migrationOp.ForwardSQL = statement // Keep original statement text // 保留原始语句文本
+	return migrationOp, true
+}
+
+// NormalizeStatement upper-cases statement and collapses whitespace so pattern matching works on hand-written SQL
+//
+// NormalizeStatement 将语句转为大写并合并空白，使模式匹配适用于手写 SQL
+func NormalizeStatement(statement string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(statement), " "))
+}
+
+var statementTargetRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)`),
+	regexp.MustCompile(`(?i)^DROP\s+INDEX\s+(?:IF\s+EXISTS\s+)?([^\s;]+)`),
+	regexp.MustCompile(`(?i)^(?:CREATE|DROP|ALTER)\s+TABLE\s+(?:IF\s+(?:NOT\s+)?EXISTS\s+)?([^\s(]+)`),
+	regexp.MustCompile(`(?i)^UPDATE\s+([^\s]+)`),
+	regexp.MustCompile(`(?i)^DELETE\s+FROM\s+([^\s]+)`),
+	regexp.MustCompile(`(?i)^INSERT\s+INTO\s+([^\s(]+)`),
+}
+
+// StatementTarget extracts the object a statement works on without quotes
+// Returns index name on index statements and table name on table and data statements
+//
+// StatementTarget 提取语句操作的对象名（去除引号）
+// 索引语句返回索引名，表语句和数据语句返回表名
+func StatementTarget(statement string) string {
+	statement = strings.TrimSpace(statement)
+	for _, re := range statementTargetRegexps {
+		if matches := re.FindStringSubmatch(statement); len(matches) == 2 {
+			return unquoteName(matches[1])
+		}
+	}
+	return ""
+}
+
+// unquoteName removes quotes around identifiers and keeps the last part of schema-qualified names
+//
+// unquoteName 去除标识符两侧的引号，并保留带模式前缀名称的最后一段
+func unquoteName(name string) string {
+	if pos := strings.LastIndex(name, "."); pos >= 0 {
+		name = name[pos+1:]
+	}
+	return strings.Trim(name, "`\"[]")
+}
@@ -0,0 +1,93 @@
+package checkmigration
+
+import (
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// StatementEffect tells whether the effect of a statement can be seen in the database
+//
+// StatementEffect 表示语句的效果是否能在数据库中观察到
+type StatementEffect string
+
+const (
+	EffectApplied StatementEffect = "APPLIED" // Effect exists in database // 效果已存在于数据库中
+	EffectMissing StatementEffect = "MISSING" // Effect does not exist in database // 效果不存在于数据库中
+	EffectUnknown StatementEffect = "UNKNOWN" // Statement kind cannot be checked // 无法检查该类语句
+)
+
+var (
+	addColumnRegexp   = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+\S+\s+ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([^\s,;]+)`)
+	dropColumnRegexp  = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+\S+\s+DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?([^\s,;]+)`)
+	addIndexRegexp    = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+\S+\s+ADD\s+(?:UNIQUE\s+)?(?:INDEX|KEY)\s+([^\s(]+)`)
+	dropIndexRegexp   = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+\S+\s+DROP\s+(?:INDEX|KEY)\s+([^\s,;]+)`)
+	indexTableRegexp  = regexp.MustCompile(`(?i)\s+ON\s+([^\s(]+)`)
+	createTableRegexp = regexp.MustCompile(`(?i)^CREATE\s+TABLE\s+`)
+	dropTableRegexp   = regexp.MustCompile(`(?i)^DROP\s+TABLE\s+`)
+	createIndexRegexp = regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+`)
+	dropIndexOnRegexp = regexp.MustCompile(`(?i)^DROP\s+INDEX\s+`)
+)
+
+// CheckStatementEffect checks whether the effect of a schema statement exists in the database
+// Looks at tables, columns and indexes through GORM Migrator, data statements are reported as unknown
+//
+// CheckStatementEffect 检查结构语句的效果是否存在于数据库中
+// 通过 GORM Migrator 检查表、列和索引，数据语句报告为未知
+func CheckStatementEffect(db *gorm.DB, statement string) StatementEffect {
+	statement = strings.TrimSpace(statement)
+	target := StatementTarget(statement)
+	if target == "" {
+		return EffectUnknown
+	}
+	migrator := db.Migrator()
+	switch {
+	case createTableRegexp.MatchString(statement):
+		return effectOf(migrator.HasTable(target))
+	case dropTableRegexp.MatchString(statement):
+		return effectOf(!migrator.HasTable(target))
+	case createIndexRegexp.MatchString(statement):
+		if matches := indexTableRegexp.FindStringSubmatch(statement); len(matches) == 2 {
+			return effectOf(migrator.HasIndex(unquoteName(matches[1]), target))
+		}
+	case dropIndexOnRegexp.MatchString(statement):
+		if matches := indexTableRegexp.FindStringSubmatch(statement); len(matches) == 2 {
+			return effectOf(!migrator.HasIndex(unquoteName(matches[1]), target))
+		}
+	case addIndexRegexp.MatchString(statement):
+		return effectOf(migrator.HasIndex(target, unquoteName(addIndexRegexp.FindStringSubmatch(statement)[1])))
+	case dropIndexRegexp.MatchString(statement):
+		return effectOf(!migrator.HasIndex(target, unquoteName(dropIndexRegexp.FindStringSubmatch(statement)[1])))
+	case addColumnRegexp.MatchString(statement):
+		if column := unquoteName(addColumnRegexp.FindStringSubmatch(statement)[1]); !isConstraintKeyword(column) {
+			return effectOf(migrator.HasColumn(target, column))
+		}
+	case dropColumnRegexp.MatchString(statement):
+		if column := unquoteName(dropColumnRegexp.FindStringSubmatch(statement)[1]); !isConstraintKeyword(column) {
+			return effectOf(!migrator.HasColumn(target, column))
+		}
+	}
+	return EffectUnknown
+}
+
+// effectOf converts existence check into statement effect
+//
+// effectOf 将存在性检查结果转换为语句效果
+func effectOf(exists bool) StatementEffect {
+	if exists {
+		return EffectApplied
+	}
+	return EffectMissing
+}
+
+// isConstraintKeyword reports whether word after ADD or DROP starts a constraint clause rather than a column name
+//
+// isConstraintKeyword 判断 ADD 或 DROP 之后的单词是否为约束子句而非列名
+func isConstraintKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "CONSTRAINT", "PRIMARY", "FOREIGN", "UNIQUE", "CHECK", "INDEX", "KEY":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package checkmigration_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStatementEffect(t *testing.T) {
+	require.NoError(t, caseDB.Exec("CREATE TABLE `effect_users` (`id` integer, `name` text)").Error)
+	require.NoError(t, caseDB.Exec("CREATE INDEX `idx_effect_users_name` ON `effect_users`(`name`)").Error)
+	defer func() {
+		require.NoError(t, caseDB.Exec("DROP TABLE `effect_users`").Error)
+	}()
+
+	for statement, effect := range map[string]checkmigration.StatementEffect{
+		"CREATE TABLE `effect_users` (`id` integer)":                        checkmigration.EffectApplied,
+		"CREATE TABLE `effect_orders` (`id` integer)":                       checkmigration.EffectMissing,
+		"DROP TABLE `effect_orders`":                                        checkmigration.EffectApplied,
+		"ALTER TABLE `effect_users` ADD COLUMN `name` text":                 checkmigration.EffectApplied,
+		"ALTER TABLE `effect_users` ADD `age` integer":                      checkmigration.EffectMissing,
+		"ALTER TABLE `effect_users` DROP COLUMN `age`":                      checkmigration.EffectApplied,
+		"CREATE INDEX `idx_effect_users_name` ON `effect_users`(`name`)":    checkmigration.EffectApplied,
+		"CREATE INDEX `idx_effect_users_id` ON `effect_users`(`id`)":        checkmigration.EffectMissing,
+		"ALTER TABLE `effect_users` ADD CONSTRAINT `pk` PRIMARY KEY (`id`)": checkmigration.EffectUnknown,
+		"UPDATE `effect_users` SET `name` = 'a'":                            checkmigration.EffectUnknown,
+	} {
+		require.Equal(t, effect, checkmigration.CheckStatementEffect(caseDB, statement), statement)
+	}
+}
@@ -0,0 +1,49 @@
+package checkmigration_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- header comment\nCREATE TABLE `users` (`id` integer, `memo` text DEFAULT 'a;b');\n\ncreate index idx_users_memo on users(memo); -- tail\n"
+	statements := checkmigration.SplitStatements(script)
+	require.Equal(t, []string{
+		"CREATE TABLE `users` (`id` integer, `memo` text DEFAULT 'a;b')",
+		"create index idx_users_memo on users(memo)",
+	}, statements)
+}
+
+func TestSplitStatements_DollarQuoteAndBlockComment(t *testing.T) {
+	script := "/* header; comment */\nCREATE FUNCTION one() RETURNS integer AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\n" +
+		"CREATE FUNCTION two() RETURNS integer AS $fn$ SELECT $1; $fn$ LANGUAGE sql;\nSELECT 1 /* a; b */ + 2;\n"
+	statements := checkmigration.SplitStatements(script)
+	require.Equal(t, []string{
+		"CREATE FUNCTION one() RETURNS integer AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql",
+		"CREATE FUNCTION two() RETURNS integer AS $fn$ SELECT $1; $fn$ LANGUAGE sql",
+		"SELECT 1   + 2",
+	}, statements)
+}
+
+func TestClassifyStatement(t *testing.T) {
+	op, ok := checkmigration.ClassifyStatement("create  unique index idx_users_code on users(code)")
+	require.True(t, ok)
+	require.Equal(t, "CREATE UNIQUE INDEX", op.Kind.ForwardSubstr)
+	require.Equal(t, "DROP INDEX", op.Kind.ReverseSubstr)
+	require.Equal(t, "create  unique index idx_users_code on users(code)", op.ForwardSQL)
+
+	_, ok = checkmigration.ClassifyStatement("UPDATE users SET name = 'a'")
+	require.False(t, ok)
+}
+
+func TestStatementTarget(t *testing.T) {
+	require.Equal(t, "users", checkmigration.StatementTarget("CREATE TABLE `users` (`id` integer)"))
+	require.Equal(t, "users", checkmigration.StatementTarget("ALTER TABLE \"public\".\"users\" ADD \"age\" bigint"))
+	require.Equal(t, "users", checkmigration.StatementTarget("drop table if exists users"))
+	require.Equal(t, "idx_users_rank", checkmigration.StatementTarget("CREATE INDEX `idx_users_rank` ON `users`(`rank`)"))
+	require.Equal(t, "idx_users_rank", checkmigration.StatementTarget("DROP INDEX `idx_users_rank` ON `users`"))
+	require.Equal(t, "users", checkmigration.StatementTarget("DELETE FROM users"))
+	require.Equal(t, "users", checkmigration.StatementTarget("update users set age = 1"))
+}
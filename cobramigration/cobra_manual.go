@@ -0,0 +1,241 @@
+package cobramigration
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/internal/utils"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+)
+
+// askOne asks prompt through survey, so commands fail with typed errors instead of panicking
+// Returns ErrCancelled on interrupt and ErrUsage pointing at skipFlag when the prompt can not run, such as without a TTY
+//
+// askOne 通过 survey 发出提示，使命令以类型化错误失败而不是 panic
+// 中断时返回 ErrCancelled，提示无法运行（例如没有 TTY）时返回指向 skipFlag 的 ErrUsage
+func askOne(prompt survey.Prompt, response any, skipFlag string) error {
+	if err := survey.AskOne(prompt, response); err != nil {
+		if errors.Is(err, terminal.InterruptErr) {
+			return erero.Wro(migrationerrors.ErrCancelled)
+		}
+		return erero.Wrapf(migrationerrors.ErrUsage, "prompt failed (%v), pass %s to run without prompt", err, skipFlag)
+	}
+	return nil
+}
+
+// confirmTyped asks operator to type the expected text before running destructive operation
+// Skips the prompt when yes is set, used in automation, returns ErrCancelled when input does not match
+//
+// confirmTyped 在执行破坏性操作前要求操作者输入预期文本
+// 设置 yes 时跳过提示，用于自动化场景，输入不匹配时返回 ErrCancelled
+func confirmTyped(writer io.Writer, action string, expected string, yes bool) error {
+	if yes {
+		return nil
+	}
+	var answer string
+	prompt := &survey.Input{
+		Message: fmt.Sprintf("%s. Type %q to confirm:", action, expected),
+	}
+	if err := askOne(prompt, &answer, "--yes"); err != nil {
+		return err
+	}
+	if strings.TrimSpace(answer) != expected {
+		outputs.ShowMessage(writer, eroticgo.AMBER, "CANCELLED. Input does not match", expected)
+		return erero.Wro(migrationerrors.ErrCancelled)
+	}
+	return nil
+}
+
+// currentVersion reads database version, returning -1 when no scripts applied
+//
+// currentVersion 读取数据库版本，未应用任何脚本时返回 -1
+func currentVersion(migration *migrate.Migrate) (int, bool, error) {
+	version, dirtyFlag, err := migration.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return -1, false, nil
+	}
+	if err != nil {
+		return 0, false, erero.Wro(err)
+	}
+	return int(version), dirtyFlag, nil
+}
+
+// newGotoCmd creates command migrating up or down to the given version
+// Asks typed confirmation when moving down since rollback scripts drop data
+//
+// newGotoCmd 创建向上或向下迁移到指定版本的命令
+// 向下迁移会执行回滚脚本并丢失数据，因此需要输入确认
+func newGotoCmd(cfg *Config) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:          "goto <version>",
+		Short:        "Migrate up or down to the given version",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				targetVersion, err := strconv.ParseUint(args[0], 10, 64)
+				if err != nil {
+					return nil, erero.Wrapf(migrationerrors.ErrUsage, "version %q is invalid", args[0])
+				}
+
+				migration, cleanup := cfg.Param.GetMigration()
+				defer cleanup()
+				if err := verifyManifest(cfg, migration); err != nil {
+					return nil, err
+				}
+
+				version, _, err := currentVersion(migration)
+				if err != nil {
+					return nil, err
+				}
+				if int(targetVersion) < version {
+					if err := confirmTyped(writer, fmt.Sprintf("Rollback from version %d to %d", version, targetVersion), args[0], yes); err != nil {
+						return nil, err
+					}
+				}
+				if err := utils.WhistleCauseE(migrateTo(cfg.withWriter(writer), migration, uint(targetVersion))); err != nil {
+					return nil, err
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS. Now at version", targetVersion)
+				return readVersionInfo(migration)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "skip confirmation prompt")
+	return cmd
+}
+
+// newForceCmd creates command setting database version without running scripts, clearing dirty flag
+// Use -1 to mark the database as having no version, a forced record is saved into history table when configured
+//
+// newForceCmd 创建不运行脚本直接设置数据库版本并清除脏标志的命令
+// 使用 -1 将数据库标记为无版本，配置了历史表时会保存一条强制记录
+func newForceCmd(cfg *Config) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:          "force <version>",
+		Short:        "Set version and clear dirty flag without running scripts",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				targetVersion, err := strconv.Atoi(args[0])
+				if err != nil || targetVersion < -1 {
+					return nil, erero.Wrapf(migrationerrors.ErrUsage, "version %q is invalid, use -1 to clear version", args[0])
+				}
+
+				migration, cleanup := cfg.Param.GetMigration()
+				defer cleanup()
+
+				version, dirtyFlag, err := currentVersion(migration)
+				if err != nil {
+					return nil, err
+				}
+				action := fmt.Sprintf("Force version from %d (dirty=%t) to %d", version, dirtyFlag, targetVersion)
+				if err := confirmTyped(writer, action, args[0], yes); err != nil {
+					return nil, err
+				}
+				if err := migration.Force(targetVersion); err != nil {
+					return nil, erero.Wro(err)
+				}
+				if cfg.HistoryTable != "" && targetVersion >= 0 {
+					if err := saveForcedRecord(cfg, uint(targetVersion), "forced by force command"); err != nil {
+						return nil, err
+					}
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS. Forced version", targetVersion)
+				return readVersionInfo(migration)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "skip confirmation prompt")
+	return cmd
+}
+
+// newDownCmd creates command rolling back all applied migrations
+// Requires --all flag so that a bare `down` does not wipe the schema by accident
+//
+// newDownCmd 创建回滚所有已应用迁移的命令
+// 需要 --all 参数，避免单独的 `down` 意外清空数据库结构
+func newDownCmd(cfg *Config) *cobra.Command {
+	var all bool
+	var yes bool
+	cmd := &cobra.Command{
+		Use:          "down",
+		Short:        "Rollback all migrations (requires --all)",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				if !all {
+					outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Use `down --all` to rollback all migrations, or `dec` to rollback one step.")
+					return nil, erero.Wrapf(migrationerrors.ErrUsage, "down needs --all")
+				}
+				migration, cleanup := cfg.Param.GetMigration()
+				defer cleanup()
+				if err := verifyManifest(cfg, migration); err != nil {
+					return nil, err
+				}
+
+				version, _, err := currentVersion(migration)
+				if err != nil {
+					return nil, err
+				}
+				if err := confirmTyped(writer, fmt.Sprintf("Rollback all migrations from version %d", version), "down", yes); err != nil {
+					return nil, err
+				}
+				if err := utils.WhistleCauseE(migrateDown(cfg.withWriter(writer), migration)); err != nil {
+					return nil, err
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS. All migrations rolled back")
+				return readVersionInfo(migration)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "rollback all applied migrations")
+	cmd.Flags().BoolVar(&yes, "yes", false, "skip confirmation prompt")
+	return cmd
+}
+
+// newDropCmd creates command dropping everything inside the database
+// Asks operator to type "drop" unless --yes is given
+//
+// newDropCmd 创建删除数据库中所有内容的命令
+// 除非指定 --yes，否则要求操作者输入 "drop"
+func newDropCmd(cfg *Config) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:          "drop",
+		Short:        "Drop everything inside the database",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				migration, cleanup := cfg.Param.GetMigration()
+				defer cleanup()
+
+				if err := confirmTyped(writer, "Drop EVERYTHING inside the database", "drop", yes); err != nil {
+					return nil, err
+				}
+				if err := migration.Drop(); err != nil {
+					return nil, erero.Wro(err)
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS. Database dropped")
+				return readVersionInfo(migration)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "skip confirmation prompt")
+	return cmd
+}
@@ -0,0 +1,157 @@
+package cobramigration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+)
+
+// runMigrateCmd runs migrate command tree with args
+// runMigrateCmd 使用参数运行迁移命令树
+func runMigrateCmd(t *testing.T, cfg *cobramigration.Config, args ...string) {
+	require.NoError(t, runMigrateCmdE(cfg, args...))
+}
+
+// runMigrateCmdE runs migrate command tree with args and returns its error
+// runMigrateCmdE 使用参数运行迁移命令树并返回其错误
+func runMigrateCmdE(cfg *cobramigration.Config, args ...string) error {
+	cmd := cobramigration.NewMigrateCmdWithConfig(cfg)
+	cmd.SetArgs(args)
+	return cmd.Execute()
+}
+
+// readVersion reads version of param, returning -1 when no scripts applied
+// readVersion 读取连接的版本，未应用脚本时返回 -1
+func readVersion(t *testing.T, param *migrationparam.MigrationParam) (int, bool) {
+	migration, cleanup := param.GetMigration()
+	defer cleanup()
+	version, dirtyFlag, err := migration.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return -1, false
+	}
+	require.NoError(t, err)
+	return int(version), dirtyFlag
+}
+
+func TestNewMigrateCmd_GotoForceDown(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+
+	runMigrateCmd(t, cfg, "goto", "2")
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, 2, version)
+
+	runMigrateCmd(t, cfg, "goto", "1", "--yes")
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+
+	runMigrateCmd(t, cfg, "force", "1", "--yes")
+	version, dirtyFlag := readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+	require.False(t, dirtyFlag)
+
+	// Without --all the down command refuses to run
+	// 不带 --all 时 down 命令拒绝执行
+	require.ErrorIs(t, runMigrateCmdE(cfg, "down", "--yes"), migrationerrors.ErrUsage)
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+
+	runMigrateCmd(t, cfg, "down", "--all", "--yes")
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, -1, version)
+}
+
+func TestNewMigrateCmd_Drop(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+
+	runMigrateCmd(t, cfg, "all")
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, 2, version)
+
+	runMigrateCmd(t, cfg, "drop", "--yes")
+	db, cleanup := cfg.Param.GetDB()
+	defer cleanup()
+	require.False(t, db.Migrator().HasTable("users"))
+}
+
+func TestNewMigrateCmd_OutputJSON(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+
+	cmd := cobramigration.NewMigrateCmdWithConfig(cfg)
+	buffer := &bytes.Buffer{}
+	cmd.SetOut(buffer)
+	cmd.SetArgs([]string{"all", "--output", "json"})
+	require.NoError(t, cmd.Execute())
+
+	var info cobramigration.VersionInfo
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &info))
+	require.Equal(t, cobramigration.VersionInfo{Version: 2, HasMigrated: true}, info)
+}
+
+func TestNewMigrateCmd_OutputJSON_ProcessStdout(t *testing.T) {
+	if os.Getenv("GO_MIGRATE_STDOUT_CHILD") != "" {
+		// Runs in the child process, writing into the real stdout that zaplog writes to as well
+		// 在子进程中运行，写入 zaplog 同样写入的真实 stdout
+		scriptsInRoot, shards := newShards(t, "main")
+		cmd := cobramigration.NewMigrateCmdWithConfig(&cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot})
+		cmd.SetArgs([]string{"all", "--output", "json"})
+		os.Exit(migrationerrors.Execute(cmd))
+	}
+
+	// The child exits without cleanup, so its temp DIRs are placed in the temp DIR of this test
+	// 子进程退出时不做清理，因此将其临时 DIR 放在本测试的临时 DIR 中
+	command := exec.Command(os.Args[0], "-test.run=^TestNewMigrateCmd_OutputJSON_ProcessStdout$")
+	command.Env = append(os.Environ(), "GO_MIGRATE_STDOUT_CHILD=1", "TMPDIR="+t.TempDir())
+	stderr := &bytes.Buffer{}
+	command.Stderr = stderr
+	output, err := command.Output()
+	require.NoError(t, err, stderr.String())
+	t.Log(stderr.String())
+
+	var info cobramigration.VersionInfo
+	require.NoError(t, json.Unmarshal(output, &info), string(output))
+	require.Equal(t, cobramigration.VersionInfo{Version: 2, HasMigrated: true}, info)
+	require.Contains(t, stderr.String(), "MIGRATION SUCCESS")
+}
+
+func TestNewMigrateCmd_ExitCode(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "broken")
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+
+	require.Equal(t, migrationerrors.ExitFailure, migrationerrors.ExitCode(runMigrateCmdE(cfg, "all")))
+	// The failed script left the database dirty, so the next run is refused
+	// 失败的脚本使数据库变脏，因此下一次运行被拒绝
+	require.Equal(t, migrationerrors.ExitDirty, migrationerrors.ExitCode(runMigrateCmdE(cfg, "all")))
+	require.Equal(t, migrationerrors.ExitUsage, migrationerrors.ExitCode(runMigrateCmdE(cfg, "inc", "zero")))
+}
+
+func TestNewMigrateCmd_PromptWithoutTerminal(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+	runMigrateCmd(t, cfg, "inc", "2", "--yes")
+
+	// Prompts fail with ErrUsage instead of panicking when stdin is not a terminal
+	// stdin 不是终端时提示以 ErrUsage 失败而不是 panic
+	stdin := os.Stdin
+	os.Stdin = rese.P1(os.Open(os.DevNull))
+	t.Cleanup(func() {
+		require.NoError(t, os.Stdin.Close())
+		os.Stdin = stdin
+	})
+	require.ErrorIs(t, runMigrateCmdE(cfg, "drop"), migrationerrors.ErrUsage)
+	require.ErrorIs(t, runMigrateCmdE(cfg, "dec", "2"), migrationerrors.ErrUsage)
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, 2, version)
+}
@@ -10,12 +10,57 @@
 package cobramigration
 
 import (
+	"io"
+	"os"
+
+	"github.com/go-xlan/go-migrate/internal/outputs"
 	"github.com/go-xlan/go-migrate/internal/utils"
+	"github.com/go-xlan/go-migrate/migrationerrors"
 	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
 	"github.com/yyle88/eroticgo"
+	"github.com/yyle88/zaplog"
 )
 
+// Config contains configuration options the migrate command needs
+// ScriptsInRoot is optional, when set the checksum manifest is verified before running scripts
+// With HistoryTable, Hooks or Observer set, migrations run one step at a time
+//
+// Config 包含迁移命令所需的配置选项
+// ScriptsInRoot 可选，设置后会在运行脚本前校验校验和清单
+// 设置 HistoryTable、Hooks 或 Observer 后，迁移逐步执行
+type Config struct {
+	Param         *migrationparam.MigrationParam // Migration connection // 迁移连接
+	ScriptsInRoot string                         // Path to migration scripts DIR // 迁移脚本 DIR 路径
+	HistoryTable  string                         // History table saving each step, recording is off when empty // 保存每个步骤的历史表，为空时不记录
+	Hooks         *Hooks                         // Functions called around the run and each step, needs ScriptsInRoot // 在运行和每个步骤前后调用的函数，需要 ScriptsInRoot
+	Observer      Observer                       // Receives events of each step, such as ProgressObserver // 接收每个步骤的事件，例如 ProgressObserver
+	writer        io.Writer                      // Writer of step notes set by commands, os.Stderr when nil // 由命令设置的步骤提示 writer，为 nil 时使用 os.Stderr
+}
+
+// withWriter returns copy of config writing step notes into writer, used by commands to pass their output writer
+//
+// withWriter 返回将步骤提示写入 writer 的配置副本，供命令传入其输出 writer
+func (cfg *Config) withWriter(writer io.Writer) *Config {
+	clone := *cfg
+	clone.writer = writer
+	return &clone
+}
+
+// noteWriter returns writer of step notes, such as a hook failing after a failed step
+//
+// noteWriter 返回步骤提示的 writer，例如步骤失败后钩子也失败
+func (cfg *Config) noteWriter() io.Writer {
+	if cfg.writer == nil {
+		return os.Stderr
+	}
+	return cfg.writer
+}
+
 // NewMigrateCmd creates comprehensive migration command with subcommands for all migration operations
 // Uses lazy initialization - connections created only when command runs (not during command tree building)
 // Migration connection interface ensures proper resource cleanup after operations
@@ -24,92 +69,241 @@ import (
 // 使用延迟初始化 - 仅在命令运行时创建连接（而非命令树构建时）
 // 迁移连接接口确保操作后正确清理资源
 func NewMigrateCmd(param *migrationparam.MigrationParam) *cobra.Command {
+	return NewMigrateCmdWithConfig(&Config{Param: param})
+}
+
+// NewMigrateCmdWithConfig creates migration command using config with scripts DIR
+// Verifies checksum manifest of applied scripts before running migrations
+//
+// NewMigrateCmdWithConfig 使用包含脚本 DIR 的配置创建迁移命令
+// 在运行迁移前校验已应用脚本的校验和清单
+func NewMigrateCmdWithConfig(cfg *Config) *cobra.Command {
 	// Create root command
 	var rootCmd = &cobra.Command{
-		Use:   "migrate",
-		Short: "Database migration",
-		Long:  "Database migration",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := param.GetMigration()
-			defer cleanup()
-
-			version, dirtyFlag, err := migration.Version()
-			utils.WhistleCause(err) // panic when cause is not expected
-			if dirtyFlag {
-				eroticgo.RED.ShowMessage(version, "(DIRTY)")
-			} else {
-				eroticgo.GREEN.ShowMessage(version)
-			}
+		Use:          "migrate",
+		Short:        "Database migration",
+		Long:         "Database migration",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				migration, cleanup := cfg.Param.GetMigration()
+				defer cleanup()
+
+				version, dirtyFlag, err := migration.Version()
+				if err := utils.WhistleCauseE(err); err != nil {
+					return nil, err
+				}
+				if dirtyFlag {
+					outputs.ShowMessage(writer, eroticgo.RED, version, "(DIRTY)")
+				} else {
+					outputs.ShowMessage(writer, eroticgo.GREEN, version)
+				}
+				return readVersionInfo(migration)
+			})
 		},
 	}
+	outputs.AddFlag(rootCmd)
 
-	rootCmd.AddCommand(newAllCmd(param)) // Append `all` subcommand // 添加 `all` 子命令
-	rootCmd.AddCommand(newIncCMD(param)) // Append `inc` subcommand // 添加 `inc` 子命令
-	rootCmd.AddCommand(newDecCMD(param)) // Append `dec` subcommand // 添加 `dec` 子命令
+	rootCmd.AddCommand(newAllCmd(cfg))     // Append `all` subcommand // 添加 `all` 子命令
+	rootCmd.AddCommand(newIncCMD(cfg))     // Append `inc` subcommand // 添加 `inc` 子命令
+	rootCmd.AddCommand(newDecCMD(cfg))     // Append `dec` subcommand // 添加 `dec` 子命令
+	rootCmd.AddCommand(newGotoCmd(cfg))    // Append `goto` subcommand // 添加 `goto` 子命令
+	rootCmd.AddCommand(newForceCmd(cfg))   // Append `force` subcommand // 添加 `force` 子命令
+	rootCmd.AddCommand(newDownCmd(cfg))    // Append `down` subcommand // 添加 `down` 子命令
+	rootCmd.AddCommand(newDropCmd(cfg))    // Append `drop` subcommand // 添加 `drop` 子命令
+	rootCmd.AddCommand(newRepairCmd(cfg))  // Append `repair` subcommand // 添加 `repair` 子命令
+	rootCmd.AddCommand(newHistoryCmd(cfg)) // Append `history` subcommand // 添加 `history` 子命令
+	rootCmd.AddCommand(newPlanCmd(cfg))    // Append `plan` subcommand // 添加 `plan` 子命令
 
 	return rootCmd
 }
 
 // newAllCmd creates command for executing all pending migrations
 // Performs complete database upgrade to latest schema version
+// With --out-of-order, older versions missing in history run first
+// With --plan, pending scripts are verified against the plan file, then the content saved in the plan runs
 //
 // newAllCmd 创建用于执行所有待处理迁移的命令
 // 将数据库升级到最新的结构版本
-func newAllCmd(param *migrationparam.MigrationParam) *cobra.Command {
-	return &cobra.Command{
-		Use:   "all",
-		Short: "Run all migration files",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := param.GetMigration()
-			defer cleanup()
-
-			// Perform complete database upgrade
-			// 执行完整的数据库升级
-			utils.WhistleCause(migration.Up())
+// 使用 --out-of-order 时，先执行历史中缺失的较旧版本
+// 使用 --plan 时，根据计划文件校验待处理脚本，然后执行计划中保存的内容
+func newAllCmd(cfg *Config) *cobra.Command {
+	var outOfOrder bool
+	var planFile string
+	cmd := &cobra.Command{
+		Use:          "all",
+		Short:        "Run all migration files",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				if planFile != "" {
+					if outOfOrder {
+						return nil, erero.Wrap(migrationerrors.ErrUsage, "--plan cannot be used with --out-of-order")
+					}
+					if err := migratePlanFile(writer, cfg, planFile); err != nil {
+						return nil, err
+					}
+				} else if outOfOrder {
+					if err := MigrateOutOfOrder(cfg.withWriter(writer)); err != nil {
+						return nil, err
+					}
+				} else {
+					if err := MigrateAll(cfg.withWriter(writer)); err != nil {
+						return nil, err
+					}
+				}
+				return GetVersionInfo(cfg.Param)
+			})
 		},
 	}
+	cmd.Flags().BoolVar(&outOfOrder, "out-of-order", false, "also apply older versions missing in history table")
+	cmd.Flags().StringVar(&planFile, "plan", "", "plan file saved by plan --save, verified and then applied")
+	return cmd
+}
+
+// VersionInfo contains database version and dirty flag, written as JSON object of migrate commands
+//
+// VersionInfo 包含数据库版本和脏标志，作为迁移命令的 JSON 对象输出
+type VersionInfo struct {
+	Version     uint // Database version, 0 when no scripts applied // 数据库版本，未应用脚本时为 0
+	Dirty       bool // Database dirty flag // 数据库脏标志
+	HasMigrated bool // Whether any script has been applied // 是否已应用过脚本
+}
+
+// GetVersionInfo reads version info through a new migration connection
+//
+// GetVersionInfo 通过新的迁移连接读取版本信息
+func GetVersionInfo(param *migrationparam.MigrationParam) (*VersionInfo, error) {
+	migration, cleanup := param.GetMigration()
+	defer cleanup()
+	return readVersionInfo(migration)
 }
 
-// newDecCMD creates command for rolling back one migration step
-// Safely reverts database schema by one version
+// readVersionInfo reads version info of migration
 //
-// newDecCMD 创建用于回滚一个迁移步骤的命令
-// 安全地将数据库结构回退一个版本
-func newDecCMD(param *migrationparam.MigrationParam) *cobra.Command {
-	return &cobra.Command{
-		Use:   "dec",
-		Short: "Rollback one step (-1)",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := param.GetMigration()
-			defer cleanup()
-
-			// Rollback database by one migration step
-			// 将数据库回滚一个迁移步骤
-			utils.WhistleCause(migration.Steps(-1))
+// readVersionInfo 读取迁移的版本信息
+func readVersionInfo(migration *migrate.Migrate) (*VersionInfo, error) {
+	version, dirtyFlag, err := migration.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return &VersionInfo{}, nil
+		}
+		return nil, erero.Wro(err)
+	}
+	return &VersionInfo{Version: version, Dirty: dirtyFlag, HasMigrated: true}, nil
+}
+
+// MigrateAll runs all pending migrations after verifying checksum manifest
+// Shared by multi-module and shard commands so each target migrates the same way
+//
+// MigrateAll 在校验校验和清单后执行所有待处理迁移
+// 供多模块和分片命令共用，使每个目标以相同方式迁移
+func MigrateAll(cfg *Config) error {
+	migration, cleanup := cfg.Param.GetMigration()
+	defer cleanup()
+	if err := verifyManifest(cfg, migration); err != nil {
+		return err
+	}
+
+	// Perform complete database upgrade
+	// 执行完整的数据库升级
+	return utils.WhistleCauseE(migrateUp(cfg, migration))
+}
+
+// MigrateSteps runs n migration steps after verifying checksum manifest, rolling back when n is negative
+// Returns ErrNoScripts without running any step when source does not hold n steps
+// Shared by shard commands so each shard steps the same way
+//
+// MigrateSteps 在校验校验和清单后执行 n 个迁移步骤，n 为负数时回滚
+// 当源中不足 n 个步骤时返回 ErrNoScripts 且不执行任何步骤
+// 供分片命令共用，使每个分片以相同方式步进
+func MigrateSteps(cfg *Config, n int) error {
+	migration, cleanup := cfg.Param.GetMigration()
+	defer cleanup()
+	if err := verifyManifest(cfg, migration); err != nil {
+		return err
+	}
+	if _, err := planMigrationSteps(migration, n); err != nil {
+		return err
+	}
+
+	return utils.WhistleCauseE(migrateSteps(cfg, migration, n))
+}
+
+// newDecCMD creates command for rolling back migration steps, one step when n is not given
+// Lists versions to be reverted and asks confirmation when n > 1
+//
+// newDecCMD 创建用于回滚迁移步骤的命令，未指定 n 时回滚一步
+// 列出将被回滚的版本，n > 1 时请求确认
+func newDecCMD(cfg *Config) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:          "dec [n]",
+		Short:        "Rollback n steps (-n), one step by default",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Rollback database by n migration steps
+			// 将数据库回滚 n 个迁移步骤
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				n, err := parseStepCount(args)
+				if err != nil {
+					return nil, err
+				}
+				return runSteps(writer, cfg, -n, yes)
+			})
 		},
 	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "skip confirmation prompt")
+	return cmd
 }
 
-// newIncCMD creates command for executing next migration step
-// Advances database schema by one version forward
+// newIncCMD creates command for executing migration steps, one step when n is not given
+// Lists versions to be applied and asks confirmation when n > 1
 //
-// newIncCMD 创建用于执行下一个迁移步骤的命令
-// 将数据库结构向前推进一个版本
-func newIncCMD(param *migrationparam.MigrationParam) *cobra.Command {
-	return &cobra.Command{
-		Use:   "inc",
-		Short: "Run next step (+1)",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := param.GetMigration()
-			defer cleanup()
-
-			// Execute next migration step forward
-			// 向前执行下一个迁移步骤
-			utils.WhistleCause(migration.Steps(+1))
+// newIncCMD 创建用于执行迁移步骤的命令，未指定 n 时执行一步
+// 列出将被应用的版本，n > 1 时请求确认
+func newIncCMD(cfg *Config) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:          "inc [n]",
+		Short:        "Run next n steps (+n), one step by default",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Execute next n migration steps forward
+			// 向前执行接下来的 n 个迁移步骤
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				n, err := parseStepCount(args)
+				if err != nil {
+					return nil, err
+				}
+				return runSteps(writer, cfg, +n, yes)
+			})
 		},
 	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "skip confirmation prompt")
+	return cmd
+}
+
+// verifyManifest checks applied scripts against checksum manifest before running migrations
+// Returns ErrScriptModified when an applied script was edited, warns that it is skipped without ScriptsInRoot
+//
+// verifyManifest 在运行迁移前根据校验和清单检查已应用的脚本
+// 当已应用的脚本被修改时返回 ErrScriptModified，未设置 ScriptsInRoot 时警告已跳过检查
+func verifyManifest(cfg *Config, migration *migrate.Migrate) error {
+	if cfg.ScriptsInRoot == "" {
+		zaplog.SUG.Warnln("checksum manifest verification skipped, set ScriptsInRoot to detect edited scripts")
+		return nil
+	}
+	version, _, err := migration.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return nil // No scripts applied yet // 尚未应用任何脚本
+		}
+		return erero.Wro(err)
+	}
+	return newscripts.VerifyManifest(newscripts.NewOptions(cfg.ScriptsInRoot), version)
 }
@@ -0,0 +1,322 @@
+package cobramigration
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+	"gorm.io/gorm"
+)
+
+// Repair actions offered on a dirty database
+//
+// 脏数据库上提供的修复动作
+const (
+	RepairForcePrevious = "previous"  // Force version to the previous script version // 将版本强制设为上一个脚本版本
+	RepairForceForward  = "forward"   // Force version to the dirty version // 将版本强制设为脏版本
+	RepairRunRemaining  = "remaining" // Run statements after the last applied one, then force forward // 执行最后一条已生效语句之后的语句，然后向前强制
+)
+
+// StatementCheck contains one statement of the failed script and whether its effect exists
+//
+// StatementCheck 包含失败脚本中的单条语句及其效果是否存在
+type StatementCheck struct {
+	Statement string                         // Statement text // 语句文本
+	Effect    checkmigration.StatementEffect // Effect seen in database // 数据库中观察到的效果
+}
+
+// RepairPlan describes a dirty version with its scripts and the statements still to run
+//
+// RepairPlan 描述脏版本及其脚本和仍需执行的语句
+type RepairPlan struct {
+	Version         uint              // Dirty version, the failed older version when OutOfOrder // 脏版本，OutOfOrder 时为失败的较旧版本
+	DatabaseVersion uint              // Database version with dirty flag // 带脏标志的数据库版本
+	OutOfOrder      bool              // Whether an out-of-order step of an older version failed // 是否为较旧版本的乱序步骤失败
+	PreviousVersion int               // Previous script version, -1 when none // 上一个脚本版本，无则为 -1
+	UpName          string            // Up script name // 升级脚本名称
+	UpScript        string            // Up script content // 升级脚本内容
+	DownName        string            // Down script name // 回滚脚本名称
+	DownScript      string            // Down script content // 回滚脚本内容
+	Checks          []*StatementCheck // Checks of up script statements // 升级脚本语句的检查结果
+	Remaining       []string          // Statements after the last applied one // 最后一条已生效语句之后的语句
+}
+
+// PlanRepair reads scripts of the dirty version and checks which up statements took effect
+// Statements after the last applied one are treated as remaining
+//
+// PlanRepair 读取脏版本的脚本并检查哪些升级语句已生效
+// 最后一条已生效语句之后的语句视为剩余语句
+func PlanRepair(db *gorm.DB, scriptsInRoot string, version uint) (*RepairPlan, error) {
+	entries, err := os.ReadDir(scriptsInRoot)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	plan := &RepairPlan{Version: version, DatabaseVersion: version, PreviousVersion: -1}
+	for _, item := range entries {
+		if item.IsDir() {
+			continue
+		}
+		migration, err := source.DefaultParse(item.Name())
+		if err != nil {
+			continue // Skip files that don't match migration pattern // 跳过不匹配迁移模式的文件
+		}
+		if migration.Version < version {
+			plan.PreviousVersion = max(plan.PreviousVersion, int(migration.Version))
+			continue
+		}
+		if migration.Version > version {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(scriptsInRoot, item.Name()))
+		if err != nil {
+			return nil, erero.Wro(err)
+		}
+		switch migration.Direction {
+		case source.Up:
+			plan.UpName, plan.UpScript = item.Name(), string(content)
+		case source.Down:
+			plan.DownName, plan.DownScript = item.Name(), string(content)
+		}
+	}
+	if plan.UpName == "" {
+		return nil, erero.Errorf("up script of version %d not found in %s", version, scriptsInRoot)
+	}
+
+	lastApplied := -1
+	for idx, statement := range checkmigration.SplitStatements(plan.UpScript) {
+		effect := checkmigration.CheckStatementEffect(db, statement)
+		if effect == checkmigration.EffectApplied {
+			lastApplied = idx
+		}
+		plan.Checks = append(plan.Checks, &StatementCheck{Statement: statement, Effect: effect})
+	}
+	for _, check := range plan.Checks[lastApplied+1:] {
+		plan.Remaining = append(plan.Remaining, check.Statement)
+	}
+	return plan, nil
+}
+
+// ShowRepairPlan writes scripts of the dirty version and effect of each up statement into writer
+//
+// ShowRepairPlan 将脏版本的脚本以及每条升级语句的效果写入 writer
+func ShowRepairPlan(writer io.Writer, plan *RepairPlan) {
+	if plan.OutOfOrder {
+		outputs.ShowMessage(writer, eroticgo.RED, "DIRTY VERSION:", plan.DatabaseVersion, "OUT-OF-ORDER VERSION FAILED:", plan.Version)
+	} else {
+		outputs.ShowMessage(writer, eroticgo.RED, "DIRTY VERSION:", plan.Version)
+	}
+	outputs.ShowMessage(writer, eroticgo.CYAN, "=== "+plan.UpName+" ===")
+	fmt.Fprintln(writer, plan.UpScript)
+	if plan.DownName != "" {
+		outputs.ShowMessage(writer, eroticgo.CYAN, "=== "+plan.DownName+" ===")
+		fmt.Fprintln(writer, plan.DownScript)
+	}
+	outputs.ShowMessage(writer, eroticgo.CYAN, "=== Statement Effects ===")
+	for _, check := range plan.Checks {
+		switch check.Effect {
+		case checkmigration.EffectApplied:
+			outputs.ShowMessage(writer, eroticgo.GREEN, "["+check.Effect+"]", check.Statement)
+		case checkmigration.EffectMissing:
+			outputs.ShowMessage(writer, eroticgo.RED, "["+check.Effect+"]", check.Statement)
+		default:
+			outputs.ShowMessage(writer, eroticgo.AMBER, "["+check.Effect+"]", check.Statement)
+		}
+	}
+}
+
+// newRepairCmd creates command guiding recovery of a dirty database
+// Shows scripts of the dirty version and statement effects, then forces previous, forces forward or runs remaining statements
+//
+// newRepairCmd 创建引导脏数据库恢复的命令
+// 显示脏版本的脚本和语句效果，然后强制回到上一版本、向前强制或执行剩余语句
+func newRepairCmd(cfg *Config) *cobra.Command {
+	var action string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:          "repair",
+		Short:        "Guide recovery of a dirty database",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				return runRepair(writer, cfg, action, yes)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&action, "action", "", "repair action without prompt: previous, forward or remaining")
+	cmd.Flags().BoolVar(&yes, "yes", false, "skip confirmation prompt")
+	return cmd
+}
+
+// RepairResult contains the repair plan and the action taken, written as JSON object of repair command
+//
+// RepairResult 包含修复计划和所执行的动作，作为 repair 命令的 JSON 对象输出
+type RepairResult struct {
+	Plan     *RepairPlan  // Repair plan, nil when database is not dirty // 修复计划，数据库非脏时为 nil
+	Action   string       // Action taken, empty when nothing was done // 所执行的动作，未执行时为空
+	Repaired bool         // Whether version was forced // 是否已强制设置版本
+	After    *VersionInfo // Version info after repair // 修复后的版本信息
+}
+
+// runRepair shows repair plan of the dirty version and runs the chosen action
+// A failed out-of-order step in history is repaired on its older version, keeping the database version
+//
+// runRepair 显示脏版本的修复计划并执行所选动作
+// 历史中失败的乱序步骤会针对其较旧版本修复，并保持数据库版本不变
+func runRepair(writer io.Writer, cfg *Config, action string, yes bool) (*RepairResult, error) {
+	if cfg.ScriptsInRoot == "" {
+		outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Repair needs ScriptsInRoot in config.")
+		return nil, erero.Wrap(migrationerrors.ErrUsage, "repair needs ScriptsInRoot in config")
+	}
+	migration, cleanup := cfg.Param.GetMigration()
+	defer cleanup()
+	db, _ := cfg.Param.GetDB()
+
+	result := &RepairResult{}
+	version, dirtyFlag, err := currentVersion(migration)
+	if err != nil {
+		return nil, err
+	}
+	if !dirtyFlag {
+		outputs.ShowMessage(writer, eroticgo.GREEN, "NOT DIRTY. Nothing to repair at version", version)
+		after, err := readVersionInfo(migration)
+		if err != nil {
+			return nil, err
+		}
+		result.After = after
+		return result, nil
+	}
+	failedVersion, outOfOrder, err := findOutOfOrderFailure(cfg, db, uint(version))
+	if err != nil {
+		return nil, err
+	}
+	if !outOfOrder {
+		failedVersion = uint(version)
+	}
+	plan, err := PlanRepair(db, cfg.ScriptsInRoot, failedVersion)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	plan.DatabaseVersion = uint(version)
+	plan.OutOfOrder = outOfOrder
+	ShowRepairPlan(writer, plan)
+	result.Plan = plan
+
+	if action == "" {
+		action, err = askRepairAction(plan)
+		if err != nil {
+			return nil, err
+		}
+		if action == "" {
+			outputs.ShowMessage(writer, eroticgo.AMBER, "CANCELLED")
+			return nil, erero.Wro(migrationerrors.ErrCancelled)
+		}
+	}
+
+	switch action {
+	case RepairForcePrevious, RepairForceForward:
+	case RepairRunRemaining:
+		// Statements of unknown kind may be fragments of a body the splitter could not keep whole
+		// 未知类型的语句可能是拆分器未能保持完整的正文片段
+		for _, statement := range plan.Remaining {
+			if _, ok := checkmigration.ClassifyStatement(statement); !ok && checkmigration.StatementTarget(statement) == "" {
+				outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Statement can not be classified, finish it by hand then use action", RepairForceForward)
+				return nil, erero.Wrapf(migrationerrors.ErrUsage, "remaining statement %q can not be classified", statement)
+			}
+		}
+		for _, statement := range plan.Remaining {
+			outputs.ShowMessage(writer, eroticgo.AMBER, "RUN", statement)
+		}
+	default:
+		return nil, erero.Wrapf(migrationerrors.ErrUsage, "unknown repair action %q, use %s, %s or %s", action, RepairForcePrevious, RepairForceForward, RepairRunRemaining)
+	}
+	forceVersion := repairForceVersion(plan, action)
+	if err := confirmTyped(writer, fmt.Sprintf("Repair dirty version %d with action %s", version, action), action, yes); err != nil {
+		return nil, err
+	}
+	if action == RepairRunRemaining {
+		for _, statement := range plan.Remaining {
+			if err := db.Exec(statement).Error; err != nil {
+				return nil, erero.Wro(err)
+			}
+		}
+	}
+	if err := migration.Force(forceVersion); err != nil {
+		return nil, erero.Wro(err)
+	}
+	if cfg.HistoryTable != "" && action != RepairForcePrevious {
+		if err := saveForcedRecord(cfg, plan.Version, "forced by repair "+action); err != nil {
+			return nil, err
+		}
+	}
+	outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS. Forced version", forceVersion)
+	result.Action = action
+	result.Repaired = true
+	after, err := readVersionInfo(migration)
+	if err != nil {
+		return nil, err
+	}
+	result.After = after
+	return result, nil
+}
+
+// repairForceVersion tells the version that action forces
+// Out-of-order failures keep the database version, since the older version never moved it
+//
+// repairForceVersion 给出动作所强制设置的版本
+// 乱序失败保持数据库版本不变，因为较旧版本从未改变它
+func repairForceVersion(plan *RepairPlan, action string) int {
+	if plan.OutOfOrder {
+		return int(plan.DatabaseVersion)
+	}
+	if action == RepairForcePrevious {
+		return plan.PreviousVersion
+	}
+	return int(plan.Version)
+}
+
+// askRepairAction asks operator to pick a repair action, returning empty when cancelled
+// Returns ErrUsage pointing at --action when the prompt can not run
+//
+// askRepairAction 请操作者选择修复动作，取消时返回空
+// 提示无法运行时返回指向 --action 的 ErrUsage
+func askRepairAction(plan *RepairPlan) (string, error) {
+	choices := [][2]string{
+		{fmt.Sprintf("force previous version (%d)", plan.PreviousVersion), RepairForcePrevious},
+		{fmt.Sprintf("force forward (%d)", plan.Version), RepairForceForward},
+		{fmt.Sprintf("run %d remaining statements then force forward (%d)", len(plan.Remaining), plan.Version), RepairRunRemaining},
+		{"cancel", ""},
+	}
+	if plan.OutOfOrder {
+		choices[0][0] = fmt.Sprintf("leave out-of-order version %d unapplied (keep %d)", plan.Version, plan.DatabaseVersion)
+		choices[1][0] = fmt.Sprintf("mark out-of-order version %d applied (keep %d)", plan.Version, plan.DatabaseVersion)
+		choices[2][0] = fmt.Sprintf("run %d remaining statements then mark out-of-order version %d applied (keep %d)", len(plan.Remaining), plan.Version, plan.DatabaseVersion)
+	}
+	var options []string
+	for _, choice := range choices {
+		options = append(options, choice[0])
+	}
+	var answer string
+	if err := askOne(&survey.Select{
+		Message: "How to repair the dirty version?",
+		Options: options,
+		Default: "cancel",
+	}, &answer, "--action"); err != nil {
+		return "", err
+	}
+	for _, choice := range choices {
+		if choice[0] == answer {
+			return choice[1], nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,77 @@
+package cobramigration_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMigrateCmd_Repair(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	// Index name clashes with an existing table in sqlite, so the second statement fails
+	// 在 sqlite 中索引名与已有表冲突，因此第二条语句失败
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00003_rank.up.sql"), []byte("ALTER TABLE `users` ADD COLUMN `rank` integer;\nCREATE INDEX `idx_users_rank` ON `users`(`rank`);\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00003_rank.down.sql"), []byte("DROP INDEX `idx_users_rank`;\nALTER TABLE `users` DROP COLUMN `rank`;\n"), 0644))
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+
+	runMigrateCmd(t, cfg, "inc", "2", "--yes")
+	{
+		db, cleanup := cfg.Param.GetDB()
+		require.NoError(t, db.Exec("CREATE TABLE `idx_users_rank` (`id` integer)").Error)
+		cleanup()
+	}
+	require.Error(t, runMigrateCmdE(cfg, "inc"))
+	version, dirtyFlag := readVersion(t, cfg.Param)
+	require.Equal(t, 3, version)
+	require.True(t, dirtyFlag)
+
+	{
+		db, cleanup := cfg.Param.GetDB()
+		plan, err := cobramigration.PlanRepair(db, scriptsInRoot, 3)
+		require.NoError(t, err)
+		cobramigration.ShowRepairPlan(os.Stdout, plan)
+		require.Equal(t, 2, plan.PreviousVersion)
+		require.Len(t, plan.Checks, 2)
+		require.Equal(t, checkmigration.EffectApplied, plan.Checks[0].Effect)
+		require.Equal(t, checkmigration.EffectMissing, plan.Checks[1].Effect)
+		require.Equal(t, []string{"CREATE INDEX `idx_users_rank` ON `users`(`rank`)"}, plan.Remaining)
+
+		require.NoError(t, db.Exec("DROP TABLE `idx_users_rank`").Error)
+		cleanup()
+	}
+
+	runMigrateCmd(t, cfg, "repair", "--action", cobramigration.RepairRunRemaining, "--yes")
+	version, dirtyFlag = readVersion(t, cfg.Param)
+	require.Equal(t, 3, version)
+	require.False(t, dirtyFlag)
+
+	db, cleanup := cfg.Param.GetDB()
+	defer cleanup()
+	require.True(t, db.Migrator().HasIndex("users", "idx_users_rank"))
+}
+
+func TestNewMigrateCmd_Repair_Unclassified(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00003_view.up.sql"), []byte("CREATE VIEW `user_names` AS SELECT `name` FROM `users`;\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00003_view.down.sql"), []byte("DROP VIEW `user_names`;\n"), 0644))
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+
+	runMigrateCmd(t, cfg, "inc", "2", "--yes")
+	{
+		db, cleanup := cfg.Param.GetDB()
+		require.NoError(t, db.Exec("UPDATE `schema_migrations` SET `version` = 3, `dirty` = 1").Error)
+		cleanup()
+	}
+
+	// Statements of unknown kind are not run blindly
+	// 未知类型的语句不会被盲目执行
+	require.ErrorIs(t, runMigrateCmdE(cfg, "repair", "--action", cobramigration.RepairRunRemaining, "--yes"), migrationerrors.ErrUsage)
+	version, dirtyFlag := readVersion(t, cfg.Param)
+	require.Equal(t, 3, version)
+	require.True(t, dirtyFlag)
+}
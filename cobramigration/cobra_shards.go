@@ -0,0 +1,245 @@
+package cobramigration
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-xlan/go-migrate/internal/fanout"
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+)
+
+// Shard describes one named database of a sharded deployment
+//
+// Shard 描述分片部署中的单个命名数据库
+type Shard struct {
+	Name  string                         // Shard name shown in summary table // 汇总表中显示的分片名称
+	Param *migrationparam.MigrationParam // Migration connection of this shard // 本分片的迁移连接
+}
+
+// ShardsConfig contains shards sharing one scripts DIR and fan-out settings
+//
+// ShardsConfig 包含共用一个脚本 DIR 的分片及分发设置
+type ShardsConfig struct {
+	Shards          []*Shard // Shards in declared sequence // 按声明顺序排列的分片
+	ScriptsInRoot   string   // Path to migration scripts DIR, optional // 迁移脚本 DIR 路径，可选
+	HistoryTable    string   // History table in each shard, recording is off when empty // 每个分片中的历史表，为空时不记录
+	Parallelism     int      // Max count of shards running at once // 同时运行的最大分片数
+	ContinueOnError bool     // Keep running other shards after a failure // 失败后继续运行其他分片
+	Hooks           *Hooks   // Functions called around the run and each step of each shard, concurrently across shards // 在每个分片的运行和每个步骤前后调用的函数，各分片并发调用
+	Observer        Observer // Receives events of each step of each shard, concurrently across shards // 接收每个分片每个步骤的事件，各分片并发调用
+}
+
+// ShardVersion contains version and dirty flag of one shard at one moment
+//
+// ShardVersion 包含单个分片在某一时刻的版本和脏标志
+type ShardVersion struct {
+	Version uint   // Database version, 0 when no scripts applied // 数据库版本，未应用脚本时为 0
+	Dirty   bool   // Database dirty flag // 数据库脏标志
+	Err     error  `json:"-"` // Error reading version // 读取版本的错误
+	Error   string // Message of Err in JSON output // JSON 输出中 Err 的信息
+}
+
+// ShardResult contains outcome of running one shard
+//
+// ShardResult 包含单个分片的运行结果
+type ShardResult struct {
+	Name     string        // Shard name // 分片名称
+	Before   *ShardVersion // Version before running // 运行前的版本
+	After    *ShardVersion // Version after running // 运行后的版本
+	Err      error         `json:"-"` // Error of this shard // 本分片的错误
+	Error    string        // Message of Err in JSON output // JSON 输出中 Err 的信息
+	Duration time.Duration // Time this shard took, in nanoseconds in JSON output // 本分片的耗时，JSON 输出中以纳秒表示
+}
+
+// ShardsResult contains results of all shards, written as JSON object of shards commands
+//
+// ShardsResult 包含所有分片的结果，作为 shards 命令的 JSON 对象输出
+type ShardsResult struct {
+	Shards []*ShardResult // Results in declared sequence // 按声明顺序排列的结果
+}
+
+// NewShardsCmd creates command tree running all, inc, dec and status across shards
+// Each run prints a summary table with version and dirty flag of each shard before and after
+//
+// NewShardsCmd 创建在所有分片上运行 all、inc、dec 和 status 的命令树
+// 每次运行都会打印汇总表，显示每个分片运行前后的版本和脏标志
+func NewShardsCmd(config *ShardsConfig) *cobra.Command {
+	// Create root command
+	var rootCmd = &cobra.Command{
+		Use:   "shards",
+		Short: "Sharded database migration",
+		Long:  "Sharded database migration",
+		Args:  cobra.NoArgs,
+	}
+	outputs.AddFlag(rootCmd)
+	rootCmd.PersistentFlags().IntVar(&config.Parallelism, "parallelism", max(1, config.Parallelism), "max count of shards running at once")
+	rootCmd.PersistentFlags().BoolVar(&config.ContinueOnError, "continue-on-error", config.ContinueOnError, "keep running other shards after a failure")
+
+	rootCmd.AddCommand(newShardsRunCmd(config, "all", "Run all migration files of each shard", MigrateAll))
+	rootCmd.AddCommand(newShardsStepsCmd(config, "inc [n]", "Run next n steps (+n) of each shard, one step by default", +1))
+	rootCmd.AddCommand(newShardsStepsCmd(config, "dec [n]", "Rollback n steps (-n) of each shard, one step by default", -1))
+	rootCmd.AddCommand(newShardsRunCmd(config, "status", "Show version of each shard", nil))
+	return rootCmd
+}
+
+// newShardsRunCmd creates subcommand running action on each shard, nil action only reads versions
+//
+// newShardsRunCmd 创建在每个分片上执行动作的子命令，动作为 nil 时仅读取版本
+func newShardsRunCmd(config *ShardsConfig, use string, short string, action func(cfg *Config) error) *cobra.Command {
+	return &cobra.Command{
+		Use:          use,
+		Short:        short,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				results, err := RunShards(config, action)
+				ShowShardResults(writer, results)
+				return &ShardsResult{Shards: results}, err
+			})
+		},
+	}
+}
+
+// newShardsStepsCmd creates subcommand running n steps on each shard, direction given by sign
+// Asks confirmation once before the fan-out when n > 1
+//
+// newShardsStepsCmd 创建在每个分片上执行 n 个步骤的子命令，方向由 sign 决定
+// n > 1 时在分发前请求一次确认
+func newShardsStepsCmd(config *ShardsConfig, use string, short string, sign int) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:          use,
+		Short:        short,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				n, err := parseStepCount(args)
+				if err != nil {
+					return nil, err
+				}
+				if err := confirmSteps(writer, sign*n, yes); err != nil {
+					return nil, err
+				}
+				results, err := RunShards(config, func(cfg *Config) error {
+					return MigrateSteps(cfg, sign*n)
+				})
+				ShowShardResults(writer, results)
+				return &ShardsResult{Shards: results}, err
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "skip confirmation prompt")
+	return cmd
+}
+
+// RunShards runs action on each shard with bounded concurrency, recording versions before and after
+// A nil action only reads versions, errors and panics of action are reported as errors of that shard
+// Returns results of every shard, with error wrapping the first failure when any shard failed
+//
+// RunShards 以有界并发在每个分片上执行动作，并记录运行前后的版本
+// 动作为 nil 时仅读取版本，动作的错误和 panic 作为该分片的错误报告
+// 返回每个分片的结果，任一分片失败时返回包装第一个失败的错误
+func RunShards(config *ShardsConfig, action func(cfg *Config) error) ([]*ShardResult, error) {
+	type shardTask struct {
+		shard  *Shard
+		result *ShardResult
+	}
+	tasks := make([]*shardTask, len(config.Shards))
+	shardResults := make([]*ShardResult, len(config.Shards))
+	for idx, shard := range config.Shards {
+		shardResults[idx] = &ShardResult{Name: shard.Name}
+		tasks[idx] = &shardTask{shard: shard, result: shardResults[idx]}
+	}
+	results := fanout.Run(tasks, &fanout.Options{
+		Parallelism: config.Parallelism,
+		StopOnError: !config.ContinueOnError,
+	}, func(task *shardTask) error {
+		task.result.Before = readShardVersion(task.shard.Param)
+		if task.result.Before.Err != nil {
+			return task.result.Before.Err
+		}
+		if action == nil {
+			task.result.After = task.result.Before
+			return nil
+		}
+		// Read the version after running even when action panics
+		// 即使动作 panic 也在运行后读取版本
+		defer func() {
+			task.result.After = readShardVersion(task.shard.Param)
+		}()
+		return action(&Config{
+			Param:         task.shard.Param,
+			ScriptsInRoot: config.ScriptsInRoot,
+			HistoryTable:  config.HistoryTable,
+			Hooks:         config.Hooks,
+			Observer:      config.Observer,
+		})
+	})
+	for _, result := range results {
+		result.Item.result.Err = result.Err
+		if result.Err != nil {
+			result.Item.result.Error = result.Err.Error()
+		}
+		result.Item.result.Duration = result.Duration
+	}
+	return shardResults, fanout.CheckFailures(results, "shards")
+}
+
+// readShardVersion reads version and dirty flag of shard, treating no version as 0
+//
+// readShardVersion 读取分片的版本和脏标志，无版本时视为 0
+func readShardVersion(param *migrationparam.MigrationParam) *ShardVersion {
+	migration, cleanup := param.GetMigration()
+	defer cleanup()
+
+	version, dirtyFlag, err := migration.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		err = erero.Wro(err)
+		return &ShardVersion{Err: err, Error: err.Error()}
+	}
+	return &ShardVersion{Version: version, Dirty: dirtyFlag}
+}
+
+// ShowShardResults writes summary table with version and dirty flag of each shard before and after into writer
+//
+// ShowShardResults 将汇总表写入 writer，显示每个分片运行前后的版本和脏标志
+func ShowShardResults(writer io.Writer, results []*ShardResult) {
+	outputs.ShowMessage(writer, eroticgo.CYAN, "=== Shard Results ===")
+	outputs.ShowMessage(writer, eroticgo.CYAN, fmt.Sprintf("%-16s %-24s %-24s %s", "SHARD", "BEFORE", "AFTER", "DURATION"))
+	for _, result := range results {
+		line := fmt.Sprintf("%-16s %-24s %-24s %s", result.Name, formatShardVersion(result.Before), formatShardVersion(result.After), result.Duration)
+		switch {
+		case result.Err != nil:
+			outputs.ShowMessage(writer, eroticgo.RED, line, "error:", result.Err)
+		case result.After != nil && result.After.Dirty:
+			outputs.ShowMessage(writer, eroticgo.RED, line)
+		default:
+			outputs.ShowMessage(writer, eroticgo.GREEN, line)
+		}
+	}
+}
+
+// formatShardVersion formats shard version as table cell
+//
+// formatShardVersion 将分片版本格式化为表格单元
+func formatShardVersion(shardVersion *ShardVersion) string {
+	switch {
+	case shardVersion == nil:
+		return "-"
+	case shardVersion.Err != nil:
+		return "(ERROR)"
+	case shardVersion.Dirty:
+		return fmt.Sprintf("%d (DIRTY)", shardVersion.Version)
+	default:
+		return fmt.Sprintf("%d", shardVersion.Version)
+	}
+}
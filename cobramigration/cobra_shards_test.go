@@ -0,0 +1,143 @@
+package cobramigration_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/internal/fanout"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/go-xlan/go-migrate/newmigrate"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newShards creates shards each using its own sqlite file, shard "broken" fails its first script
+// newShards 创建各自使用独立 sqlite 文件的分片，分片 "broken" 的第一个脚本会失败
+func newShards(t *testing.T, names ...string) (string, []*cobramigration.Shard) {
+	scriptsInRoot := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00001_init.up.sql"), []byte("CREATE TABLE `users` (`id` integer);\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00001_init.down.sql"), []byte("DROP TABLE `users`;\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00002_name.up.sql"), []byte("ALTER TABLE `users` ADD COLUMN `name` text;\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00002_name.down.sql"), []byte("ALTER TABLE `users` DROP COLUMN `name`;\n"), 0644))
+
+	databaseRoot := t.TempDir()
+	newDB := func(name string) *gorm.DB {
+		return rese.P1(gorm.Open(sqlite.Open(filepath.Join(databaseRoot, name+".db")), &gorm.Config{}))
+	}
+	var shards []*cobramigration.Shard
+	for _, name := range names {
+		if name == "broken" {
+			brokenDB := newDB(name)
+			require.NoError(t, brokenDB.Exec("CREATE TABLE `users` (`id` integer)").Error)
+			require.NoError(t, rese.P1(brokenDB.DB()).Close())
+		}
+		shards = append(shards, &cobramigration.Shard{
+			Name: name,
+			Param: migrationparam.NewMigrationParam(
+				func() *gorm.DB {
+					return newDB(name)
+				},
+				func(db *gorm.DB) *migrate.Migrate {
+					// Without tx wrap a failing script keeps effects of earlier statements, as DDL does in MySQL
+					// 不包裹事务时失败脚本会保留之前语句的效果，与 MySQL 中 DDL 的行为一致
+					return rese.P1(newmigrate.NewWithScriptsAndDatabase(&newmigrate.ScriptsAndDatabaseParam{
+						ScriptsInRoot:    scriptsInRoot,
+						DatabaseName:     "sqlite3",
+						DatabaseInstance: rese.V1(sqlite3.WithInstance(rese.P1(db.DB()), &sqlite3.Config{NoTxWrap: true})),
+					}))
+				},
+			),
+		})
+	}
+	return scriptsInRoot, shards
+}
+
+func TestRunShards(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "shard_00", "broken", "shard_01")
+	config := &cobramigration.ShardsConfig{
+		Shards:          shards,
+		ScriptsInRoot:   scriptsInRoot,
+		Parallelism:     2,
+		ContinueOnError: true,
+	}
+
+	results, err := cobramigration.RunShards(config, func(cfg *cobramigration.Config) error {
+		return cobramigration.MigrateSteps(cfg, +1)
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "1 of 3 shards failed")
+	cobramigration.ShowShardResults(os.Stdout, results)
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, uint(0), results[0].Before.Version)
+	require.Equal(t, uint(1), results[0].After.Version)
+	require.Error(t, results[1].Err)
+	require.True(t, results[1].After.Dirty)
+	require.NoError(t, results[2].Err)
+	require.Equal(t, uint(1), results[2].After.Version)
+
+	// Reading versions of a dirty shard is not a failure
+	// 读取脏分片的版本不算失败
+	results, err = cobramigration.RunShards(config, nil)
+	require.NoError(t, err)
+	cobramigration.ShowShardResults(os.Stdout, results)
+	require.Equal(t, uint(1), results[0].After.Version)
+	require.True(t, results[1].After.Dirty)
+
+	// Typed error of the failed shard is kept
+	// 保留失败分片的类型化错误
+	_, err = cobramigration.RunShards(config, cobramigration.MigrateAll)
+	require.ErrorIs(t, err, migrationerrors.ErrDirty)
+}
+
+func TestRunShards_StopOnError(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "broken", "shard_00")
+	config := &cobramigration.ShardsConfig{
+		Shards:        shards,
+		ScriptsInRoot: scriptsInRoot,
+		Parallelism:   1,
+	}
+
+	results, err := cobramigration.RunShards(config, cobramigration.MigrateAll)
+	require.Error(t, err)
+	cobramigration.ShowShardResults(os.Stdout, results)
+	require.Error(t, results[0].Err)
+	require.ErrorIs(t, results[1].Err, fanout.ErrSkipped)
+	require.Nil(t, results[1].Before)
+}
+
+func TestNewShardsCmd_Steps(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "shard_00", "shard_01")
+	buffer := &bytes.Buffer{}
+	config := &cobramigration.ShardsConfig{
+		Shards:        shards,
+		ScriptsInRoot: scriptsInRoot,
+		Parallelism:   2,
+		Observer:      cobramigration.NewJSONLinesObserver(buffer),
+	}
+
+	cmd := cobramigration.NewShardsCmd(config)
+	cmd.SetArgs([]string{"inc", "2", "--yes"})
+	require.NoError(t, cmd.Execute())
+	for _, shard := range shards {
+		version, _ := readVersion(t, shard.Param)
+		require.Equal(t, 2, version)
+	}
+	require.Contains(t, buffer.String(), string(cobramigration.EventStepFinished))
+
+	cmd = cobramigration.NewShardsCmd(config)
+	cmd.SetArgs([]string{"dec", "2", "--yes"})
+	require.NoError(t, cmd.Execute())
+	for _, shard := range shards {
+		version, _ := readVersion(t, shard.Param)
+		require.Equal(t, -1, version)
+	}
+}
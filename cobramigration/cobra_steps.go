@@ -0,0 +1,153 @@
+package cobramigration
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/internal/utils"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationhistory"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+)
+
+// parseStepCount parses optional step count argument, defaulting to 1
+//
+// parseStepCount 解析可选的步数参数，默认为 1
+func parseStepCount(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, erero.Wrapf(migrationerrors.ErrUsage, "step count %q must be a positive number", args[0])
+	}
+	return n, nil
+}
+
+// PlanSteps lists script versions that n steps apply (n > 0) or revert (n < 0) from database version
+// Use -1 as database version when no scripts applied
+// Reads versions through source driver of migration so embedded and remote sources are checked too
+// Returns ErrNoScripts when source does not hold enough versions
+//
+// PlanSteps 列出从数据库版本出发执行 n 步（n > 0）应用或（n < 0）回滚的脚本版本
+// 未应用任何脚本时数据库版本使用 -1
+// 通过迁移的源驱动读取版本，因此嵌入式和远程源同样会被校验
+// 当源中版本不足时返回 ErrNoScripts
+func PlanSteps(sourceDriver source.Driver, databaseVersion int, n int) ([]uint, error) {
+	scriptVersions, err := migrationhistory.ScanSourceVersions(sourceDriver)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+
+	var candidates []uint
+	if n > 0 {
+		for _, version := range scriptVersions {
+			if int(version) > databaseVersion {
+				candidates = append(candidates, version)
+			}
+		}
+	} else {
+		if databaseVersion >= 0 && !slices.Contains(scriptVersions, uint(databaseVersion)) {
+			return nil, erero.Errorf("database version %d has no script in source", databaseVersion)
+		}
+		for _, version := range slices.Backward(scriptVersions) {
+			if int(version) <= databaseVersion {
+				candidates = append(candidates, version)
+			}
+		}
+	}
+
+	count := max(n, -n)
+	if len(candidates) < count {
+		if n > 0 {
+			return nil, erero.Wrapf(migrationerrors.ErrNoScripts, "cannot apply %d steps, only %d scripts pending after version %d", count, len(candidates), databaseVersion)
+		}
+		return nil, erero.Wrapf(migrationerrors.ErrNoScripts, "cannot revert %d steps, only %d scripts applied up to version %d", count, len(candidates), databaseVersion)
+	}
+	return candidates[:count], nil
+}
+
+// confirmSteps asks confirmation to run n steps when |n| > 1, returning ErrCancelled when declined
+// Skips the prompt when yes is set, used in automation
+//
+// confirmSteps 在 |n| > 1 时请求确认执行 n 个步骤，拒绝时返回 ErrCancelled
+// 设置 yes 时跳过提示，用于自动化场景
+func confirmSteps(writer io.Writer, n int, yes bool) error {
+	if (n <= 1 && n >= -1) || yes {
+		return nil
+	}
+	action := "Apply"
+	if n < 0 {
+		action = "Revert"
+	}
+	var confirmed bool
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("%s %d steps?", action, max(n, -n)),
+		Default: false,
+	}
+	if err := askOne(prompt, &confirmed, "--yes"); err != nil {
+		return err
+	}
+	if !confirmed {
+		outputs.ShowMessage(writer, eroticgo.AMBER, "CANCELLED")
+		return erero.Wro(migrationerrors.ErrCancelled)
+	}
+	return nil
+}
+
+// planMigrationSteps lists versions that n steps run on migration, reading its source driver
+//
+// planMigrationSteps 读取迁移的源驱动，列出 n 个步骤将执行的版本
+func planMigrationSteps(migration *migrate.Migrate, n int) ([]uint, error) {
+	sourceDriver, err := utils.SourceDriver(migration)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	version, _, err := currentVersion(migration)
+	if err != nil {
+		return nil, err
+	}
+	return PlanSteps(sourceDriver, version, n)
+}
+
+// runSteps runs n migration steps on one connection, rolling back when n is negative
+// Validates n against source of migration, listing versions and asking confirmation when |n| > 1
+// Returns version info after running
+//
+// runSteps 在同一连接上执行 n 个迁移步骤，n 为负数时回滚
+// 根据迁移的源校验 n，|n| > 1 时列出版本并请求确认
+// 返回运行后的版本信息
+func runSteps(writer io.Writer, cfg *Config, n int, yes bool) (*VersionInfo, error) {
+	migration, cleanup := cfg.Param.GetMigration()
+	defer cleanup()
+	if err := verifyManifest(cfg, migration); err != nil {
+		return nil, err
+	}
+
+	action := "Apply"
+	if n < 0 {
+		action = "Revert"
+	}
+	versions, err := planMigrationSteps(migration, n)
+	if err != nil {
+		outputs.ShowMessage(writer, eroticgo.RED, "FAILED.", err.Error())
+		return nil, err
+	}
+	for _, version := range versions {
+		outputs.ShowMessage(writer, eroticgo.AMBER, action, version)
+	}
+	if err := confirmSteps(writer, n, yes); err != nil {
+		return nil, err
+	}
+	if err := utils.WhistleCauseE(migrateSteps(cfg.withWriter(writer), migration, n)); err != nil {
+		return nil, err
+	}
+	return readVersionInfo(migration)
+}
@@ -0,0 +1,83 @@
+package cobramigration_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+)
+
+func TestPlanSteps(t *testing.T) {
+	scriptsInRoot, _ := newShards(t)
+	sourceDriver := rese.V1(iofs.New(os.DirFS(scriptsInRoot), "."))
+	defer rese.F0(sourceDriver.Close)
+
+	versions, err := cobramigration.PlanSteps(sourceDriver, -1, 2)
+	require.NoError(t, err)
+	require.Equal(t, []uint{1, 2}, versions)
+
+	versions, err = cobramigration.PlanSteps(sourceDriver, 1, 1)
+	require.NoError(t, err)
+	require.Equal(t, []uint{2}, versions)
+
+	versions, err = cobramigration.PlanSteps(sourceDriver, 2, -2)
+	require.NoError(t, err)
+	require.Equal(t, []uint{2, 1}, versions)
+
+	_, err = cobramigration.PlanSteps(sourceDriver, 1, 2)
+	require.ErrorIs(t, err, migrationerrors.ErrNoScripts)
+
+	_, err = cobramigration.PlanSteps(sourceDriver, 1, -2)
+	require.ErrorIs(t, err, migrationerrors.ErrNoScripts)
+
+	_, err = cobramigration.PlanSteps(sourceDriver, 3, -1)
+	require.Error(t, err)
+}
+
+func TestNewMigrateCmd_StepCount(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+
+	// Too many steps are refused without running any of them
+	// 步数过多时拒绝执行且不运行任何步骤
+	require.ErrorIs(t, runMigrateCmdE(cfg, "inc", "3", "--yes"), migrationerrors.ErrNoScripts)
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, -1, version)
+
+	runMigrateCmd(t, cfg, "inc", "2", "--yes")
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, 2, version)
+
+	runMigrateCmd(t, cfg, "dec")
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+
+	runMigrateCmd(t, cfg, "inc")
+	runMigrateCmd(t, cfg, "dec", "2", "--yes")
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, -1, version)
+}
+
+func TestNewMigrateCmd_StepCount_WithoutScriptsInRoot(t *testing.T) {
+	_, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{Param: shards[0].Param}
+
+	// Steps are checked through source of migration, so nothing is reverted when n is too large
+	// 通过迁移的源校验步数，因此 n 过大时不会回滚任何版本
+	runMigrateCmd(t, cfg, "inc")
+	require.ErrorIs(t, runMigrateCmdE(cfg, "dec", "3", "--yes"), migrationerrors.ErrNoScripts)
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+
+	require.ErrorIs(t, cobramigration.MigrateSteps(cfg, +2), migrationerrors.ErrNoScripts)
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+
+	require.NoError(t, cobramigration.MigrateSteps(cfg, +1))
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, 2, version)
+}
@@ -0,0 +1,319 @@
+package cobramigration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/eroticgo"
+)
+
+// EventKind names the kind of migration event
+//
+// EventKind 表示迁移事件的类型
+type EventKind string
+
+// Migration event kinds in the sequence one step emits them
+// golang-migrate has no event hooks, so lock and statement events are synthesized on a best-effort basis:
+// lock_acquired and step_started are inferred from the golang-migrate log line of the step,
+// and statement_executed events are read from the script after the whole body ran, none are sent when the step failed
+//
+// 按单个步骤发出顺序排列的迁移事件类型
+// golang-migrate 没有事件钩子，因此锁和语句事件是尽力合成的：
+// lock_acquired 和 step_started 根据 golang-migrate 该步骤的日志行推断，
+// statement_executed 事件在整个正文执行后从脚本中读取，步骤失败时不发出
+const (
+	EventLockAcquired      EventKind = "lock_acquired"      // Migration lock held, inferred from the log line of the step // 已持有迁移锁，根据步骤的日志行推断
+	EventStepStarted       EventKind = "step_started"       // Step started reading its script // 步骤开始读取脚本
+	EventStatementExecuted EventKind = "statement_executed" // Statement of the step, synthesized from the script after the body ran // 步骤中的语句，在正文执行后根据脚本合成
+	EventStepFinished      EventKind = "step_finished"      // Step finished, with error when it failed // 步骤结束，失败时带有错误
+)
+
+// Event is one typed migration event, written as one JSON line by JSONLinesObserver
+//
+// Event 是单个类型化的迁移事件，由 JSONLinesObserver 写为一行 JSON
+type Event struct {
+	Kind      EventKind     // Event kind // 事件类型
+	Time      time.Time     // Time the event happened // 事件发生时间
+	Version   uint          `json:",omitempty"` // Script version of the step // 步骤的脚本版本
+	Direction string        `json:",omitempty"` // Step direction, up or down // 步骤方向，up 或 down
+	Name      string        `json:",omitempty"` // Script identifier of the step // 步骤的脚本标识
+	Statement string        `json:",omitempty"` // Executed statement // 已执行的语句
+	Duration  time.Duration `json:",omitempty"` // Step duration, in nanoseconds in JSON output // 步骤耗时，JSON 输出中以纳秒表示
+	Error     string        `json:",omitempty"` // Error of failed step // 失败步骤的错误
+}
+
+// Observer receives migration events, OnEvent is called on the migrating goroutine and must not block long
+// Events are best effort, use step_started and step_finished for timing and history records for audits
+//
+// Observer 接收迁移事件，OnEvent 在执行迁移的协程上调用，不应长时间阻塞
+// 事件是尽力而为的，计时请使用 step_started 和 step_finished，审计请使用历史记录
+type Observer interface {
+	OnEvent(event *Event)
+}
+
+// Observers sends each event to all observers in sequence
+//
+// Observers 将每个事件依次发送给所有观察者
+type Observers []Observer
+
+// OnEvent sends event to all observers
+//
+// OnEvent 将事件发送给所有观察者
+func (observers Observers) OnEvent(event *Event) {
+	for _, observer := range observers {
+		observer.OnEvent(event)
+	}
+}
+
+// ProgressObserver shows live progress of each step on the terminal
+//
+// ProgressObserver 在终端上实时显示每个步骤的进度
+type ProgressObserver struct {
+	writer io.Writer // Writer of progress lines, such as os.Stdout // 进度行的 writer，例如 os.Stdout
+	count  int       // Count of finished steps // 已结束的步骤数
+}
+
+// NewProgressObserver creates observer showing progress into writer, use os.Stderr to keep stdout clean in JSON mode
+//
+// NewProgressObserver 创建将进度写入 writer 的观察者，JSON 模式下使用 os.Stderr 以保持 stdout 干净
+func NewProgressObserver(writer io.Writer) *ProgressObserver {
+	return &ProgressObserver{writer: writer}
+}
+
+// OnEvent shows one line of progress
+//
+// OnEvent 显示一行进度
+func (p *ProgressObserver) OnEvent(event *Event) {
+	switch event.Kind {
+	case EventLockAcquired:
+		outputs.ShowMessage(p.writer, eroticgo.CYAN, "[LOCK] migration lock acquired")
+	case EventStepStarted:
+		outputs.ShowMessage(p.writer, eroticgo.CYAN, fmt.Sprintf("[STEP %d] %s %d %s ...", p.count+1, event.Direction, event.Version, event.Name))
+	case EventStatementExecuted:
+		outputs.ShowMessage(p.writer, eroticgo.BLUE, "    "+event.Statement)
+	case EventStepFinished:
+		p.count++
+		if event.Error != "" {
+			outputs.ShowMessage(p.writer, eroticgo.RED, fmt.Sprintf("[STEP %d] %s %d FAILED after %s: %s", p.count, event.Direction, event.Version, event.Duration, event.Error))
+		} else {
+			outputs.ShowMessage(p.writer, eroticgo.GREEN, fmt.Sprintf("[STEP %d] %s %d done in %s", p.count, event.Direction, event.Version, event.Duration))
+		}
+	}
+}
+
+// JSONLinesObserver writes each event as one JSON line, such as into an events file
+//
+// JSONLinesObserver 将每个事件写为一行 JSON，例如写入事件文件
+type JSONLinesObserver struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	err     error // First write error // 第一个写入错误
+}
+
+// NewJSONLinesObserver creates observer writing JSON lines into writer
+//
+// NewJSONLinesObserver 创建将 JSON 行写入 writer 的观察者
+func NewJSONLinesObserver(writer io.Writer) *JSONLinesObserver {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	return &JSONLinesObserver{encoder: encoder}
+}
+
+// OnEvent writes event as one JSON line, keeping the first write error without stopping the migration
+//
+// OnEvent 将事件写为一行 JSON，保留第一个写入错误而不中断迁移
+func (o *JSONLinesObserver) OnEvent(event *Event) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.err != nil {
+		return
+	}
+	o.err = o.encoder.Encode(event)
+}
+
+// Err returns the first write error
+//
+// Err 返回第一个写入错误
+func (o *JSONLinesObserver) Err() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.err
+}
+
+// emitEvent sends event to the observer of config when set
+//
+// emitEvent 配置了观察者时将事件发送给它
+func emitEvent(cfg *Config, event *Event) {
+	if cfg.Observer == nil {
+		return
+	}
+	event.Time = time.Now()
+	cfg.Observer.OnEvent(event)
+}
+
+// eventLogger is set as migrate.Migrate.Log during one step, turning golang-migrate log lines into events
+// golang-migrate logs "Start buffering" (or "Scheduled" without prefetch) once it holds the lock and starts reading the script
+// Matching log text is best effort, a step whose line is not recognized sends no events
+// Lines are forwarded to the previous migration log, verbose ones only when it is verbose
+//
+// eventLogger 在单个步骤期间设为 migrate.Migrate.Log，将 golang-migrate 的日志行转换为事件
+// golang-migrate 在持有锁并开始读取脚本时记录 "Start buffering"（未预取时为 "Scheduled"）
+// 匹配日志文本是尽力而为的，日志行未被识别的步骤不发出事件
+// 日志行会转发给之前的迁移日志，详细日志行仅在其为详细模式时转发
+type eventLogger struct {
+	cfg      *Config
+	previous migrate.Logger // Migration log set before the step, nil when none // 步骤前设置的迁移日志，未设置时为 nil
+	mutex    sync.Mutex
+	started  *Event // Step started event, nil until the step starts // 步骤开始事件，步骤开始前为 nil
+}
+
+// Printf receives golang-migrate log lines
+//
+// Printf 接收 golang-migrate 的日志行
+func (l *eventLogger) Printf(format string, v ...any) {
+	l.forward(format, v...)
+	message := strings.TrimSpace(fmt.Sprintf(format, v...))
+	logString, ok := strings.CutPrefix(message, "Start buffering ")
+	if !ok {
+		logString, ok = strings.CutPrefix(message, "Scheduled ")
+	}
+	if !ok {
+		return
+	}
+	event, ok := parseLogString(logString)
+	if !ok {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.started != nil {
+		return // Only one step runs per eventLogger // 每个 eventLogger 只运行一个步骤
+	}
+	l.started = event
+	emitEvent(l.cfg, &Event{Kind: EventLockAcquired})
+	emitEvent(l.cfg, event)
+}
+
+// forward sends log line to the previous migration log
+// golang-migrate sends errors and finished lines whatever Verbose returns, other lines only reach a verbose previous log
+//
+// forward 将日志行发送给之前的迁移日志
+// golang-migrate 不论 Verbose 返回什么都会发送错误行和结束行，其它行只送达详细模式的之前日志
+func (l *eventLogger) forward(format string, v ...any) {
+	if l.previous == nil {
+		return
+	}
+	if !l.previous.Verbose() && !strings.HasPrefix(format, "error: ") && !strings.HasPrefix(format, "Finished ") {
+		return
+	}
+	l.previous.Printf(format, v...)
+}
+
+// Verbose returns true so golang-migrate logs the start of each step
+//
+// Verbose 返回 true，使 golang-migrate 记录每个步骤的开始
+func (l *eventLogger) Verbose() bool {
+	return true
+}
+
+// startedEvent returns the step started event, nil when no step started
+//
+// startedEvent 返回步骤开始事件，没有步骤开始时为 nil
+func (l *eventLogger) startedEvent() *Event {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.started
+}
+
+// parseLogString parses migration log string "<version>/<u|d> <identifier>" of golang-migrate
+//
+// parseLogString 解析 golang-migrate 的迁移日志字符串 "<version>/<u|d> <identifier>"
+func parseLogString(logString string) (*Event, bool) {
+	versionText, rest, ok := strings.Cut(logString, "/")
+	if !ok {
+		return nil, false
+	}
+	version, err := strconv.ParseUint(versionText, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	directionText, name, _ := strings.Cut(rest, " ")
+	direction := string(source.Up)
+	if directionText == "d" {
+		direction = string(source.Down)
+	}
+	return &Event{Kind: EventStepStarted, Version: uint(version), Direction: direction, Name: name}, true
+}
+
+// observeStep runs one golang-migrate step with an event logger chained before the migration log
+// golang-migrate runs a script body at once, so statements are split from the script and emitted after the body ran
+// These statement events tell what the script holds, not when each statement ran, and are skipped when the step failed
+//
+// observeStep 将事件日志器串联在迁移日志之前并执行单个 golang-migrate 步骤
+// golang-migrate 一次性执行脚本正文，因此语句从脚本中拆分并在正文执行后发出
+// 这些语句事件表示脚本包含的内容，而非每条语句的执行时间，步骤失败时不发出
+func observeStep(cfg *Config, migration *migrate.Migrate, step func() error) error {
+	if cfg.Observer == nil {
+		return step()
+	}
+	previousLog := migration.Log
+	logger := &eventLogger{cfg: cfg, previous: previousLog}
+	migration.Log = logger
+	defer func() {
+		migration.Log = previousLog
+	}()
+
+	cause := step()
+	started := logger.startedEvent()
+	if started == nil {
+		return cause // Step did not start, golang-migrate refused or had nothing to run // 步骤未开始，golang-migrate 拒绝或无可执行内容
+	}
+	if cause == nil && cfg.ScriptsInRoot != "" {
+		if content, err := readScript(cfg.ScriptsInRoot, started.Version, started.Direction); err == nil {
+			for _, statement := range checkmigration.SplitStatements(content) {
+				emitStatement(cfg, started, statement)
+			}
+		}
+	}
+	emitStepFinished(cfg, started, cause)
+	return cause
+}
+
+// emitStatement emits statement executed event of the started step
+//
+// emitStatement 发出已开始步骤的语句执行事件
+func emitStatement(cfg *Config, started *Event, statement string) {
+	emitEvent(cfg, &Event{
+		Kind:      EventStatementExecuted,
+		Version:   started.Version,
+		Direction: started.Direction,
+		Name:      started.Name,
+		Statement: statement,
+	})
+}
+
+// emitStepFinished emits step finished event with duration since the started event
+//
+// emitStepFinished 发出步骤结束事件，耗时从开始事件起计算
+func emitStepFinished(cfg *Config, started *Event, cause error) {
+	event := &Event{
+		Kind:      EventStepFinished,
+		Version:   started.Version,
+		Direction: started.Direction,
+		Name:      started.Name,
+		Duration:  time.Since(started.Time),
+	}
+	if cause != nil {
+		event.Error = cause.Error()
+	}
+	emitEvent(cfg, event)
+}
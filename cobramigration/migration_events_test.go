@@ -0,0 +1,131 @@
+package cobramigration_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/go-xlan/go-migrate/newmigrate"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// readEvents parses JSON lines written by JSONLinesObserver into "kind version direction" texts
+// readEvents 将 JSONLinesObserver 写出的 JSON 行解析为 "类型 版本 方向" 文本
+func readEvents(t *testing.T, buffer *bytes.Buffer) []string {
+	var texts []string
+	scanner := bufio.NewScanner(buffer)
+	for scanner.Scan() {
+		var event cobramigration.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.False(t, event.Time.IsZero())
+		texts = append(texts, fmt.Sprintf("%s %d %s %s", event.Kind, event.Version, event.Direction, event.Statement))
+	}
+	return texts
+}
+
+func TestNewMigrateCmd_Observer(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	buffer := &bytes.Buffer{}
+	observer := cobramigration.NewJSONLinesObserver(buffer)
+	cfg := &cobramigration.Config{
+		Param:         shards[0].Param,
+		ScriptsInRoot: scriptsInRoot,
+		Observer:      cobramigration.Observers{observer, cobramigration.NewProgressObserver(os.Stdout)},
+	}
+
+	runMigrateCmd(t, cfg, "all")
+	require.NoError(t, observer.Err())
+	require.Equal(t, []string{
+		"lock_acquired 0  ",
+		"step_started 1 up ",
+		"statement_executed 1 up CREATE TABLE `users` (`id` integer)",
+		"step_finished 1 up ",
+		"lock_acquired 0  ",
+		"step_started 2 up ",
+		"statement_executed 2 up ALTER TABLE `users` ADD COLUMN `name` text",
+		"step_finished 2 up ",
+	}, readEvents(t, buffer))
+
+	runMigrateCmd(t, cfg, "dec")
+	require.Equal(t, []string{
+		"lock_acquired 0  ",
+		"step_started 2 down ",
+		"statement_executed 2 down ALTER TABLE `users` DROP COLUMN `name`",
+		"step_finished 2 down ",
+	}, readEvents(t, buffer))
+}
+
+func TestNewMigrateCmd_ObserverFailure(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "broken")
+	buffer := &bytes.Buffer{}
+	cfg := &cobramigration.Config{
+		Param:         shards[0].Param,
+		ScriptsInRoot: scriptsInRoot,
+		Observer:      cobramigration.NewJSONLinesObserver(buffer),
+	}
+
+	require.Error(t, runMigrateCmdE(cfg, "all"))
+	var finished cobramigration.Event
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	require.NoError(t, json.Unmarshal(lines[len(lines)-1], &finished))
+	require.Equal(t, cobramigration.EventStepFinished, finished.Kind)
+	require.Equal(t, uint(1), finished.Version)
+	require.NotEmpty(t, finished.Error)
+}
+
+// recordLogger records lines golang-migrate sends to the migration log
+// recordLogger 记录 golang-migrate 发送给迁移日志的行
+type recordLogger struct {
+	lines []string
+}
+
+func (l *recordLogger) Printf(format string, v ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *recordLogger) Verbose() bool {
+	return false
+}
+
+func TestNewMigrateCmd_ObserverKeepsLog(t *testing.T) {
+	scriptsInRoot, _ := newShards(t)
+	logger := &recordLogger{}
+	param := migrationparam.NewMigrationParam(
+		func() *gorm.DB {
+			return rese.P1(gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "main.db")), &gorm.Config{}))
+		},
+		func(db *gorm.DB) *migrate.Migrate {
+			migration := rese.P1(newmigrate.NewWithScriptsAndDatabase(&newmigrate.ScriptsAndDatabaseParam{
+				ScriptsInRoot:    scriptsInRoot,
+				DatabaseName:     "sqlite3",
+				DatabaseInstance: rese.V1(sqlite3.WithInstance(rese.P1(db.DB()), &sqlite3.Config{})),
+			}))
+			migration.Log = logger
+			return migration
+		},
+	)
+	buffer := &bytes.Buffer{}
+	cfg := &cobramigration.Config{
+		Param:         param,
+		ScriptsInRoot: scriptsInRoot,
+		Observer:      cobramigration.NewJSONLinesObserver(buffer),
+	}
+
+	runMigrateCmd(t, cfg, "inc")
+	require.Contains(t, readEvents(t, buffer), "step_started 1 up ")
+	// Non-verbose log gets the finished line but no verbose lines
+	// 非详细模式的日志收到结束行，但不收到详细日志行
+	require.Len(t, logger.lines, 1)
+	require.Contains(t, logger.lines[0], "Finished 1/u init")
+}
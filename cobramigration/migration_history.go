@@ -0,0 +1,430 @@
+package cobramigration
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationhistory"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+)
+
+// isNothingToRun reports errors meaning no step was run
+//
+// isNothingToRun 判断表示没有执行任何步骤的错误
+func isNothingToRun(err error) bool {
+	return errors.Is(err, migrate.ErrNoChange) || errors.Is(err, migrate.ErrNilVersion) || errors.Is(err, os.ErrNotExist)
+}
+
+// isRefused reports errors returned before any script ran, such as a dirty database
+//
+// isRefused 判断在任何脚本执行前返回的错误，例如数据库为脏状态
+func isRefused(err error) bool {
+	var errDirty migrate.ErrDirty
+	return errors.As(err, &errDirty) || errors.Is(err, migrate.ErrLocked)
+}
+
+// runStep runs one step up (+1) or down (-1), saving it into history table, calling step hooks and emitting events when configured
+//
+// runStep 执行一个升级（+1）或回滚（-1）步骤，配置了历史表、钩子和观察者时将其保存、调用步骤钩子并发出事件
+func runStep(cfg *Config, migration *migrate.Migrate, sign int) error {
+	if !isStepwise(cfg) {
+		return migration.Steps(sign)
+	}
+	beforeVersion, dirtyFlag, err := currentVersion(migration)
+	if err != nil {
+		return err
+	}
+	stepVersion, stepDirection, known := stepTarget(cfg, beforeVersion, dirtyFlag, sign)
+	return runHookedStep(cfg, stepVersion, stepDirection, known, func() error {
+		return observeStep(cfg, migration, func() error {
+			return recordStep(cfg, migration, sign, beforeVersion)
+		})
+	})
+}
+
+// recordStep runs one step up (+1) or down (-1) from beforeVersion, saving it into history table when configured
+//
+// recordStep 从 beforeVersion 执行一个升级（+1）或回滚（-1）步骤，配置了历史表时将其保存
+func recordStep(cfg *Config, migration *migrate.Migrate, sign int, beforeVersion int) error {
+	if cfg.HistoryTable == "" {
+		return migration.Steps(sign)
+	}
+	startedAt := time.Now()
+	cause := migration.Steps(sign)
+	if isNothingToRun(cause) || isRefused(cause) {
+		return cause
+	}
+	version, direction := uint(beforeVersion), string(source.Down)
+	if sign > 0 {
+		// Up step sets version to the script version, even when it fails
+		// 升级步骤会将版本设为脚本版本，即使失败也是如此
+		afterVersion, _, err := currentVersion(migration)
+		if err != nil {
+			if cause != nil {
+				return cause // Keep the step error when there is one // 有步骤错误时保留步骤错误
+			}
+			return err
+		}
+		version, direction = uint(afterVersion), string(source.Up)
+	}
+	return saveStepRecord(cfg, newHistoryRecord(cfg, version, direction, startedAt, cause), cause)
+}
+
+// newHistoryRecord creates history record of a step that started at startedAt and just finished
+//
+// newHistoryRecord 创建在 startedAt 开始且刚刚结束的步骤的历史记录
+func newHistoryRecord(cfg *Config, version uint, direction string, startedAt time.Time, cause error) *migrationhistory.HistoryRecord {
+	finishedAt := time.Now()
+	record := &migrationhistory.HistoryRecord{
+		Version:    version,
+		Direction:  direction,
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+		DurationMs: finishedAt.Sub(startedAt).Milliseconds(),
+		Host:       currentHost(),
+		OsUser:     currentOsUser(),
+		Checksum:   scriptChecksum(cfg.ScriptsInRoot, version, direction),
+		Outcome:    migrationhistory.OutcomeSuccess,
+	}
+	if cause != nil {
+		record.Outcome = migrationhistory.OutcomeFailure
+		record.Message = cause.Error()
+	}
+	return record
+}
+
+// ensureHistoryTable creates history table when missing, called once before a recorded run
+//
+// ensureHistoryTable 在历史表不存在时创建，在每次记录的运行之前调用一次
+func ensureHistoryTable(cfg *Config) error {
+	db, _ := cfg.Param.GetDB()
+	return migrationhistory.EnsureTable(db, cfg.HistoryTable)
+}
+
+// saveHistoryRecord saves record into history table, which ensureHistoryTable created
+//
+// saveHistoryRecord 将记录保存到由 ensureHistoryTable 创建的历史表
+func saveHistoryRecord(cfg *Config, record *migrationhistory.HistoryRecord) error {
+	db, _ := cfg.Param.GetDB()
+	return migrationhistory.SaveRecord(db, cfg.HistoryTable, record)
+}
+
+// saveStepRecord saves record of a step and returns the step error, or the saving error when the step succeeded
+// A saving error after a failed step is only printed, so the step error is not hidden
+//
+// saveStepRecord 保存步骤的记录并返回步骤的错误，步骤成功时返回保存的错误
+// 步骤失败后的保存错误仅被打印，使步骤的错误不被掩盖
+func saveStepRecord(cfg *Config, record *migrationhistory.HistoryRecord, cause error) error {
+	if err := saveHistoryRecord(cfg, record); err != nil {
+		if cause != nil {
+			outputs.ShowMessage(cfg.noteWriter(), eroticgo.RED, "save history record failed:", err.Error())
+			return cause
+		}
+		return erero.Wrapf(err, "save history record of %s version %d failed", record.Direction, record.Version)
+	}
+	return cause
+}
+
+// saveForcedRecord saves a forced record of up version into history table, so out-of-order detection treats it as applied
+// Called after force or repair set the version without running the whole script
+//
+// saveForcedRecord 将升级版本的强制记录保存到历史表，使乱序检测将其视为已应用
+// 在 force 或 repair 未完整执行脚本而设置版本后调用
+func saveForcedRecord(cfg *Config, version uint, message string) error {
+	if err := ensureHistoryTable(cfg); err != nil {
+		return err
+	}
+	record := newHistoryRecord(cfg, version, string(source.Up), time.Now(), nil)
+	record.Outcome = migrationhistory.OutcomeForced
+	record.Message = message
+	if err := saveHistoryRecord(cfg, record); err != nil {
+		return erero.Wrapf(err, "save forced record of version %d failed", version)
+	}
+	return nil
+}
+
+// runStepwise ensures history table when recording is on, then runs the steps with hooks
+//
+// runStepwise 开启记录时确保历史表存在，然后带钩子执行步骤
+func runStepwise(cfg *Config, run func() error) error {
+	if cfg.HistoryTable != "" {
+		if err := ensureHistoryTable(cfg); err != nil {
+			return err
+		}
+	}
+	return runWithHooks(cfg, run)
+}
+
+// migrateUp runs all pending migrations, one recorded and hooked step at a time when history or hooks are on
+//
+// migrateUp 执行所有待处理迁移，开启历史或钩子时逐步执行、记录并调用钩子
+func migrateUp(cfg *Config, migration *migrate.Migrate) error {
+	if !isStepwise(cfg) {
+		return migration.Up()
+	}
+	return runStepwise(cfg, func() error {
+		return stepUp(cfg, migration)
+	})
+}
+
+// stepUp runs all pending migrations one step at a time
+//
+// stepUp 逐步执行所有待处理迁移
+func stepUp(cfg *Config, migration *migrate.Migrate) error {
+	for count := 0; ; count++ {
+		if err := runStep(cfg, migration, +1); err != nil {
+			if isNothingToRun(err) {
+				if count == 0 {
+					return migrate.ErrNoChange
+				}
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// migrateSteps runs n steps, one recorded and hooked step at a time when history or hooks are on
+// Maps running out of scripts to ErrNoScripts, so it is not taken as nothing to run
+//
+// migrateSteps 执行 n 个步骤，开启历史或钩子时逐步执行、记录并调用钩子
+// 将脚本不足映射为 ErrNoScripts，避免被当作无需执行
+func migrateSteps(cfg *Config, migration *migrate.Migrate, n int) error {
+	err := stepMigration(cfg, migration, n)
+	var errShortLimit migrate.ErrShortLimit
+	if errors.As(err, &errShortLimit) || errors.Is(err, os.ErrNotExist) {
+		return erero.Wrapf(migrationerrors.ErrNoScripts, "cannot run %d steps: %v", n, err)
+	}
+	return err
+}
+
+// stepMigration runs n steps, one recorded and hooked step at a time when history or hooks are on
+//
+// stepMigration 执行 n 个步骤，开启历史或钩子时逐步执行、记录并调用钩子
+func stepMigration(cfg *Config, migration *migrate.Migrate, n int) error {
+	if !isStepwise(cfg) {
+		return migration.Steps(n)
+	}
+	sign := 1
+	if n < 0 {
+		sign = -1
+	}
+	return runStepwise(cfg, func() error {
+		for idx := 0; idx < max(n, -n); idx++ {
+			if err := runStep(cfg, migration, sign); err != nil {
+				if idx > 0 && isNothingToRun(err) {
+					return migrate.ErrShortLimit{Short: uint(max(n, -n) - idx)}
+				}
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrateTo migrates up or down to target version, one recorded and hooked step at a time when history or hooks are on
+//
+// migrateTo 升级或回滚到目标版本，开启历史或钩子时逐步执行、记录并调用钩子
+func migrateTo(cfg *Config, migration *migrate.Migrate, targetVersion uint) error {
+	if !isStepwise(cfg) {
+		return migration.Migrate(targetVersion)
+	}
+	return runStepwise(cfg, func() error {
+		for {
+			version, _, err := currentVersion(migration)
+			if err != nil {
+				return err
+			}
+			if version == int(targetVersion) {
+				return nil
+			}
+			sign := 1
+			if version > int(targetVersion) {
+				sign = -1
+			}
+			if err := runStep(cfg, migration, sign); err != nil {
+				return err
+			}
+			afterVersion, _, err := currentVersion(migration)
+			if err != nil {
+				return err
+			}
+			if sign > 0 && afterVersion > int(targetVersion) {
+				return erero.Errorf("version %d has no script, stepped past it to %d", targetVersion, afterVersion)
+			}
+		}
+	})
+}
+
+// migrateDown rolls back all applied migrations, one recorded and hooked step at a time when history or hooks are on
+//
+// migrateDown 回滚所有已应用迁移，开启历史或钩子时逐步执行、记录并调用钩子
+func migrateDown(cfg *Config, migration *migrate.Migrate) error {
+	if !isStepwise(cfg) {
+		return migration.Down()
+	}
+	return runStepwise(cfg, func() error {
+		for count := 0; ; count++ {
+			if err := runStep(cfg, migration, -1); err != nil {
+				if isNothingToRun(err) {
+					if count == 0 {
+						return migrate.ErrNoChange
+					}
+					return nil
+				}
+				return err
+			}
+			version, _, err := currentVersion(migration)
+			if err != nil {
+				return err
+			}
+			if version < 0 {
+				return nil
+			}
+		}
+	})
+}
+
+// currentHost returns host name of the machine, falling back to HOSTNAME env and then "unknown"
+//
+// currentHost 返回机器的主机名，失败时依次回退到 HOSTNAME 环境变量和 "unknown"
+func currentHost() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	if host := os.Getenv("HOSTNAME"); host != "" {
+		return host
+	}
+	return "unknown"
+}
+
+// currentOsUser returns name of the OS user running the process
+//
+// currentOsUser 返回运行进程的系统用户名
+func currentOsUser() string {
+	if osUser, err := user.Current(); err == nil {
+		return osUser.Username
+	}
+	return os.Getenv("USER")
+}
+
+// scriptChecksum computes checksum of the script file with version and direction, empty when not found
+//
+// scriptChecksum 计算指定版本和方向的脚本文件校验和，找不到时为空
+func scriptChecksum(scriptsInRoot string, version uint, direction string) string {
+	content, err := readScript(scriptsInRoot, version, direction)
+	if err != nil {
+		return ""
+	}
+	return newscripts.ScriptChecksum(content)
+}
+
+// readScript reads content of the script file with version and direction
+//
+// readScript 读取指定版本和方向的脚本文件内容
+func readScript(scriptsInRoot string, version uint, direction string) (string, error) {
+	name, err := findScriptName(scriptsInRoot, version, direction)
+	if err != nil {
+		return "", erero.Wro(err)
+	}
+	content, err := os.ReadFile(filepath.Join(scriptsInRoot, name))
+	if err != nil {
+		return "", erero.Wro(err)
+	}
+	return string(content), nil
+}
+
+// findScriptName finds name of the script file with version and direction
+//
+// findScriptName 查找指定版本和方向的脚本文件名
+func findScriptName(scriptsInRoot string, version uint, direction string) (string, error) {
+	if scriptsInRoot == "" {
+		return "", erero.New("scripts DIR is not set")
+	}
+	entries, err := os.ReadDir(scriptsInRoot)
+	if err != nil {
+		return "", erero.Wro(err)
+	}
+	for _, item := range entries {
+		migration, err := source.DefaultParse(item.Name())
+		if err != nil || migration.Version != version || string(migration.Direction) != direction {
+			continue
+		}
+		return item.Name(), nil
+	}
+	return "", erero.Errorf("%s script of version %d not found in %s", direction, version, scriptsInRoot)
+}
+
+// ShowHistory writes history records into writer one line each
+//
+// ShowHistory 将历史记录写入 writer，每行一条
+func ShowHistory(writer io.Writer, records []*migrationhistory.HistoryRecord) {
+	outputs.ShowMessage(writer, eroticgo.CYAN, fmt.Sprintf("%-16s %-5s %-25s %-10s %-16s %-16s %-8s %s", "VERSION", "DIR", "STARTED", "DURATION", "HOST", "USER", "OUTCOME", "CHECKSUM"))
+	for _, record := range records {
+		line := fmt.Sprintf("%-16d %-5s %-25s %-10s %-16s %-16s %-8s %s",
+			record.Version,
+			record.Direction,
+			record.StartedAt.Format(time.RFC3339),
+			(time.Duration(record.DurationMs) * time.Millisecond).String(),
+			record.Host,
+			record.OsUser,
+			record.Outcome,
+			record.Checksum,
+		)
+		if record.Outcome == migrationhistory.OutcomeFailure {
+			outputs.ShowMessage(writer, eroticgo.RED, line, "error:", record.Message)
+		} else {
+			outputs.ShowMessage(writer, eroticgo.GREEN, line)
+		}
+	}
+}
+
+// HistoryResult contains history records newest first, written as JSON object of history command
+//
+// HistoryResult 包含按从新到旧排列的历史记录，作为 history 命令的 JSON 对象输出
+type HistoryResult struct {
+	Records []*migrationhistory.HistoryRecord // History records, empty when history is off // 历史记录，未开启历史时为空
+}
+
+// newHistoryCmd creates command listing history records
+//
+// newHistoryCmd 创建列出历史记录的命令
+func newHistoryCmd(cfg *Config) *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:          "history",
+		Short:        "List up and down steps saved in history table",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				if cfg.HistoryTable == "" {
+					outputs.ShowMessage(writer, eroticgo.AMBER, "History is off. Set HistoryTable in config to record each step.")
+					return &HistoryResult{}, nil
+				}
+				db, cleanup := cfg.Param.GetDB()
+				defer cleanup()
+				records, err := migrationhistory.ReadHistory(db, cfg.HistoryTable, limit)
+				if err != nil {
+					return nil, err
+				}
+				ShowHistory(writer, records)
+				return &HistoryResult{Records: records}, nil
+			})
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 20, "max count of records to list, all records when not positive")
+	return cmd
+}
@@ -0,0 +1,93 @@
+package cobramigration_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationhistory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHistory(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{
+		Param:         shards[0].Param,
+		ScriptsInRoot: scriptsInRoot,
+		HistoryTable:  migrationhistory.DefaultHistoryTable,
+	}
+
+	runMigrateCmd(t, cfg, "all")
+	runMigrateCmd(t, cfg, "dec")
+	runMigrateCmd(t, cfg, "goto", "2")
+	runMigrateCmd(t, cfg, "history")
+
+	db, cleanup := cfg.Param.GetDB()
+	defer cleanup()
+	records, err := migrationhistory.ReadHistory(db, cfg.HistoryTable, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	// Records come newest first
+	// 记录按从新到旧排列
+	type step struct {
+		Version   uint
+		Direction string
+	}
+	var steps []step
+	for _, record := range records {
+		steps = append(steps, step{Version: record.Version, Direction: record.Direction})
+		require.Equal(t, migrationhistory.OutcomeSuccess, record.Outcome)
+		require.NotEmpty(t, record.Checksum)
+		require.NotEmpty(t, record.Host)
+		require.False(t, record.FinishedAt.Before(record.StartedAt))
+	}
+	require.Equal(t, []step{{2, "up"}, {2, "down"}, {2, "up"}, {1, "up"}}, steps)
+}
+
+func TestReadHistory_Failure(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "broken")
+	cfg := &cobramigration.Config{
+		Param:         shards[0].Param,
+		ScriptsInRoot: scriptsInRoot,
+		HistoryTable:  migrationhistory.DefaultHistoryTable,
+	}
+
+	require.Error(t, cobramigration.MigrateAll(cfg))
+	// Dirty database refuses the step so no record is added
+	// 脏数据库拒绝执行步骤，因此不会新增记录
+	require.ErrorIs(t, cobramigration.MigrateAll(cfg), migrationerrors.ErrDirty)
+
+	db, cleanup := cfg.Param.GetDB()
+	defer cleanup()
+	records, err := migrationhistory.ReadHistory(db, cfg.HistoryTable, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, uint(1), records[0].Version)
+	require.Equal(t, migrationhistory.OutcomeFailure, records[0].Outcome)
+	require.NotEmpty(t, records[0].Message)
+}
+
+func TestReadHistory_Forced(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{
+		Param:         shards[0].Param,
+		ScriptsInRoot: scriptsInRoot,
+		HistoryTable:  migrationhistory.DefaultHistoryTable,
+	}
+
+	runMigrateCmd(t, cfg, "force", "2", "--yes")
+	runMigrateCmd(t, cfg, "force", "--yes", "--", "-1")
+
+	// Clearing the version saves no record
+	// 清除版本时不保存记录
+	db, cleanup := cfg.Param.GetDB()
+	defer cleanup()
+	records, err := migrationhistory.ReadHistory(db, cfg.HistoryTable, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, uint(2), records[0].Version)
+	require.Equal(t, "up", records[0].Direction)
+	require.Equal(t, migrationhistory.OutcomeForced, records[0].Outcome)
+	require.NotEmpty(t, records[0].Checksum)
+}
@@ -0,0 +1,388 @@
+package cobramigration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/internal/utils"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+)
+
+// PlanStep is one pending up script that `all` would apply
+//
+// PlanStep 是 `all` 将要应用的单个待处理升级脚本
+type PlanStep struct {
+	Version  uint   // Script version // 脚本版本
+	Name     string // Up script version and identifier, such as 1_init.up // 升级脚本的版本和标识，例如 1_init.up
+	Checksum string // Checksum of up script content // 升级脚本内容的校验和
+	Content  string // Up script content // 升级脚本内容
+}
+
+// Plan lists pending up scripts in order, written as JSON object of plan command and saved as plan file
+//
+// Plan 按顺序列出待处理的升级脚本，作为 plan 命令的 JSON 对象输出并保存为计划文件
+type Plan struct {
+	DatabaseVersion int         // Database version the plan starts from, -1 when no scripts applied // 计划起始的数据库版本，未应用脚本时为 -1
+	Steps           []*PlanStep // Pending up scripts in order // 按顺序排列的待处理升级脚本
+}
+
+// ComputePlan walks source driver of migration from database version and reads each pending up script
+// Use -1 as database version when no scripts applied
+//
+// ComputePlan 从数据库版本开始遍历迁移的源驱动并读取每个待处理的升级脚本
+// 未应用任何脚本时数据库版本使用 -1
+func ComputePlan(sourceDriver source.Driver, databaseVersion int) (*Plan, error) {
+	plan := &Plan{DatabaseVersion: databaseVersion}
+	var version uint
+	var err error
+	if databaseVersion < 0 {
+		version, err = sourceDriver.First()
+	} else {
+		version, err = sourceDriver.Next(uint(databaseVersion))
+	}
+	for err == nil {
+		step, ok, cause := readPlanStep(sourceDriver, version)
+		if cause != nil {
+			return nil, erero.Wro(cause)
+		}
+		if ok {
+			plan.Steps = append(plan.Steps, step)
+		}
+		version, err = sourceDriver.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, erero.Wro(err)
+	}
+	return plan, nil
+}
+
+// readPlanStep reads up script of version through source driver, ok is false when version has no up script
+//
+// readPlanStep 通过源驱动读取版本的升级脚本，版本没有升级脚本时 ok 为 false
+func readPlanStep(sourceDriver source.Driver, version uint) (*PlanStep, bool, error) {
+	reader, identifier, err := sourceDriver.ReadUp(version)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, erero.Wro(err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, erero.Wro(err)
+	}
+	return &PlanStep{
+		Version:  version,
+		Name:     fmt.Sprintf("%d_%s.%s", version, identifier, source.Up),
+		Checksum: newscripts.ScriptChecksum(string(content)),
+		Content:  string(content),
+	}, true, nil
+}
+
+// ShowPlan writes each pending up script with name, checksum and full content into writer
+//
+// ShowPlan 将每个待处理升级脚本的名称、校验和及完整内容写入 writer
+func ShowPlan(writer io.Writer, plan *Plan) {
+	if len(plan.Steps) == 0 {
+		outputs.ShowMessage(writer, eroticgo.GREEN, "NO PENDING SCRIPTS at version", plan.DatabaseVersion)
+		return
+	}
+	outputs.ShowMessage(writer, eroticgo.AMBER, fmt.Sprintf("Apply %d scripts after version %d", len(plan.Steps), plan.DatabaseVersion))
+	for _, step := range plan.Steps {
+		outputs.ShowMessage(writer, eroticgo.CYAN, "=== "+step.Name+" "+step.Checksum+" ===")
+		fmt.Fprintln(writer, step.Content)
+	}
+}
+
+// WritePlan saves plan as JSON plan file
+//
+// WritePlan 将计划保存为 JSON 计划文件
+func WritePlan(path string, plan *Plan) error {
+	content, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return erero.Wro(err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return erero.Wro(err)
+	}
+	return nil
+}
+
+// ReadPlan reads JSON plan file saved by WritePlan
+//
+// ReadPlan 读取由 WritePlan 保存的 JSON 计划文件
+func ReadPlan(path string) (*Plan, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(content, &plan); err != nil {
+		return nil, erero.Wrapf(migrationerrors.ErrUsage, "plan file %s is not valid: %v", path, err)
+	}
+	return &plan, nil
+}
+
+// VerifyPlan checks that the current plan has the same versions and checksums as the saved plan
+// Returns ErrDrift describing the first difference
+//
+// VerifyPlan 检查当前计划与保存的计划具有相同的版本和校验和
+// 返回描述第一处差异的 ErrDrift
+func VerifyPlan(saved *Plan, current *Plan) error {
+	for idx := 0; idx < max(len(saved.Steps), len(current.Steps)); idx++ {
+		switch {
+		case idx >= len(current.Steps):
+			return erero.Wrapf(migrationerrors.ErrDrift, "planned version %d is no longer pending", saved.Steps[idx].Version)
+		case idx >= len(saved.Steps):
+			return erero.Wrapf(migrationerrors.ErrDrift, "version %d is pending but not in plan", current.Steps[idx].Version)
+		case saved.Steps[idx].Version != current.Steps[idx].Version:
+			return erero.Wrapf(migrationerrors.ErrDrift, "step %d is version %d but plan has version %d", idx+1, current.Steps[idx].Version, saved.Steps[idx].Version)
+		case saved.Steps[idx].Checksum != current.Steps[idx].Checksum:
+			return erero.Wrapf(migrationerrors.ErrDrift, "script %s checksum %s does not match plan checksum %s", current.Steps[idx].Name, current.Steps[idx].Checksum, saved.Steps[idx].Checksum)
+		}
+	}
+	return nil
+}
+
+// readPlan computes plan of pending up scripts from current database version through source of migration
+//
+// readPlan 通过迁移的源从当前数据库版本计算待处理升级脚本的计划
+func readPlan(migration *migrate.Migrate) (*Plan, error) {
+	sourceDriver, err := utils.SourceDriver(migration)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	version, dirtyFlag, err := currentVersion(migration)
+	if err != nil {
+		return nil, err
+	}
+	if dirtyFlag {
+		return nil, erero.Wrapf(migrationerrors.ErrDirty, "version=%d", version)
+	}
+	return ComputePlan(sourceDriver, version)
+}
+
+// MigratePlan verifies pending up scripts against saved plan, then applies the content saved in the plan
+// golang-migrate reads database version under its lock and runs the plan as source, so nothing outside the plan runs
+// Returns ErrDrift when scripts differ from the plan, or when database version moved off the plan before the lock
+//
+// MigratePlan 根据保存的计划校验待处理升级脚本，然后应用计划中保存的内容
+// golang-migrate 在持有锁时读取数据库版本并以计划作为源执行，因此不会执行计划之外的内容
+// 脚本与计划不同，或在加锁前数据库版本已偏离计划时返回 ErrDrift
+func MigratePlan(cfg *Config, saved *Plan) error {
+	migration, cleanup := cfg.Param.GetMigration()
+	defer cleanup()
+	if err := verifyManifest(cfg, migration); err != nil {
+		return err
+	}
+	current, err := readPlan(migration)
+	if err != nil {
+		return err
+	}
+	if err := VerifyPlan(saved, current); err != nil {
+		return err
+	}
+
+	planMigration, err := newPlanMigration(migration, saved)
+	if err != nil {
+		return err
+	}
+	return utils.WhistleCauseE(migrateUp(cfg, planMigration))
+}
+
+// newPlanMigration creates migration running saved plan as source on the database driver of migration
+// It is not closed, since closing it would close the shared database driver
+//
+// newPlanMigration 创建在迁移的数据库驱动上以保存的计划作为源执行的迁移
+// 不关闭它，因为关闭会同时关闭共享的数据库驱动
+func newPlanMigration(migration *migrate.Migrate, plan *Plan) (*migrate.Migrate, error) {
+	databaseDriver, err := utils.DatabaseDriver(migration)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	planMigration, err := migrate.NewWithInstance("plan", newPlanSource(plan), "database", databaseDriver)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	planMigration.PrefetchMigrations = migration.PrefetchMigrations
+	planMigration.LockTimeout = migration.LockTimeout
+	return planMigration, nil
+}
+
+// planSource serves up scripts saved in plan as golang-migrate source
+// Database version of the plan is served as a version without up script, so golang-migrate accepts it as the start
+// Any other start version is refused with ErrDrift, since the plan was computed from a different database state
+//
+// planSource 将计划中保存的升级脚本作为 golang-migrate 源提供
+// 计划的数据库版本作为没有升级脚本的版本提供，使 golang-migrate 接受其作为起点
+// 其它起始版本以 ErrDrift 拒绝，因为计划是根据不同的数据库状态计算的
+type planSource struct {
+	plan *Plan
+}
+
+// newPlanSource creates source serving up scripts saved in plan
+//
+// newPlanSource 创建提供计划中保存的升级脚本的源
+func newPlanSource(plan *Plan) *planSource {
+	return &planSource{plan: plan}
+}
+
+// Open is not supported, plan source is created from a plan with newPlanSource
+//
+// Open 不受支持，计划源通过 newPlanSource 从计划创建
+func (s *planSource) Open(url string) (source.Driver, error) {
+	return nil, erero.New("plan source can not be opened by url")
+}
+
+// Close does nothing, plan source holds no resources
+//
+// Close 不做任何事，计划源不持有资源
+func (s *planSource) Close() error {
+	return nil
+}
+
+// First returns first planned version, refusing when the plan does not start from no scripts applied
+//
+// First 返回第一个计划版本，计划不是从未应用脚本开始时拒绝
+func (s *planSource) First() (uint, error) {
+	if s.plan.DatabaseVersion >= 0 {
+		return 0, erero.Wrapf(migrationerrors.ErrDrift, "plan starts from version %d but no scripts are applied", s.plan.DatabaseVersion)
+	}
+	if len(s.plan.Steps) == 0 {
+		return 0, &fs.PathError{Op: "first", Path: "plan", Err: fs.ErrNotExist}
+	}
+	return s.plan.Steps[0].Version, nil
+}
+
+// Prev returns version before the given version in the plan
+//
+// Prev 返回计划中给定版本之前的版本
+func (s *planSource) Prev(version uint) (uint, error) {
+	versions := s.versions()
+	idx := slices.Index(versions, version)
+	if idx <= 0 {
+		return 0, &fs.PathError{Op: "prev for version " + strconv.FormatUint(uint64(version), 10), Path: "plan", Err: fs.ErrNotExist}
+	}
+	return versions[idx-1], nil
+}
+
+// Next returns version after the given version in the plan
+//
+// Next 返回计划中给定版本之后的版本
+func (s *planSource) Next(version uint) (uint, error) {
+	versions := s.versions()
+	idx := slices.Index(versions, version)
+	if idx < 0 || idx+1 >= len(versions) {
+		return 0, &fs.PathError{Op: "next for version " + strconv.FormatUint(uint64(version), 10), Path: "plan", Err: fs.ErrNotExist}
+	}
+	return versions[idx+1], nil
+}
+
+// ReadUp returns up script content saved in the plan
+//
+// ReadUp 返回计划中保存的升级脚本内容
+func (s *planSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	for _, step := range s.plan.Steps {
+		if step.Version == version {
+			return io.NopCloser(strings.NewReader(step.Content)), step.Name, nil
+		}
+	}
+	return nil, "", &fs.PathError{Op: "read up for version " + strconv.FormatUint(uint64(version), 10), Path: "plan", Err: fs.ErrNotExist}
+}
+
+// ReadDown serves an empty down script of database version in the plan, so golang-migrate accepts it as the start
+// Versions outside the plan are refused with ErrDrift, the plan has no down scripts to run
+//
+// ReadDown 为计划中的数据库版本提供空的回滚脚本，使 golang-migrate 接受其作为起点
+// 计划之外的版本以 ErrDrift 拒绝，计划中没有可执行的回滚脚本
+func (s *planSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	if int(version) == s.plan.DatabaseVersion {
+		return io.NopCloser(strings.NewReader("")), "", nil
+	}
+	if slices.Contains(s.versions(), version) {
+		return nil, "", &fs.PathError{Op: "read down for version " + strconv.FormatUint(uint64(version), 10), Path: "plan", Err: fs.ErrNotExist}
+	}
+	return nil, "", erero.Wrapf(migrationerrors.ErrDrift, "database version %d is not in plan starting from version %d", version, s.plan.DatabaseVersion)
+}
+
+// versions lists database version of the plan when scripts were applied, then each planned version
+//
+// versions 列出计划的数据库版本（已应用脚本时），然后是每个计划版本
+func (s *planSource) versions() []uint {
+	var versions []uint
+	if s.plan.DatabaseVersion >= 0 {
+		versions = append(versions, uint(s.plan.DatabaseVersion))
+	}
+	for _, step := range s.plan.Steps {
+		versions = append(versions, step.Version)
+	}
+	return versions
+}
+
+// migratePlanFile reads saved plan file and runs MigratePlan with it, showing whether scripts match the plan
+//
+// migratePlanFile 读取保存的计划文件并以其执行 MigratePlan，显示脚本是否与计划一致
+func migratePlanFile(writer io.Writer, cfg *Config, path string) error {
+	saved, err := ReadPlan(path)
+	if err != nil {
+		return err
+	}
+	if err := MigratePlan(cfg.withWriter(writer), saved); err != nil {
+		if errors.Is(err, migrationerrors.ErrDrift) {
+			outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Scripts differ from plan file", path, err.Error())
+		}
+		return err
+	}
+	outputs.ShowMessage(writer, eroticgo.GREEN, "Plan file applied", len(saved.Steps), "planned scripts")
+	return nil
+}
+
+// newPlanCmd creates command listing pending up scripts that `all` would apply, optionally saving plan file
+//
+// newPlanCmd 创建列出 `all` 将应用的待处理升级脚本的命令，可选保存计划文件
+func newPlanCmd(cfg *Config) *cobra.Command {
+	var save string
+	cmd := &cobra.Command{
+		Use:          "plan",
+		Short:        "Show pending scripts that all would apply",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				migration, cleanup := cfg.Param.GetMigration()
+				defer cleanup()
+				plan, err := readPlan(migration)
+				if err != nil {
+					return nil, err
+				}
+				ShowPlan(writer, plan)
+				if save != "" {
+					if err := WritePlan(save, plan); err != nil {
+						return nil, err
+					}
+					outputs.ShowMessage(writer, eroticgo.GREEN, "Saved plan file", save)
+				}
+				return plan, nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&save, "save", "", "save plan file to verify with all --plan")
+	return cmd
+}
@@ -0,0 +1,117 @@
+package cobramigration_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationhistory"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+)
+
+// newSourceDriver opens source driver of scripts DIR
+// newSourceDriver 打开脚本 DIR 的源驱动
+func newSourceDriver(t *testing.T, scriptsInRoot string) source.Driver {
+	sourceDriver := rese.V1(iofs.New(os.DirFS(scriptsInRoot), "."))
+	t.Cleanup(func() {
+		require.NoError(t, sourceDriver.Close())
+	})
+	return sourceDriver
+}
+
+func TestComputePlan(t *testing.T) {
+	scriptsInRoot, _ := newShards(t)
+	sourceDriver := newSourceDriver(t, scriptsInRoot)
+
+	plan, err := cobramigration.ComputePlan(sourceDriver, -1)
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 2)
+	require.Equal(t, uint(1), plan.Steps[0].Version)
+	require.Equal(t, "1_init.up", plan.Steps[0].Name)
+	require.Equal(t, "CREATE TABLE `users` (`id` integer);\n", plan.Steps[0].Content)
+	require.NotEmpty(t, plan.Steps[0].Checksum)
+	require.Equal(t, "2_name.up", plan.Steps[1].Name)
+
+	plan, err = cobramigration.ComputePlan(sourceDriver, 1)
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 1)
+	require.Equal(t, uint(2), plan.Steps[0].Version)
+
+	plan, err = cobramigration.ComputePlan(sourceDriver, 2)
+	require.NoError(t, err)
+	require.Empty(t, plan.Steps)
+}
+
+func TestVerifyPlan(t *testing.T) {
+	scriptsInRoot, _ := newShards(t)
+	sourceDriver := newSourceDriver(t, scriptsInRoot)
+
+	saved, err := cobramigration.ComputePlan(sourceDriver, -1)
+	require.NoError(t, err)
+	current, err := cobramigration.ComputePlan(sourceDriver, -1)
+	require.NoError(t, err)
+	require.NoError(t, cobramigration.VerifyPlan(saved, current))
+
+	current, err = cobramigration.ComputePlan(sourceDriver, 1)
+	require.NoError(t, err)
+	require.ErrorIs(t, cobramigration.VerifyPlan(saved, current), migrationerrors.ErrDrift)
+
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00002_name.up.sql"), []byte("ALTER TABLE `users` ADD COLUMN `nick` text;\n"), 0644))
+	current, err = cobramigration.ComputePlan(sourceDriver, -1)
+	require.NoError(t, err)
+	require.ErrorIs(t, cobramigration.VerifyPlan(saved, current), migrationerrors.ErrDrift)
+}
+
+func TestNewMigrateCmd_Plan(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot}
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+
+	runMigrateCmd(t, cfg, "plan", "--save", planFile)
+	plan, err := cobramigration.ReadPlan(planFile)
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 2)
+
+	// Editing a planned script after saving the plan makes all refuse to run
+	// 保存计划后修改计划中的脚本会使 all 拒绝执行
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00002_name.up.sql"), []byte("ALTER TABLE `users` ADD COLUMN `nick` text;\n"), 0644))
+	require.ErrorIs(t, runMigrateCmdE(cfg, "all", "--plan", planFile), migrationerrors.ErrDrift)
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, -1, version)
+
+	runMigrateCmd(t, cfg, "plan", "--save", planFile)
+	runMigrateCmd(t, cfg, "all", "--plan", planFile)
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, 2, version)
+}
+
+func TestMigratePlan(t *testing.T) {
+	_, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{Param: shards[0].Param, HistoryTable: migrationhistory.DefaultHistoryTable}
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+
+	// Plan is read through source of migration, so ScriptsInRoot is not needed
+	// 计划通过迁移的源读取，因此不需要 ScriptsInRoot
+	runMigrateCmd(t, cfg, "inc")
+	runMigrateCmd(t, cfg, "plan", "--save", planFile)
+	saved, err := cobramigration.ReadPlan(planFile)
+	require.NoError(t, err)
+	require.Equal(t, 1, saved.DatabaseVersion)
+	require.Len(t, saved.Steps, 1)
+
+	// Database version is checked again under the migrate lock, a plan from another start is refused
+	// 在迁移锁下再次检查数据库版本，从其它起点计算的计划被拒绝
+	saved.DatabaseVersion = 0
+	require.ErrorIs(t, cobramigration.MigratePlan(cfg, saved), migrationerrors.ErrDrift)
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+
+	runMigrateCmd(t, cfg, "all", "--plan", planFile)
+	version, _ = readVersion(t, cfg.Param)
+	require.Equal(t, 2, version)
+}
@@ -0,0 +1,118 @@
+package cobramigration
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/internal/utils"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationhistory"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+	"gorm.io/gorm"
+)
+
+// applyOutOfOrder runs up script of an older version through golang-migrate, holding the migrate lock and keeping the database version
+// A failure leaves the database dirty at its version, and the failure record in history tells repair which older version failed
+//
+// applyOutOfOrder 通过 golang-migrate 执行较旧版本的升级脚本，持有迁移锁并保持数据库版本不变
+// 失败时数据库在其版本上变脏，历史中的失败记录告诉 repair 是哪个较旧版本失败
+func applyOutOfOrder(cfg *Config, migration *migrate.Migrate, version uint) error {
+	name, err := findScriptName(cfg.ScriptsInRoot, version, string(source.Up))
+	if err != nil {
+		return erero.Wro(err)
+	}
+	script, err := source.DefaultParse(name)
+	if err != nil {
+		return erero.Wro(err)
+	}
+	content, err := os.ReadFile(filepath.Join(cfg.ScriptsInRoot, name))
+	if err != nil {
+		return erero.Wro(err)
+	}
+	databaseVersion, _, err := currentVersion(migration)
+	if err != nil {
+		return err
+	}
+	step, err := migrate.NewMigration(io.NopCloser(bytes.NewReader(content)), script.Identifier, version, databaseVersion)
+	if err != nil {
+		return erero.Wro(err)
+	}
+	startedAt := time.Now()
+	cause := observeStep(cfg, migration, func() error {
+		return migration.Run(step)
+	})
+	if isRefused(cause) {
+		return cause
+	}
+	return saveStepRecord(cfg, newHistoryRecord(cfg, version, string(source.Up), startedAt, cause), cause)
+}
+
+// findOutOfOrderFailure finds the older version whose out-of-order step left the database dirty at databaseVersion
+// The latest history record tells it, since out-of-order steps keep the database version
+//
+// findOutOfOrderFailure 查找其乱序步骤使数据库在 databaseVersion 上变脏的较旧版本
+// 乱序步骤保持数据库版本不变，因此由最新的历史记录得知
+func findOutOfOrderFailure(cfg *Config, db *gorm.DB, databaseVersion uint) (uint, bool, error) {
+	if cfg.HistoryTable == "" {
+		return 0, false, nil
+	}
+	records, err := migrationhistory.ReadHistory(db, cfg.HistoryTable, 1)
+	if err != nil {
+		return 0, false, erero.Wro(err)
+	}
+	if len(records) == 0 {
+		return 0, false, nil
+	}
+	record := records[0]
+	if record.Outcome != migrationhistory.OutcomeFailure || record.Direction != string(source.Up) || record.Version >= databaseVersion {
+		return 0, false, nil
+	}
+	return record.Version, true, nil
+}
+
+// MigrateOutOfOrder applies unapplied older versions recorded as missing in history, then runs all pending migrations
+// Needs HistoryTable and ScriptsInRoot in config, out-of-order versions run as hooked steps too
+// Each out-of-order version is shown before applying it, into the command writer or os.Stderr
+//
+// MigrateOutOfOrder 先应用历史中缺失的较旧版本，然后执行所有待处理迁移
+// 需要在配置中设置 HistoryTable 和 ScriptsInRoot，乱序版本同样作为带钩子的步骤执行
+// 每个乱序版本在应用前都会显示，写入命令的 writer 或 os.Stderr
+func MigrateOutOfOrder(cfg *Config) error {
+	if cfg.HistoryTable == "" || cfg.ScriptsInRoot == "" {
+		return erero.Wrap(migrationerrors.ErrUsage, "out-of-order mode needs HistoryTable and ScriptsInRoot in config")
+	}
+	migration, cleanup := cfg.Param.GetMigration()
+	defer cleanup()
+	if err := verifyManifest(cfg, migration); err != nil {
+		return err
+	}
+	db, _ := cfg.Param.GetDB()
+	return utils.WhistleCauseE(runStepwise(cfg, func() error {
+		version, dirtyFlag, err := currentVersion(migration)
+		if err != nil {
+			return err
+		}
+		if version >= 0 && !dirtyFlag {
+			olderVersions, err := migrationhistory.FindOutOfOrder(db, cfg.HistoryTable, cfg.ScriptsInRoot, uint(version))
+			if err != nil {
+				return erero.Wro(err)
+			}
+			for _, olderVersion := range olderVersions {
+				outputs.ShowMessage(cfg.noteWriter(), eroticgo.AMBER, "Apply out-of-order version", olderVersion)
+				if err := runHookedStep(cfg, olderVersion, string(source.Up), true, func() error {
+					return applyOutOfOrder(cfg, migration, olderVersion)
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		return stepUp(cfg, migration)
+	}))
+}
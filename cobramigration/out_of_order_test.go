@@ -0,0 +1,99 @@
+package cobramigration_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/migrationhistory"
+	"github.com/go-xlan/go-migrate/migrationstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateOutOfOrder(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00005_orders.up.sql"), []byte("CREATE TABLE `orders` (`id` integer);\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00005_orders.down.sql"), []byte("DROP TABLE `orders`;\n"), 0644))
+	cfg := &cobramigration.Config{
+		Param:         shards[0].Param,
+		ScriptsInRoot: scriptsInRoot,
+		HistoryTable:  migrationhistory.DefaultHistoryTable,
+	}
+	runMigrateCmd(t, cfg, "all")
+
+	// A branch merged late brings version 3, older than database version 5
+	// 较晚合并的分支带来版本 3，早于数据库版本 5
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00003_items.up.sql"), []byte("CREATE TABLE `items` (`id` integer);\nCREATE INDEX `idx_items_id` ON `items`(`id`);\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00003_items.down.sql"), []byte("DROP TABLE `items`;\n"), 0644))
+	runMigrateCmd(t, cfg, "all")
+
+	{
+		migration, cleanup := cfg.Param.GetMigration()
+		db, _ := cfg.Param.GetDB()
+		status, err := migrationstate.GetStatusWithHistory(db, migration, scriptsInRoot, nil, cfg.HistoryTable)
+		require.NoError(t, err)
+		migrationstate.ShowStatus(status)
+		require.Equal(t, []uint{3}, status.OutOfOrderVersions)
+		require.False(t, status.HasUpToDate)
+		cleanup()
+	}
+
+	cmd := cobramigration.NewMigrateCmdWithConfig(cfg)
+	buffer := &bytes.Buffer{}
+	cmd.SetOut(buffer)
+	cmd.SetArgs([]string{"all", "--out-of-order"})
+	require.NoError(t, cmd.Execute())
+	require.Contains(t, buffer.String(), "Apply out-of-order version")
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, 5, version)
+
+	db, cleanup := cfg.Param.GetDB()
+	defer cleanup()
+	require.True(t, db.Migrator().HasTable("items"))
+	versions, err := migrationhistory.FindOutOfOrder(db, cfg.HistoryTable, scriptsInRoot, 5)
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
+
+func TestMigrateOutOfOrder_Repair(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	cfg := &cobramigration.Config{
+		Param:         shards[0].Param,
+		ScriptsInRoot: scriptsInRoot,
+		HistoryTable:  migrationhistory.DefaultHistoryTable,
+	}
+	runMigrateCmd(t, cfg, "all")
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00005_orders.up.sql"), []byte("CREATE TABLE `orders` (`id` integer);\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00005_orders.down.sql"), []byte("DROP TABLE `orders`;\n"), 0644))
+	runMigrateCmd(t, cfg, "all")
+
+	// Older version 3 fails on its second statement, leaving the database dirty at version 5
+	// 较旧版本 3 在第二条语句失败，使数据库在版本 5 上变脏
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00003_items.up.sql"), []byte("CREATE TABLE `items` (`id` integer);\nCREATE TABLE `orders` (`id` integer);\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00003_items.down.sql"), []byte("DROP TABLE `items`;\n"), 0644))
+	require.Error(t, runMigrateCmdE(cfg, "all", "--out-of-order"))
+	version, dirtyFlag := readVersion(t, cfg.Param)
+	require.Equal(t, 5, version)
+	require.True(t, dirtyFlag)
+
+	runMigrateCmd(t, cfg, "repair", "--action", cobramigration.RepairForceForward, "--yes")
+	version, dirtyFlag = readVersion(t, cfg.Param)
+	require.Equal(t, 5, version)
+	require.False(t, dirtyFlag)
+
+	// Forced record marks version 3 as applied, so out-of-order mode does not run it again
+	// 强制记录将版本 3 标记为已应用，因此乱序模式不会再次执行它
+	db, cleanup := cfg.Param.GetDB()
+	defer cleanup()
+	versions, err := migrationhistory.FindOutOfOrder(db, cfg.HistoryTable, scriptsInRoot, 5)
+	require.NoError(t, err)
+	require.Empty(t, versions)
+	records, err := migrationhistory.ReadHistory(db, cfg.HistoryTable, 2)
+	require.NoError(t, err)
+	require.Equal(t, migrationhistory.OutcomeForced, records[0].Outcome)
+	require.Equal(t, uint(3), records[0].Version)
+	require.Equal(t, migrationhistory.OutcomeFailure, records[1].Outcome)
+	runMigrateCmd(t, cfg, "all", "--out-of-order")
+}
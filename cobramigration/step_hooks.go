@@ -0,0 +1,116 @@
+package cobramigration
+
+import (
+	"slices"
+	"time"
+
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/migrationhistory"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+)
+
+// Hooks contains functions called around a migration run and around each step of it, nil functions are skipped
+// Direction is "up" or "down", the same as migrationhistory.HistoryRecord.Direction
+// A hook error aborts the remaining steps, AfterStep and AfterAll still run once their Before hook ran
+//
+// Hooks 包含在迁移运行前后以及每个步骤前后调用的函数，nil 函数会被跳过
+// 方向为 "up" 或 "down"，与 migrationhistory.HistoryRecord.Direction 相同
+// 钩子错误会中止剩余步骤，对应的 Before 钩子运行过后 AfterStep 和 AfterAll 仍会运行
+type Hooks struct {
+	BeforeAll  func() error                                                                  // Called before the first step // 在第一个步骤之前调用
+	BeforeStep func(version uint, direction string) error                                    // Called before each step // 在每个步骤之前调用
+	AfterStep  func(version uint, direction string, duration time.Duration, err error) error // Called after each step with its error // 在每个步骤之后携带其错误调用
+	AfterAll   func(err error) error                                                         // Called after the last step with error of the run // 在最后一个步骤之后携带运行的错误调用
+}
+
+// isStepwise reports whether migrations run one step at a time, as history, hooks and observer need
+//
+// isStepwise 判断迁移是否逐步执行，历史、钩子和观察者需要逐步执行
+func isStepwise(cfg *Config) bool {
+	return cfg.HistoryTable != "" || cfg.Hooks != nil || cfg.Observer != nil
+}
+
+// runWithHooks calls BeforeAll, runs the steps, then calls AfterAll with the error of the steps
+// Hooks need ScriptsInRoot in config to tell the version of each step before it runs
+//
+// runWithHooks 调用 BeforeAll，执行步骤，然后携带步骤的错误调用 AfterAll
+// 钩子需要在配置中设置 ScriptsInRoot，以便在每个步骤运行前得知其版本
+func runWithHooks(cfg *Config, run func() error) error {
+	if cfg.Hooks == nil {
+		return run()
+	}
+	if cfg.ScriptsInRoot == "" {
+		return erero.New("hooks need ScriptsInRoot in config")
+	}
+	if cfg.Hooks.BeforeAll != nil {
+		if err := cfg.Hooks.BeforeAll(); err != nil {
+			return erero.Wrap(err, "before-all hook failed")
+		}
+	}
+	cause := run()
+	if cfg.Hooks.AfterAll != nil {
+		if err := cfg.Hooks.AfterAll(cause); err != nil {
+			if cause != nil && !isNothingToRun(cause) {
+				outputs.ShowMessage(cfg.noteWriter(), eroticgo.RED, "after-all hook failed:", err.Error())
+				return cause
+			}
+			return erero.Wrap(err, "after-all hook failed")
+		}
+	}
+	return cause
+}
+
+// runHookedStep calls BeforeStep, runs the step, then calls AfterStep with the duration and error of the step
+// Runs the step without step hooks when the step version is unknown, so golang-migrate reports why nothing ran
+//
+// runHookedStep 调用 BeforeStep，执行步骤，然后携带步骤的耗时和错误调用 AfterStep
+// 步骤版本未知时不调用步骤钩子直接执行，由 golang-migrate 报告未执行的原因
+func runHookedStep(cfg *Config, version uint, direction string, known bool, step func() error) error {
+	if cfg.Hooks == nil || !known {
+		return step()
+	}
+	if cfg.Hooks.BeforeStep != nil {
+		if err := cfg.Hooks.BeforeStep(version, direction); err != nil {
+			return erero.Wrapf(err, "before-step hook of %s version %d failed", direction, version)
+		}
+	}
+	startedAt := time.Now()
+	cause := step()
+	if cfg.Hooks.AfterStep != nil {
+		if err := cfg.Hooks.AfterStep(version, direction, time.Since(startedAt), cause); err != nil {
+			if cause != nil {
+				outputs.ShowMessage(cfg.noteWriter(), eroticgo.RED, "after-step hook failed:", err.Error())
+				return cause
+			}
+			return erero.Wrapf(err, "after-step hook of %s version %d failed", direction, version)
+		}
+	}
+	return cause
+}
+
+// stepTarget tells the version and direction that one step up (+1) or down (-1) from database version runs
+// Known is false when no script is there to run or the database is dirty
+//
+// stepTarget 给出从数据库版本升级（+1）或回滚（-1）一步所执行的版本和方向
+// 没有可执行的脚本或数据库为脏状态时 known 为 false
+func stepTarget(cfg *Config, databaseVersion int, dirtyFlag bool, sign int) (uint, string, bool) {
+	if cfg.Hooks == nil || dirtyFlag {
+		return 0, "", false
+	}
+	if sign < 0 {
+		return uint(max(databaseVersion, 0)), string(source.Down), databaseVersion >= 0
+	}
+	scriptVersions, err := migrationhistory.ScanScriptVersions(cfg.ScriptsInRoot)
+	if err != nil {
+		return 0, "", false
+	}
+	idx := slices.IndexFunc(scriptVersions, func(version uint) bool {
+		return int(version) > databaseVersion
+	})
+	if idx < 0 {
+		return 0, "", false
+	}
+	return scriptVersions[idx], string(source.Up), true
+}
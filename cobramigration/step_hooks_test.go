@@ -0,0 +1,108 @@
+package cobramigration_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// newRecordingHooks creates hooks appending each call into calls, BeforeStep fails on failVersion
+// newRecordingHooks 创建将每次调用追加到 calls 的钩子，BeforeStep 在 failVersion 上失败
+func newRecordingHooks(calls *[]string, failVersion uint) *cobramigration.Hooks {
+	return &cobramigration.Hooks{
+		BeforeAll: func() error {
+			*calls = append(*calls, "before-all")
+			return nil
+		},
+		BeforeStep: func(version uint, direction string) error {
+			*calls = append(*calls, fmt.Sprintf("before-step %d %s", version, direction))
+			if version == failVersion {
+				return errors.New("replication consumer not paused")
+			}
+			return nil
+		},
+		AfterStep: func(version uint, direction string, duration time.Duration, err error) error {
+			*calls = append(*calls, fmt.Sprintf("after-step %d %s %v", version, direction, err))
+			return nil
+		},
+		AfterAll: func(err error) error {
+			*calls = append(*calls, fmt.Sprintf("after-all %v", err != nil))
+			return nil
+		},
+	}
+}
+
+func TestNewMigrateCmd_Hooks(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	var calls []string
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot, Hooks: newRecordingHooks(&calls, 0)}
+
+	runMigrateCmd(t, cfg, "all")
+	require.Equal(t, []string{
+		"before-all",
+		"before-step 1 up",
+		"after-step 1 up <nil>",
+		"before-step 2 up",
+		"after-step 2 up <nil>",
+		"after-all false",
+	}, calls)
+
+	calls = nil
+	runMigrateCmd(t, cfg, "dec")
+	require.Equal(t, []string{
+		"before-all",
+		"before-step 2 down",
+		"after-step 2 down <nil>",
+		"after-all false",
+	}, calls)
+	version, _ := readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+}
+
+func TestNewMigrateCmd_HooksAbort(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "main")
+	var calls []string
+	cfg := &cobramigration.Config{Param: shards[0].Param, ScriptsInRoot: scriptsInRoot, Hooks: newRecordingHooks(&calls, 2)}
+
+	// BeforeStep error on version 2 aborts the remaining steps, AfterAll still runs
+	// 版本 2 上的 BeforeStep 错误会中止剩余步骤，AfterAll 仍会运行
+	require.Error(t, runMigrateCmdE(cfg, "all"))
+	require.Equal(t, []string{
+		"before-all",
+		"before-step 1 up",
+		"after-step 1 up <nil>",
+		"before-step 2 up",
+		"after-all true",
+	}, calls)
+	version, dirtyFlag := readVersion(t, cfg.Param)
+	require.Equal(t, 1, version)
+	require.False(t, dirtyFlag)
+}
+
+func TestNewMigrateCmd_HooksFailAfterFailedStep(t *testing.T) {
+	scriptsInRoot, shards := newShards(t, "broken")
+	cfg := &cobramigration.Config{
+		Param:         shards[0].Param,
+		ScriptsInRoot: scriptsInRoot,
+		Hooks: &cobramigration.Hooks{
+			AfterStep: func(version uint, direction string, duration time.Duration, err error) error {
+				return errors.New("notify failed")
+			},
+		},
+	}
+
+	// The step error is returned, the hook error is written into the command writer
+	// 返回步骤的错误，钩子的错误写入命令的 writer
+	cmd := cobramigration.NewMigrateCmdWithConfig(cfg)
+	stderr := &bytes.Buffer{}
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(stderr)
+	cmd.SetArgs([]string{"all", "--output", "json"})
+	require.ErrorContains(t, cmd.Execute(), "already exists")
+	require.Contains(t, stderr.String(), "after-step hook failed")
+}
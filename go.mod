@@ -14,7 +14,6 @@ require (
 	github.com/yyle88/eroticgo v0.0.5
 	github.com/yyle88/must v0.0.29
 	github.com/yyle88/neatjson v0.0.13
-	github.com/yyle88/osexistpath v0.0.19
 	github.com/yyle88/rese v0.0.12
 	github.com/yyle88/runpath v1.0.25
 	github.com/yyle88/tern v0.0.10
@@ -44,7 +43,9 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.32 // indirect
 	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
+	github.com/rogpeppe/go-internal v1.14.1 // indirect
 	github.com/spf13/pflag v1.0.10 // indirect
+	github.com/yyle88/formatgo v1.0.28 // indirect
 	github.com/yyle88/mutexmap v1.0.15 // indirect
 	github.com/yyle88/sure v0.0.42 // indirect
 	github.com/yyle88/syntaxgo v0.0.54 // indirect
@@ -54,5 +55,6 @@ require (
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/term v0.38.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
+	golang.org/x/tools v0.40.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
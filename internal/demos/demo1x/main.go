@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-xlan/go-migrate/cobramigration"
 	"github.com/go-xlan/go-migrate/internal/demos/demo1x/internal/models"
+	"github.com/go-xlan/go-migrate/migrationerrors"
 	"github.com/go-xlan/go-migrate/migrationparam"
 	"github.com/go-xlan/go-migrate/migrationstate"
 	"github.com/go-xlan/go-migrate/newmigrate"
@@ -75,7 +76,10 @@ func main() {
 		Options: newscripts.NewOptions(scriptsInRoot),
 		Objects: objects,
 	}))
-	rootCmd.AddCommand(cobramigration.NewMigrateCmd(param))
+	rootCmd.AddCommand(cobramigration.NewMigrateCmdWithConfig(&cobramigration.Config{
+		Param:         param,
+		ScriptsInRoot: scriptsInRoot,
+	}))
 	rootCmd.AddCommand(previewmigrate.NewPreviewCmd(param, scriptsInRoot))
 	rootCmd.AddCommand(migrationstate.NewStatusCmd(&migrationstate.Config{
 		Param:       param,
@@ -83,7 +87,7 @@ func main() {
 		Objects:     objects,
 	}))
 
-	must.Done(rootCmd.Execute())
+	os.Exit(migrationerrors.Execute(rootCmd))
 }
 
 func randomSample(objects ...interface{}) any {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-xlan/go-migrate/cobramigration"
 	"github.com/go-xlan/go-migrate/internal/demos/demo2x/internal/models"
+	"github.com/go-xlan/go-migrate/migrationerrors"
 	"github.com/go-xlan/go-migrate/migrationparam"
 	"github.com/go-xlan/go-migrate/migrationstate"
 	"github.com/go-xlan/go-migrate/newmigrate"
@@ -76,7 +77,10 @@ func main() {
 		Options: newscripts.NewOptions(scriptsInRoot),
 		Objects: objects,
 	}))
-	rootCmd.AddCommand(cobramigration.NewMigrateCmd(param))
+	rootCmd.AddCommand(cobramigration.NewMigrateCmdWithConfig(&cobramigration.Config{
+		Param:         param,
+		ScriptsInRoot: scriptsInRoot,
+	}))
 	rootCmd.AddCommand(previewmigrate.NewPreviewCmd(param, scriptsInRoot))
 	rootCmd.AddCommand(migrationstate.NewStatusCmd(&migrationstate.Config{
 		Param:       param,
@@ -84,7 +88,7 @@ func main() {
 		Objects:     objects,
 	}))
 
-	must.Done(rootCmd.Execute())
+	os.Exit(migrationerrors.Execute(rootCmd))
 }
 
 func randomSample(objects ...interface{}) any {
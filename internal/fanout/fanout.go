@@ -0,0 +1,132 @@
+// Package fanout: Bounded concurrency runner used to migrate many targets at once
+// Runs one task each item, recovers panics into errors and keeps results in item sequence
+// Supports continue-on-error and stop-on-first-failure modes
+//
+// fanout: 用于同时迁移多个目标的有界并发执行器
+// 每个条目执行一个任务，将 panic 恢复为错误，并按条目顺序保存结果
+// 支持出错继续和首次失败即停止两种模式
+package fanout
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yyle88/erero"
+)
+
+// ErrSkipped reports that the item was not run because an earlier item failed
+//
+// ErrSkipped 表示由于之前的条目失败，该条目未被执行
+var ErrSkipped = errors.New("skipped after failure")
+
+// Options configures how items are run
+//
+// Options 配置条目的执行方式
+type Options struct {
+	Parallelism int  // Max count of items running at once, treated as 1 when not positive // 同时执行的最大条目数，非正数时视为 1
+	StopOnError bool // Skip items not yet started once any item fails // 任一条目失败后跳过尚未开始的条目
+}
+
+// Result contains the outcome of running one item
+//
+// Result 包含单个条目的执行结果
+type Result[T any] struct {
+	Item     T             // The item // 条目
+	Err      error         // Error of the task, ErrSkipped when not run // 任务的错误，未执行时为 ErrSkipped
+	Duration time.Duration // Time the task took // 任务耗时
+}
+
+// Run runs task on each item with bounded concurrency and returns results in item sequence
+// Panics inside the task are recovered and reported as errors
+//
+// Run 以有界并发在每个条目上执行任务，并按条目顺序返回结果
+// 任务中的 panic 会被恢复并作为错误报告
+func Run[T any](items []T, options *Options, task func(item T) error) []*Result[T] {
+	parallelism := max(1, options.Parallelism)
+	results := make([]*Result[T], len(items))
+
+	var mutex sync.Mutex
+	var failed bool
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, parallelism)
+	for idx, item := range items {
+		semaphore <- struct{}{}
+
+		mutex.Lock()
+		skip := failed && options.StopOnError
+		mutex.Unlock()
+		if skip {
+			<-semaphore
+			results[idx] = &Result[T]{Item: item, Err: ErrSkipped}
+			continue
+		}
+
+		wg.Add(1)
+		go func(idx int, item T) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			startTime := time.Now()
+			err := runTask(item, task)
+			results[idx] = &Result[T]{Item: item, Err: err, Duration: time.Since(startTime)}
+			if err != nil {
+				mutex.Lock()
+				failed = true
+				mutex.Unlock()
+			}
+		}(idx, item)
+	}
+	wg.Wait()
+	return results
+}
+
+// runTask runs task and converts panic into error
+//
+// runTask 执行任务并将 panic 转换为错误
+func runTask[T any](item T, task func(item T) error) (err error) {
+	defer func() {
+		if cause := recover(); cause != nil {
+			if causeErr, ok := cause.(error); ok {
+				err = erero.Wro(causeErr)
+			} else {
+				err = erero.New(fmt.Sprint(cause))
+			}
+		}
+	}()
+	return task(item)
+}
+
+// CountFailures counts results with errors, skipped ones included
+//
+// CountFailures 统计带有错误的结果数量，包括被跳过的
+func CountFailures[T any](results []*Result[T]) int {
+	var count int
+	for _, result := range results {
+		if result.Err != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// CheckFailures returns error when any item failed, counting failures with CountFailures
+// Wraps the first error that is not ErrSkipped, so typed errors such as ErrDirty still match with errors.Is
+//
+// CheckFailures 当任一条目失败时返回错误，使用 CountFailures 统计失败数量
+// 包装第一个非 ErrSkipped 的错误，使 ErrDirty 等类型化错误仍可通过 errors.Is 匹配
+func CheckFailures[T any](results []*Result[T], noun string) error {
+	failures := CountFailures(results)
+	if failures == 0 {
+		return nil
+	}
+	idx := slices.IndexFunc(results, func(result *Result[T]) bool {
+		return result.Err != nil && !errors.Is(result.Err, ErrSkipped)
+	})
+	if idx < 0 {
+		idx = slices.IndexFunc(results, func(result *Result[T]) bool { return result.Err != nil })
+	}
+	return errors.Wrapf(results[idx].Err, "%d of %d %s failed", failures, len(results), noun)
+}
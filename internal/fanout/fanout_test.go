@@ -0,0 +1,65 @@
+package fanout_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-xlan/go-migrate/internal/fanout"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	var running, peak atomic.Int32
+	items := []int{1, 2, 3, 4, 5, 6}
+	results := fanout.Run(items, &fanout.Options{Parallelism: 2}, func(item int) error {
+		peak.Store(max(peak.Load(), running.Add(1)))
+		defer running.Add(-1)
+		time.Sleep(10 * time.Millisecond)
+		switch item {
+		case 3:
+			return errors.New("wrong")
+		case 5:
+			panic("boom")
+		}
+		return nil
+	})
+	require.Len(t, results, len(items))
+	require.LessOrEqual(t, peak.Load(), int32(2))
+	for idx, result := range results {
+		require.Equal(t, items[idx], result.Item)
+	}
+	require.Error(t, results[2].Err)
+	require.ErrorContains(t, results[4].Err, "boom")
+	require.NoError(t, results[5].Err)
+	require.Equal(t, 2, fanout.CountFailures(results))
+}
+
+func TestRun_StopOnError(t *testing.T) {
+	results := fanout.Run([]int{1, 2, 3}, &fanout.Options{Parallelism: 1, StopOnError: true}, func(item int) error {
+		if item == 1 {
+			return errors.New("wrong")
+		}
+		return nil
+	})
+	require.Error(t, results[0].Err)
+	require.ErrorIs(t, results[1].Err, fanout.ErrSkipped)
+	require.ErrorIs(t, results[2].Err, fanout.ErrSkipped)
+}
+
+func TestCheckFailures(t *testing.T) {
+	errTyped := errors.New("typed")
+	results := fanout.Run([]int{1, 2, 3}, &fanout.Options{Parallelism: 1, StopOnError: true}, func(item int) error {
+		if item == 1 {
+			return errors.Wrap(errTyped, "item 1")
+		}
+		return nil
+	})
+	err := fanout.CheckFailures(results, "items")
+	require.ErrorIs(t, err, errTyped)
+	require.Contains(t, err.Error(), "3 of 3 items failed")
+
+	results = fanout.Run([]int{1, 2}, &fanout.Options{}, func(item int) error { return nil })
+	require.NoError(t, fanout.CheckFailures(results, "items"))
+}
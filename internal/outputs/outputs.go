@@ -0,0 +1,204 @@
+// Package outputs: Shared --output flag switching commands between colored text and JSON
+// In JSON mode each command writes one JSON object to stdout and its text output goes to stderr
+//
+// outputs: 在彩色文本和 JSON 之间切换命令输出的共享 --output 参数
+// JSON 模式下每个命令向 stdout 写入一个 JSON 对象，文本输出写入 stderr
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+	"github.com/yyle88/must"
+	"github.com/yyle88/zaplog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FlagName is the name of the persistent output flag
+//
+// FlagName 是持久输出参数的名称
+const FlagName = "output"
+
+const (
+	FormatText = "text" // Colored text, the default // 彩色文本，默认值
+	FormatJSON = "json" // One JSON object without ANSI codes // 不含 ANSI 码的单个 JSON 对象
+)
+
+// ErrorObject is the JSON object written when a command fails without a result
+//
+// ErrorObject 是命令失败且没有结果时写出的 JSON 对象
+type ErrorObject struct {
+	Error    string // Error message // 错误信息
+	ExitCode int    // Process exit code of the error // 错误对应的进程退出码
+}
+
+// AddFlag registers persistent --output flag on command unless it is already there
+//
+// AddFlag 在命令上注册持久的 --output 参数，已存在时跳过
+func AddFlag(cmd *cobra.Command) {
+	if cmd.PersistentFlags().Lookup(FlagName) == nil {
+		cmd.PersistentFlags().String(FlagName, FormatText, "output format: text or json")
+	}
+}
+
+// isJSON reports whether the command runs in JSON mode, returning ErrUsage on unknown formats
+//
+// isJSON 判断命令是否以 JSON 模式运行，遇到未知格式时返回 ErrUsage
+func isJSON(cmd *cobra.Command) (bool, error) {
+	flag := cmd.Flag(FlagName)
+	if flag == nil {
+		return false, nil
+	}
+	switch flag.Value.String() {
+	case FormatText:
+		return false, nil
+	case FormatJSON:
+		return true, nil
+	default:
+		return false, erero.Wrapf(migrationerrors.ErrUsage, "unknown output format %q, use %s or %s", flag.Value.String(), FormatText, FormatJSON)
+	}
+}
+
+// RunE runs task with the writer of its text output and returns its error, recovering unexpected panics into errors so commands exit with codes
+// Text goes to the command stdout, or to the command stderr in JSON mode, where the result is written as one JSON object
+// In JSON mode zaplog logs go to the command stderr too while the task runs
+// The JSON object holds Error and ExitCode too when the task fails, and is ErrorObject when the task fails without a result
+//
+// RunE 将文本输出的 writer 传给任务执行并返回其错误，将意外的 panic 恢复为错误，使命令以退出码结束
+// 文本写入命令的 stdout，JSON 模式下写入命令的 stderr，此时结果写为单个 JSON 对象
+// JSON 模式下任务运行期间 zaplog 日志同样写入命令的 stderr
+// 任务失败时 JSON 对象同时包含 Error 和 ExitCode，任务失败且没有结果时为 ErrorObject
+func RunE(cmd *cobra.Command, task func(writer io.Writer) (any, error)) error {
+	jsonMode, err := isJSON(cmd)
+	if err != nil {
+		return err
+	}
+	if !jsonMode {
+		_, err := runTask(cmd.OutOrStdout(), task)
+		return err
+	}
+
+	// Text and zaplog logs go to stderr so stdout only holds the JSON object
+	// 文本和 zaplog 日志写入 stderr，使 stdout 只包含 JSON 对象
+	restore := redirectLogs(cmd.ErrOrStderr())
+	result, err := runTask(cmd.ErrOrStderr(), task)
+	restore()
+	writer := cmd.OutOrStdout()
+	switch {
+	case !isNil(result) && err != nil:
+		writeJSON(writer, withError(result, err))
+	case !isNil(result):
+		writeJSON(writer, result)
+	case err != nil:
+		writeJSON(writer, newErrorObject(err))
+	default:
+		writeJSON(writer, struct{}{})
+	}
+	return err
+}
+
+// redirectLogs points zaplog at writer, keeping the level of the configured logger, and returns function restoring it
+// zaplog writes to stdout by default, which would mix its lines into the JSON object
+//
+// redirectLogs 将 zaplog 指向 writer，保留已配置日志的级别，并返回恢复它的函数
+// zaplog 默认写入 stdout，会将其日志行混入 JSON 对象
+func redirectLogs(writer io.Writer) func() {
+	previous := zaplog.LOG
+	encoderConfig := zaplog.NewZapConfig(true, "DEBUG", nil).EncoderConfig
+	zaplog.SetLog(previous.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.AddSync(writer), core)
+	})))
+	return func() {
+		zaplog.SetLog(previous)
+	}
+}
+
+// newErrorObject creates ErrorObject of err
+//
+// newErrorObject 创建 err 的 ErrorObject
+func newErrorObject(err error) *ErrorObject {
+	return &ErrorObject{Error: err.Error(), ExitCode: migrationerrors.ExitCode(err)}
+}
+
+// withError adds Error and ExitCode of err into the JSON object of result
+// Results not encoding as JSON objects are kept under Result
+//
+// withError 将 err 的 Error 和 ExitCode 加入结果的 JSON 对象
+// 不能编码为 JSON 对象的结果放在 Result 下
+func withError(result any, err error) any {
+	errorObject := newErrorObject(err)
+	var object map[string]any
+	if data, cause := json.Marshal(result); cause != nil || json.Unmarshal(data, &object) != nil || object == nil {
+		return &struct {
+			Result any
+			*ErrorObject
+		}{Result: result, ErrorObject: errorObject}
+	}
+	object["Error"] = errorObject.Error
+	object["ExitCode"] = errorObject.ExitCode
+	return object
+}
+
+// ShowMessage writes messages between separator lines in color, the same as eroticgo ShowMessage but into writer
+//
+// ShowMessage 以颜色将消息写在分隔线之间，与 eroticgo ShowMessage 相同但写入 writer
+func ShowMessage(writer io.Writer, color eroticgo.COLOR, msgs ...any) {
+	separator := color.Sprint("----------------------------------------")
+	fmt.Fprintln(writer, separator)
+	fmt.Fprintln(writer, separator)
+	fmt.Fprintln(writer, color.Sprint(msgs...))
+	fmt.Fprintln(writer, separator)
+	fmt.Fprintln(writer, separator)
+}
+
+// runTask runs task and converts panics into errors, keeping error values so errors.Is still works
+// Tasks return typed errors, the recover is a last-resort guard logging the stack of an unexpected panic
+//
+// runTask 执行任务并将 panic 转换为错误，保留错误值使 errors.Is 仍然有效
+// 任务返回类型化错误，recover 只是最后的保护，会记录意外 panic 的调用栈
+func runTask(writer io.Writer, task func(writer io.Writer) (any, error)) (result any, err error) {
+	defer func() {
+		if cause := recover(); cause != nil {
+			zaplog.LOG.Error("task panicked", zap.Any("cause", cause), zap.ByteString("stack", debug.Stack()))
+			if causeErr, ok := cause.(error); ok {
+				err = erero.Wro(causeErr)
+			} else {
+				err = erero.New(fmt.Sprint(cause))
+			}
+		}
+	}()
+	return task(writer)
+}
+
+// writeJSON writes value as one JSON line
+//
+// writeJSON 将值写为单行 JSON
+func writeJSON(writer io.Writer, value any) {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	must.Done(encoder.Encode(value))
+}
+
+// isNil reports whether value is nil or a nil pointer inside interface
+//
+// isNil 判断值是否为 nil 或接口中的 nil 指针
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
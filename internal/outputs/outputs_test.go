@@ -0,0 +1,126 @@
+package outputs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/zaplog"
+)
+
+type result struct {
+	Version uint
+	Dirty   bool
+}
+
+// newCmd creates command running task, capturing stdout in the returned buffer and stderr in the command
+// newCmd 创建执行任务的命令，在返回的缓冲区中捕获 stdout，stderr 保留在命令中
+func newCmd(task func(writer io.Writer) (any, error)) (*cobra.Command, *bytes.Buffer) {
+	cmd := &cobra.Command{
+		Use:           "demo",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, task)
+		},
+	}
+	outputs.AddFlag(cmd)
+	buffer := &bytes.Buffer{}
+	cmd.SetOut(buffer)
+	cmd.SetErr(&bytes.Buffer{})
+	return cmd, buffer
+}
+
+func TestRunE(t *testing.T) {
+	cmd, buffer := newCmd(func(writer io.Writer) (any, error) {
+		fmt.Fprintln(writer, "text goes to stderr")
+		return &result{Version: 3}, nil
+	})
+	cmd.SetArgs([]string{"--output", "json"})
+	require.NoError(t, cmd.Execute())
+
+	var output result
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &output))
+	require.Equal(t, result{Version: 3}, output)
+	require.Equal(t, "text goes to stderr\n", cmd.ErrOrStderr().(*bytes.Buffer).String())
+}
+
+func TestRunE_Logs(t *testing.T) {
+	cmd, buffer := newCmd(func(writer io.Writer) (any, error) {
+		zaplog.SUG.Debugln("log goes to stderr")
+		return &result{Version: 3}, nil
+	})
+	cmd.SetArgs([]string{"--output", "json"})
+	require.NoError(t, cmd.Execute())
+
+	var output result
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &output))
+	require.Contains(t, cmd.ErrOrStderr().(*bytes.Buffer).String(), "log goes to stderr")
+}
+
+func TestRunE_Text(t *testing.T) {
+	cmd, buffer := newCmd(func(writer io.Writer) (any, error) {
+		fmt.Fprintln(writer, "text goes to stdout")
+		return &result{Version: 3}, nil
+	})
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "text goes to stdout\n", buffer.String())
+}
+
+func TestRunE_Error(t *testing.T) {
+	cmd, buffer := newCmd(func(writer io.Writer) (any, error) {
+		return (*result)(nil), errors.New("broken")
+	})
+	cmd.SetArgs([]string{"--output", "json"})
+	require.Error(t, cmd.Execute())
+
+	var output outputs.ErrorObject
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &output))
+	require.Equal(t, "broken", output.Error)
+	require.Equal(t, migrationerrors.ExitFailure, output.ExitCode)
+}
+
+func TestRunE_ResultWithError(t *testing.T) {
+	cmd, buffer := newCmd(func(writer io.Writer) (any, error) {
+		return &result{Version: 3, Dirty: true}, errors.Wrap(migrationerrors.ErrDirty, "1 of 2 shards failed")
+	})
+	cmd.SetArgs([]string{"--output", "json"})
+	require.ErrorIs(t, cmd.Execute(), migrationerrors.ErrDirty)
+
+	var output struct {
+		result
+		outputs.ErrorObject
+	}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &output))
+	require.Equal(t, result{Version: 3, Dirty: true}, output.result)
+	require.Contains(t, output.Error, "1 of 2 shards failed")
+	require.Equal(t, migrationerrors.ExitDirty, output.ExitCode)
+}
+
+func TestRunE_Panic(t *testing.T) {
+	cmd, buffer := newCmd(func(writer io.Writer) (any, error) {
+		panic(migrationerrors.ErrDirty)
+	})
+	cmd.SetArgs([]string{"--output", "json"})
+	err := cmd.Execute()
+	require.ErrorIs(t, err, migrationerrors.ErrDirty)
+
+	var output outputs.ErrorObject
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &output))
+	require.Equal(t, migrationerrors.ExitDirty, output.ExitCode)
+}
+
+func TestRunE_UnknownFormat(t *testing.T) {
+	cmd, _ := newCmd(func(writer io.Writer) (any, error) {
+		return nil, nil
+	})
+	cmd.SetArgs([]string{"--output", "yaml"})
+	require.ErrorIs(t, cmd.Execute(), migrationerrors.ErrUsage)
+}
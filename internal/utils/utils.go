@@ -10,10 +10,16 @@ package utils
 import (
 	"encoding/hex"
 	"os"
+	"reflect"
+	"unsafe"
 
+	"github.com/go-xlan/go-migrate/migrationerrors"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/yyle88/erero"
 	"github.com/yyle88/eroticgo"
 	"github.com/yyle88/zaplog"
 )
@@ -30,15 +36,18 @@ func NewUUID32s() string {
 	return hex.EncodeToString(u[:])
 }
 
-// WhistleCause processes migration errors with appropriate logging and panic behavior
+// WhistleCauseE processes migration errors with appropriate logging
 // Handles common golang-migrate error cases with informative messages
 // Uses color-coded output for different error types and success states
+// Returns nil when nothing needed to run, wraps dirty and locked errors with typed migration errors
 //
-// WhistleCause 处理迁移错误，采用适当的日志和异常行为
+// WhistleCauseE 处理迁移错误，采用适当的日志输出
 // 处理常见的 golang-migrate 错误情况，并提供信息性消息
 // 使用颜色编码输出来区分不同的错误类型和成功状态
-func WhistleCause(cause error) {
+// 无需执行时返回 nil，将脏状态和锁定错误包装为类型化迁移错误
+func WhistleCauseE(cause error) error {
 	if cause != nil {
+		var errDirty migrate.ErrDirty
 		if errors.Is(cause, migrate.ErrNoChange) {
 			zaplog.SUG.Debugln(eroticgo.BLUE.Sprint("NO MIGRATION FILES TO RUN"))
 		} else if errors.Is(cause, migrate.ErrNilVersion) {
@@ -46,9 +55,54 @@ func WhistleCause(cause error) {
 		} else if errors.Is(cause, os.ErrNotExist) {
 			zaplog.SUG.Debugln(eroticgo.BLUE.Sprint("MIGRATION FILES NOT FOUND"))
 		} else {
-			zaplog.SUG.Panicln(eroticgo.RED.Sprint("MIGRATION FAILED:"), cause)
+			zaplog.SUG.Errorln(eroticgo.RED.Sprint("MIGRATION FAILED:"), cause)
+			switch {
+			case errors.As(cause, &errDirty):
+				return errors.Wrap(migrationerrors.ErrDirty, cause.Error())
+			case errors.Is(cause, migrate.ErrLocked):
+				return errors.Wrap(migrationerrors.ErrLocked, cause.Error())
+			default:
+				return erero.Wro(cause)
+			}
 		}
-		return
+		return nil
 	}
 	zaplog.SUG.Debugln(eroticgo.GREEN.Sprint("MIGRATION SUCCESS"))
+	return nil
+}
+
+// SourceDriver returns source driver that migration reads scripts from
+// golang-migrate keeps the driver private, so it is read through reflection
+// Lets callers list versions from embedded and remote sources the same as scripts DIR
+//
+// SourceDriver 返回迁移读取脚本所用的源驱动
+// golang-migrate 将该驱动设为私有，因此通过反射读取
+// 使调用方能够像脚本 DIR 一样列出嵌入式和远程源中的版本
+func SourceDriver(migration *migrate.Migrate) (source.Driver, error) {
+	return readDriver[source.Driver](migration, "sourceDrv")
+}
+
+// DatabaseDriver returns database driver that migration runs scripts on, read through reflection as well
+// Lets callers run another source on the same connection and lock
+//
+// DatabaseDriver 返回迁移执行脚本所用的数据库驱动，同样通过反射读取
+// 使调用方能够在同一连接和锁上运行另一个源
+func DatabaseDriver(migration *migrate.Migrate) (database.Driver, error) {
+	return readDriver[database.Driver](migration, "databaseDrv")
+}
+
+// readDriver reads private driver field of migration with the given name
+//
+// readDriver 读取迁移中指定名称的私有驱动字段
+func readDriver[T any](migration *migrate.Migrate, name string) (T, error) {
+	var driver T
+	field := reflect.ValueOf(migration).Elem().FieldByName(name)
+	if !field.IsValid() || field.Type() != reflect.TypeFor[T]() {
+		return driver, erero.Errorf("migrate.Migrate has no %s field", name)
+	}
+	driver, _ = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Interface().(T)
+	if field.IsNil() {
+		return driver, erero.Errorf("migrate.Migrate has no %s set", name)
+	}
+	return driver, nil
 }
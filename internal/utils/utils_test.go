@@ -1,10 +1,16 @@
 package utils_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-xlan/go-migrate/internal/utils"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
 )
 
 func TestNewUUID32s(t *testing.T) {
@@ -12,3 +18,26 @@ func TestNewUUID32s(t *testing.T) {
 	t.Log(res)
 	require.Len(t, res, 32)
 }
+
+func TestSourceDriver(t *testing.T) {
+	scriptsInRoot := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00001_init.up.sql"), []byte("SELECT 1;\n"), 0644))
+
+	migration := rese.P1(migrate.New("file://"+scriptsInRoot, "sqlite3://"+filepath.Join(t.TempDir(), "utils.db")))
+	defer func() {
+		sourceErr, databaseErr := migration.Close()
+		require.NoError(t, sourceErr)
+		require.NoError(t, databaseErr)
+	}()
+
+	sourceDriver, err := utils.SourceDriver(migration)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), rese.C1(sourceDriver.First()))
+
+	databaseDriver, err := utils.DatabaseDriver(migration)
+	require.NoError(t, err)
+	version, dirtyFlag, err := databaseDriver.Version()
+	require.NoError(t, err)
+	require.Equal(t, -1, version)
+	require.False(t, dirtyFlag)
+}
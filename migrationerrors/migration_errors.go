@@ -0,0 +1,122 @@
+// Package migrationerrors: Typed migration errors mapped to distinct process exit codes
+// Commands return these errors through RunE so CI can tell failures apart by exit code
+// Errors of other packages wrap them, check with errors.Is
+//
+// migrationerrors: 映射到不同进程退出码的类型化迁移错误
+// 命令通过 RunE 返回这些错误，使 CI 能根据退出码区分失败原因
+// 其它包的错误会包装它们，使用 errors.Is 判断
+package migrationerrors
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ErrUsage reports invalid flags or arguments
+	//
+	// ErrUsage 表示参数或实参无效
+	ErrUsage = errors.New("invalid usage")
+
+	// ErrDirty reports that the database is dirty and needs repair before migrating
+	//
+	// ErrDirty 表示数据库为脏状态，迁移前需要修复
+	ErrDirty = errors.New("database is dirty")
+
+	// ErrDrift reports that applied scripts no longer match what was recorded
+	//
+	// ErrDrift 表示已应用的脚本与记录不再一致
+	ErrDrift = errors.New("scripts drifted from recorded state")
+
+	// ErrNoScripts reports that scripts DIR holds no script to run
+	//
+	// ErrNoScripts 表示脚本 DIR 中没有可执行的脚本
+	ErrNoScripts = errors.New("no scripts to run")
+
+	// ErrPreviewFailed reports that previewed SQL failed before rollback
+	//
+	// ErrPreviewFailed 表示预览的 SQL 在回滚前执行失败
+	ErrPreviewFailed = errors.New("preview failed")
+
+	// ErrScriptAction reports that create or update does not match the unmigrated scripts
+	//
+	// ErrScriptAction 表示创建或更新操作与未迁移脚本的状态不符
+	ErrScriptAction = errors.New("script action does not match unmigrated scripts")
+
+	// ErrCheckFailed reports that scripts break check or lint rules
+	//
+	// ErrCheckFailed 表示脚本违反检查或静态分析规则
+	ErrCheckFailed = errors.New("scripts check failed")
+
+	// ErrCancelled reports that the operator declined a confirmation
+	//
+	// ErrCancelled 表示操作者拒绝了确认
+	ErrCancelled = errors.New("cancelled by operator")
+
+	// ErrLocked reports that another process holds the migration lock
+	//
+	// ErrLocked 表示其它进程持有迁移锁
+	ErrLocked = errors.New("database is locked")
+)
+
+// Process exit codes, 1 for failures without a typed error
+//
+// 进程退出码，没有类型化错误的失败使用 1
+const (
+	ExitSuccess       = 0  // Command finished // 命令执行成功
+	ExitFailure       = 1  // Failure without a typed error // 没有类型化错误的失败
+	ExitUsage         = 2  // ErrUsage // 参数无效
+	ExitDirty         = 3  // ErrDirty // 数据库为脏状态
+	ExitDrift         = 4  // ErrDrift // 脚本与记录不一致
+	ExitNoScripts     = 5  // ErrNoScripts // 没有可执行的脚本
+	ExitPreviewFailed = 6  // ErrPreviewFailed // 预览失败
+	ExitScriptAction  = 7  // ErrScriptAction // 脚本操作不符
+	ExitCheckFailed   = 8  // ErrCheckFailed // 脚本检查失败
+	ExitCancelled     = 9  // ErrCancelled // 操作者取消
+	ExitLocked        = 10 // ErrLocked // 数据库被锁定
+)
+
+// exitCodes maps typed errors to exit codes, the first match wins
+//
+// exitCodes 将类型化错误映射到退出码，第一个匹配的生效
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{ErrUsage, ExitUsage},
+	{ErrDirty, ExitDirty},
+	{ErrDrift, ExitDrift},
+	{ErrNoScripts, ExitNoScripts},
+	{ErrPreviewFailed, ExitPreviewFailed},
+	{ErrScriptAction, ExitScriptAction},
+	{ErrCheckFailed, ExitCheckFailed},
+	{ErrCancelled, ExitCancelled},
+	{ErrLocked, ExitLocked},
+}
+
+// ExitCode returns the exit code of err, ExitSuccess when err is nil
+//
+// ExitCode 返回错误对应的退出码，错误为 nil 时返回 ExitSuccess
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	for _, item := range exitCodes {
+		if errors.Is(err, item.err) {
+			return item.code
+		}
+	}
+	return ExitFailure
+}
+
+// Execute runs command tree and returns exit code of its error, flag errors count as ErrUsage
+// Use as os.Exit(migrationerrors.Execute(rootCmd)) in main
+//
+// Execute 运行命令树并返回其错误对应的退出码，参数错误视为 ErrUsage
+// 在 main 中使用 os.Exit(migrationerrors.Execute(rootCmd))
+func Execute(cmd *cobra.Command) int {
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return errors.Wrap(ErrUsage, err.Error())
+	})
+	return ExitCode(cmd.Execute())
+}
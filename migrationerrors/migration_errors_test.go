@@ -0,0 +1,40 @@
+package migrationerrors_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/erero"
+)
+
+func TestExitCode(t *testing.T) {
+	require.Equal(t, migrationerrors.ExitSuccess, migrationerrors.ExitCode(nil))
+	require.Equal(t, migrationerrors.ExitFailure, migrationerrors.ExitCode(errors.New("broken")))
+	require.Equal(t, migrationerrors.ExitDirty, migrationerrors.ExitCode(erero.Wro(migrationerrors.ErrDirty)))
+	require.Equal(t, migrationerrors.ExitDrift, migrationerrors.ExitCode(errors.Wrap(migrationerrors.ErrDrift, "scripts=[00001_init.up.sql]")))
+	require.Equal(t, migrationerrors.ExitCancelled, migrationerrors.ExitCode(migrationerrors.ErrCancelled))
+}
+
+func TestExecute(t *testing.T) {
+	newCmd := func(err error) *cobra.Command {
+		cmd := &cobra.Command{
+			Use:           "demo",
+			SilenceUsage:  true,
+			SilenceErrors: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return err
+			},
+		}
+		cmd.Flags().Bool("yes", false, "skip confirmation prompt")
+		return cmd
+	}
+	require.Equal(t, migrationerrors.ExitSuccess, migrationerrors.Execute(newCmd(nil)))
+	require.Equal(t, migrationerrors.ExitNoScripts, migrationerrors.Execute(newCmd(erero.Wro(migrationerrors.ErrNoScripts))))
+
+	cmd := newCmd(nil)
+	cmd.SetArgs([]string{"--unknown"})
+	require.Equal(t, migrationerrors.ExitUsage, migrationerrors.Execute(cmd))
+}
@@ -0,0 +1,182 @@
+// Package migrationhistory: History table of migration steps shared by CLI and status packages
+// Saves each up or down step with timing, host, checksum and outcome, and reads them back newest first
+// Tells which older script versions were never applied, such as versions of branches merged late
+//
+// migrationhistory: 供 CLI 和状态包共用的迁移步骤历史表
+// 保存每个升级或回滚步骤的耗时、主机、校验和及结果，并按从新到旧读取
+// 找出从未应用的较旧脚本版本，例如较晚合并的分支中的版本
+package migrationhistory
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+	"gorm.io/gorm"
+)
+
+// DefaultHistoryTable is the suggested name of history table, set HistoryTable in config to turn recording on
+//
+// DefaultHistoryTable 是历史表的建议名称，在配置中设置 HistoryTable 以开启记录
+const DefaultHistoryTable = "schema_migrations_history"
+
+// Step outcomes saved in history records
+//
+// 历史记录中保存的步骤结果
+const (
+	OutcomeSuccess = "success" // Step finished // 步骤执行成功
+	OutcomeFailure = "failure" // Step failed and left database dirty // 步骤失败并使数据库变脏
+	OutcomeForced  = "forced"  // Version marked as applied by force or repair without running the whole script // 通过 force 或 repair 标记为已应用，未完整执行脚本
+)
+
+// HistoryRecord is one up or down step saved in history table
+//
+// HistoryRecord 是保存在历史表中的单个升级或回滚步骤
+type HistoryRecord struct {
+	ID         uint      `gorm:"primaryKey"` // Record ID // 记录 ID
+	Version    uint      `gorm:"index"`      // Version of the script that ran // 执行的脚本版本
+	Direction  string    `gorm:"size:8"`     // Step direction, up or down // 步骤方向，up 或 down
+	StartedAt  time.Time // Time the step started // 步骤开始时间
+	FinishedAt time.Time // Time the step finished // 步骤结束时间
+	DurationMs int64     // Step duration in milliseconds // 步骤耗时（毫秒）
+	Host       string    `gorm:"size:255"`  // Host running the step // 执行步骤的主机
+	OsUser     string    `gorm:"size:255"`  // OS user running the step // 执行步骤的系统用户
+	Checksum   string    `gorm:"size:80"`   // Checksum of the script file // 脚本文件的校验和
+	Outcome    string    `gorm:"size:16"`   // Step outcome, success, failure or forced // 步骤结果，success、failure 或 forced
+	Message    string    `gorm:"type:text"` // Error message of failed step // 失败步骤的错误信息
+}
+
+// EnsureTable creates history table when missing, call it once before saving records of a run
+//
+// EnsureTable 在历史表不存在时创建，在保存一次运行的记录之前调用一次
+func EnsureTable(db *gorm.DB, historyTable string) error {
+	if err := db.Table(historyTable).AutoMigrate(&HistoryRecord{}); err != nil {
+		return erero.Wro(err)
+	}
+	return nil
+}
+
+// SaveRecord saves record into history table, which EnsureTable created
+//
+// SaveRecord 将记录保存到由 EnsureTable 创建的历史表
+func SaveRecord(db *gorm.DB, historyTable string, record *HistoryRecord) error {
+	if err := db.Table(historyTable).Create(record).Error; err != nil {
+		return erero.Wro(err)
+	}
+	return nil
+}
+
+// ReadHistory reads history records newest first, reading all records when limit is not positive
+// Returns no records when the table does not exist yet
+//
+// ReadHistory 按从新到旧读取历史记录，limit 非正数时读取全部记录
+// 表尚不存在时不返回任何记录
+func ReadHistory(db *gorm.DB, historyTable string, limit int) ([]*HistoryRecord, error) {
+	if !db.Migrator().HasTable(historyTable) {
+		return nil, nil
+	}
+	var records []*HistoryRecord
+	query := db.Table(historyTable).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, erero.Wro(err)
+	}
+	return records, nil
+}
+
+// FindOutOfOrder lists script versions older than database version that history shows as not applied
+// Such versions come from branches merged late and are skipped by golang-migrate forever
+// Versions older than the first history record are treated as applied before history was on
+// The latest successful or forced step decides each version, so versions fixed by repair or force are applied
+//
+// FindOutOfOrder 列出早于数据库版本且历史显示未应用的脚本版本
+// 这些版本来自较晚合并的分支，golang-migrate 会一直跳过它们
+// 早于第一条历史记录的版本视为在开启历史之前已应用
+// 每个版本由最近一次成功或强制的步骤决定，因此通过 repair 或 force 修复的版本视为已应用
+func FindOutOfOrder(db *gorm.DB, historyTable string, scriptsInRoot string, databaseVersion uint) ([]uint, error) {
+	records, err := ReadHistory(db, historyTable, 0)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	scriptVersions, err := ScanScriptVersions(scriptsInRoot)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+
+	// Replay records oldest first so the latest successful or forced step decides each version
+	// 按从旧到新重放记录，使每个版本由最近一次成功或强制的步骤决定
+	applied := make(map[uint]bool)
+	for _, record := range slices.Backward(records) {
+		if record.Outcome == OutcomeSuccess || record.Outcome == OutcomeForced {
+			applied[record.Version] = record.Direction == string(source.Up)
+		}
+	}
+	firstVersion := records[len(records)-1].Version
+
+	var versions []uint
+	for _, version := range scriptVersions {
+		if version >= firstVersion && version < databaseVersion && !applied[version] {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// ScanScriptVersions reads scripts DIR and returns sorted unique versions of scripts in it
+// Sub DIRs and files not matching the migration pattern are skipped
+//
+// ScanScriptVersions 读取脚本 DIR 并返回其中脚本的有序唯一版本
+// 跳过子 DIR 以及不匹配迁移模式的文件
+func ScanScriptVersions(scriptsInRoot string) ([]uint, error) {
+	entries, err := os.ReadDir(scriptsInRoot)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	var versions []uint
+	for _, item := range entries {
+		if item.IsDir() {
+			continue
+		}
+		migration, err := source.DefaultParse(item.Name())
+		if err != nil {
+			continue // Skip files that don't match migration pattern // 跳过不匹配迁移模式的文件
+		}
+		versions = append(versions, migration.Version)
+	}
+	slices.Sort(versions)
+	return slices.Compact(versions), nil
+}
+
+// ScanSourceVersions walks source driver with First and Next and returns sorted versions of scripts in it
+// Returns no versions when source holds no scripts
+//
+// ScanSourceVersions 通过 First 和 Next 遍历源驱动并返回其中脚本的有序版本
+// 源中没有脚本时返回空版本列表
+func ScanSourceVersions(sourceDriver source.Driver) ([]uint, error) {
+	version, err := sourceDriver.First()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, erero.Wro(err)
+	}
+	versions := []uint{version}
+	for {
+		version, err = sourceDriver.Next(version)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return versions, nil
+			}
+			return nil, erero.Wro(err)
+		}
+		versions = append(versions, version)
+	}
+}
@@ -0,0 +1,111 @@
+package migrationhistory_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/migrationhistory"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newScripts writes up and down scripts of versions into a temp DIR
+// newScripts 将各版本的升级和回滚脚本写入临时 DIR
+func newScripts(t *testing.T, names ...string) string {
+	scriptsInRoot := t.TempDir()
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, name+".up.sql"), []byte("SELECT 1;\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, name+".down.sql"), []byte("SELECT 1;\n"), 0644))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(scriptsInRoot, "00009_sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "README.md"), []byte("notes\n"), 0644))
+	return scriptsInRoot
+}
+
+// newHistoryDB opens a sqlite database in a temp DIR with history table created
+// newHistoryDB 在临时 DIR 中打开已创建历史表的 sqlite 数据库
+func newHistoryDB(t *testing.T) *gorm.DB {
+	db := rese.P1(gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "history.db")), &gorm.Config{}))
+	t.Cleanup(func() {
+		require.NoError(t, rese.P1(db.DB()).Close())
+	})
+	require.NoError(t, migrationhistory.EnsureTable(db, migrationhistory.DefaultHistoryTable))
+	return db
+}
+
+func TestScanScriptVersions(t *testing.T) {
+	scriptsInRoot := newScripts(t, "00003_items", "00001_init", "00002_name")
+
+	versions, err := migrationhistory.ScanScriptVersions(scriptsInRoot)
+	require.NoError(t, err)
+	require.Equal(t, []uint{1, 2, 3}, versions)
+
+	_, err = migrationhistory.ScanScriptVersions(filepath.Join(scriptsInRoot, "missing"))
+	require.Error(t, err)
+}
+
+func TestScanSourceVersions(t *testing.T) {
+	scriptsInRoot := newScripts(t, "00003_items", "00001_init", "00002_name")
+	sourceDriver := rese.V1(iofs.New(os.DirFS(scriptsInRoot), "."))
+	defer rese.F0(sourceDriver.Close)
+
+	versions, err := migrationhistory.ScanSourceVersions(sourceDriver)
+	require.NoError(t, err)
+	require.Equal(t, []uint{1, 2, 3}, versions)
+
+	emptyDriver := rese.V1(iofs.New(os.DirFS(t.TempDir()), "."))
+	defer rese.F0(emptyDriver.Close)
+
+	versions, err = migrationhistory.ScanSourceVersions(emptyDriver)
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
+
+func TestReadHistory_NoTable(t *testing.T) {
+	db := rese.P1(gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "empty.db")), &gorm.Config{}))
+	defer func() {
+		require.NoError(t, rese.P1(db.DB()).Close())
+	}()
+
+	records, err := migrationhistory.ReadHistory(db, migrationhistory.DefaultHistoryTable, 0)
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
+
+func TestFindOutOfOrder(t *testing.T) {
+	scriptsInRoot := newScripts(t, "00001_init", "00002_name", "00003_items", "00004_tags", "00005_orders")
+	db := newHistoryDB(t)
+	for _, record := range []*migrationhistory.HistoryRecord{
+		{Version: 2, Direction: "up", Outcome: migrationhistory.OutcomeSuccess},
+		{Version: 3, Direction: "up", Outcome: migrationhistory.OutcomeFailure},
+		{Version: 4, Direction: "up", Outcome: migrationhistory.OutcomeFailure},
+		{Version: 4, Direction: "up", Outcome: migrationhistory.OutcomeForced},
+		{Version: 5, Direction: "up", Outcome: migrationhistory.OutcomeSuccess},
+	} {
+		require.NoError(t, migrationhistory.SaveRecord(db, migrationhistory.DefaultHistoryTable, record))
+	}
+
+	// Version 1 is older than the first record, version 4 was forced, so only version 3 is missing
+	// 版本 1 早于第一条记录，版本 4 已被强制标记，因此只缺失版本 3
+	versions, err := migrationhistory.FindOutOfOrder(db, migrationhistory.DefaultHistoryTable, scriptsInRoot, 5)
+	require.NoError(t, err)
+	require.Equal(t, []uint{3}, versions)
+
+	require.NoError(t, migrationhistory.SaveRecord(db, migrationhistory.DefaultHistoryTable, &migrationhistory.HistoryRecord{
+		Version:   2,
+		Direction: "down",
+		Outcome:   migrationhistory.OutcomeSuccess,
+	}))
+	versions, err = migrationhistory.FindOutOfOrder(db, migrationhistory.DefaultHistoryTable, scriptsInRoot, 5)
+	require.NoError(t, err)
+	require.Equal(t, []uint{2, 3}, versions)
+
+	records, err := migrationhistory.ReadHistory(db, migrationhistory.DefaultHistoryTable, 2)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "down", records[0].Direction)
+}
@@ -9,18 +9,19 @@ package migrationstate
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"sort"
 
 	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/migrationhistory"
 	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/go-xlan/go-migrate/newscripts"
 	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/yyle88/erero"
 	"github.com/yyle88/eroticgo"
-	"github.com/yyle88/rese"
 	"gorm.io/gorm"
 )
 
@@ -30,9 +31,10 @@ import (
 // Config 包含状态命令所需的配置选项
 // 提供执行状态检查时所需的依赖
 type Config struct {
-	Param       *migrationparam.MigrationParam // Migration connection // 迁移连接
-	ScriptsPath string                         // Path to migration scripts DIR // 迁移脚本目录路径
-	Objects     []any                          // GORM model objects used in schema comparison // 用于结构比较的 GORM 模型对象
+	Param        *migrationparam.MigrationParam // Migration connection // 迁移连接
+	ScriptsPath  string                         // Path to migration scripts DIR // 迁移脚本目录路径
+	Objects      []any                          // GORM model objects used in schema comparison // 用于结构比较的 GORM 模型对象
+	HistoryTable string                         // History table of applied versions, out-of-order versions are reported when set // 已应用版本的历史表，设置后会报告乱序版本
 }
 
 // Status represents the current migration status
@@ -51,6 +53,8 @@ type Status struct {
 	PendingVersions     []uint   // List of pending migration versions // 待执行迁移版本列表
 	SchemaDiffCount     int      // Count of schema differences // 结构差异数量
 	SchemaDiffSQLs      []string // SQL statements showing schema differences // 结构差异的 SQL 语句
+	ModifiedScripts     []string // Applied scripts edited since recorded in manifest // 记录到清单后被修改的已应用脚本
+	OutOfOrderVersions  []uint   // Older versions missing in history table // 历史表中缺失的较旧版本
 }
 
 // GetStatus analyzes current migration state and returns comprehensive status
@@ -81,7 +85,7 @@ func GetStatus(db *gorm.DB, migration *migrate.Migrate, scriptsPath string, obje
 
 	// Scan scripts DIR and extract versions
 	// 扫描脚本目录并提取版本
-	scriptVersions, err := scanScriptVersions(scriptsPath)
+	scriptVersions, err := migrationhistory.ScanScriptVersions(scriptsPath)
 	if err != nil {
 		return nil, erero.Wro(err)
 	}
@@ -100,6 +104,16 @@ func GetStatus(db *gorm.DB, migration *migrate.Migrate, scriptsPath string, obje
 	status.PendingCount = len(status.PendingVersions)
 	status.HasUpToDate = status.PendingCount == 0
 
+	// Verify applied scripts against checksum manifest
+	// 根据校验和清单检查已应用的脚本
+	mismatches, err := newscripts.CompareManifest(newscripts.NewOptions(scriptsPath), status.DatabaseVersion)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	for _, mismatch := range mismatches {
+		status.ModifiedScripts = append(status.ModifiedScripts, mismatch.Name)
+	}
+
 	// Check schema differences when objects are provided
 	// 当提供对象时检查结构差异
 	if len(objects) > 0 {
@@ -111,38 +125,27 @@ func GetStatus(db *gorm.DB, migration *migrate.Migrate, scriptsPath string, obje
 	return status, nil
 }
 
-// scanScriptVersions reads script DIR and extracts version numbers
-// Returns sorted list of unique version numbers
+// GetStatusWithHistory analyzes migration state like GetStatus and also reports out-of-order versions
+// Older versions missing in history table keep the database from being up to date
 //
-// scanScriptVersions 读取脚本目录并提取版本号
-// 返回排序后的唯一版本号列表
-func scanScriptVersions(scriptsPath string) ([]uint, error) {
-	entries, err := os.ReadDir(scriptsPath)
+// GetStatusWithHistory 与 GetStatus 一样分析迁移状态，并额外报告乱序版本
+// 历史表中缺失的较旧版本会使数据库不处于最新状态
+func GetStatusWithHistory(db *gorm.DB, migration *migrate.Migrate, scriptsPath string, objects []any, historyTable string) (*Status, error) {
+	status, err := GetStatus(db, migration, scriptsPath, objects)
 	if err != nil {
 		return nil, erero.Wro(err)
 	}
-
-	versionSet := make(map[uint]bool)
-	for _, item := range entries {
-		if item.IsDir() {
-			continue
-		}
-		migration, err := source.DefaultParse(item.Name())
-		if err != nil {
-			continue // Skip files that don't match migration pattern // 跳过不匹配迁移模式的文件
-		}
-		versionSet[migration.Version] = true
+	if historyTable == "" || !status.HasMigrated {
+		return status, nil
 	}
-
-	versions := make([]uint, 0, len(versionSet))
-	for v := range versionSet {
-		versions = append(versions, v)
+	status.OutOfOrderVersions, err = migrationhistory.FindOutOfOrder(db, historyTable, scriptsPath, status.DatabaseVersion)
+	if err != nil {
+		return nil, erero.Wro(err)
 	}
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[i] < versions[j]
-	})
-
-	return versions, nil
+	if len(status.OutOfOrderVersions) > 0 {
+		status.HasUpToDate = false
+	}
+	return status, nil
 }
 
 // ShowStatus outputs status information in a readable format
@@ -151,69 +154,104 @@ func scanScriptVersions(scriptsPath string) ([]uint, error) {
 // ShowStatus 以可读格式输出状态信息
 // 彩色输出提升阅读体验
 func ShowStatus(status *Status) {
-	eroticgo.CYAN.ShowMessage("=== Migration Status ===")
+	ShowStatusTo(os.Stdout, status)
+}
+
+// ShowStatusTo writes status information into writer in a readable format, used by commands with --output
+//
+// ShowStatusTo 以可读格式将状态信息写入 writer，供支持 --output 的命令使用
+func ShowStatusTo(writer io.Writer, status *Status) {
+	outputs.ShowMessage(writer, eroticgo.CYAN, "=== Migration Status ===")
 
 	// Database version
 	// 数据库版本
 	if status.HasMigrated {
 		if status.IsDirtyFlag {
-			eroticgo.RED.ShowMessage(fmt.Sprintf("Database Version: %d (DIRTY)", status.DatabaseVersion))
+			outputs.ShowMessage(writer, eroticgo.RED, fmt.Sprintf("Database Version: %d (DIRTY)", status.DatabaseVersion))
 		} else {
-			eroticgo.GREEN.ShowMessage(fmt.Sprintf("Database Version: %d", status.DatabaseVersion))
+			outputs.ShowMessage(writer, eroticgo.GREEN, fmt.Sprintf("Database Version: %d", status.DatabaseVersion))
 		}
 	} else {
-		eroticgo.YELLOW.ShowMessage("Database Version: (none - no migration records)")
+		outputs.ShowMessage(writer, eroticgo.YELLOW, "Database Version: (none - no migration records)")
 	}
 
 	// Script info
 	// 脚本信息
 	if status.ScriptCount > 0 {
-		eroticgo.GREEN.ShowMessage(fmt.Sprintf("Scripts Latest: %d (%d scripts)", status.LatestScriptVersion, status.ScriptCount))
+		outputs.ShowMessage(writer, eroticgo.GREEN, fmt.Sprintf("Scripts Latest: %d (%d scripts)", status.LatestScriptVersion, status.ScriptCount))
 	} else {
-		eroticgo.YELLOW.ShowMessage("Scripts Latest: (none - no scripts found)")
+		outputs.ShowMessage(writer, eroticgo.YELLOW, "Scripts Latest: (none - no scripts found)")
 	}
 
 	// Pending migrations
 	// 待执行迁移
 	if status.PendingCount > 0 {
-		eroticgo.YELLOW.ShowMessage(fmt.Sprintf("Pending Migrations: %d", status.PendingCount))
-		fmt.Println("  Versions:", status.PendingVersions)
+		outputs.ShowMessage(writer, eroticgo.YELLOW, fmt.Sprintf("Pending Migrations: %d", status.PendingCount))
+		fmt.Fprintln(writer, "  Versions:", status.PendingVersions)
 	} else {
-		eroticgo.GREEN.ShowMessage("Pending Migrations: 0 (up to date)")
+		outputs.ShowMessage(writer, eroticgo.GREEN, "Pending Migrations: 0 (up to date)")
+	}
+
+	// Out-of-order versions
+	// 乱序版本
+	if len(status.OutOfOrderVersions) > 0 {
+		outputs.ShowMessage(writer, eroticgo.YELLOW, fmt.Sprintf("Out-of-Order Versions: %d (older than database version, run `migrate all --out-of-order`)", len(status.OutOfOrderVersions)))
+		fmt.Fprintln(writer, "  Versions:", status.OutOfOrderVersions)
+	}
+
+	// Modified applied scripts
+	// 被修改的已应用脚本
+	if len(status.ModifiedScripts) > 0 {
+		outputs.ShowMessage(writer, eroticgo.RED, fmt.Sprintf("Modified Scripts: %d (applied scripts changed since recorded in %s)", len(status.ModifiedScripts), newscripts.ManifestName))
+		for _, name := range status.ModifiedScripts {
+			fmt.Fprintln(writer, "  ->", name)
+		}
 	}
 
 	// Schema differences
 	// 结构差异
 	if status.SchemaDiffCount > 0 {
-		eroticgo.YELLOW.ShowMessage(fmt.Sprintf("Schema Differences: %d", status.SchemaDiffCount))
-		fmt.Println("  (Database has changes not yet in migration scripts)")
+		outputs.ShowMessage(writer, eroticgo.YELLOW, fmt.Sprintf("Schema Differences: %d", status.SchemaDiffCount))
+		fmt.Fprintln(writer, "  (Database has changes not yet in migration scripts)")
 		for i, sql := range status.SchemaDiffSQLs {
-			fmt.Println("->", i+1, "->", sql)
+			fmt.Fprintln(writer, "->", i+1, "->", sql)
 		}
 	} else if status.SchemaDiffCount == 0 && len(status.SchemaDiffSQLs) == 0 {
-		eroticgo.GREEN.ShowMessage("Schema Differences: 0 (Models match database)")
+		outputs.ShowMessage(writer, eroticgo.GREEN, "Schema Differences: 0 (Models match database)")
 	}
 }
 
 // NewStatusCmd creates cobra command that displays migration status
-// Provides comprehensive view of current migration state
+// Provides comprehensive view of current migration state, fails with ErrScriptModified when applied scripts were edited
 //
 // NewStatusCmd 创建显示迁移状态的 cobra 命令
-// 提供当前迁移状态的综合视图
+// 提供当前迁移状态的综合视图，已应用脚本被修改时以 ErrScriptModified 失败
 func NewStatusCmd(cfg *Config) *cobra.Command {
-	return &cobra.Command{
-		Use:   "status",
-		Short: "Show migration status",
-		Long:  "Show current database version, script versions, pending migrations and schema differences",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := cfg.Param.GetMigration()
-			defer cleanup()
-
-			db, cleanup2 := cfg.Param.GetDB()
-			defer cleanup2()
-			status := rese.P1(GetStatus(db, migration, cfg.ScriptsPath, cfg.Objects))
-			ShowStatus(status)
+	cmd := &cobra.Command{
+		Use:          "status",
+		Short:        "Show migration status",
+		Long:         "Show current database version, script versions, pending migrations and schema differences",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				migration, cleanup := cfg.Param.GetMigration()
+				defer cleanup()
+
+				db, cleanup2 := cfg.Param.GetDB()
+				defer cleanup2()
+				status, err := GetStatusWithHistory(db, migration, cfg.ScriptsPath, cfg.Objects, cfg.HistoryTable)
+				if err != nil {
+					return nil, err
+				}
+				ShowStatusTo(writer, status)
+				if len(status.ModifiedScripts) > 0 {
+					return status, erero.Wrapf(newscripts.ErrScriptModified, "database-version=%d scripts=%v", status.DatabaseVersion, status.ModifiedScripts)
+				}
+				return status, nil
+			})
 		},
 	}
+	outputs.AddFlag(cmd)
+	return cmd
 }
@@ -1 +1,56 @@
 package migrationstate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/go-xlan/go-migrate/migrationstate"
+	"github.com/go-xlan/go-migrate/newmigrate"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestNewStatusCmd_ModifiedScripts(t *testing.T) {
+	scriptsInRoot := t.TempDir()
+	forwardPath := filepath.Join(scriptsInRoot, "00001_init.up.sql")
+	require.NoError(t, os.WriteFile(forwardPath, []byte("CREATE TABLE `users` (`id` integer);\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00001_init.down.sql"), []byte("DROP TABLE `users`;\n"), 0644))
+
+	databasePath := filepath.Join(t.TempDir(), "main.db")
+	param := migrationparam.NewMigrationParam(
+		func() *gorm.DB {
+			return rese.P1(gorm.Open(sqlite.Open(databasePath), &gorm.Config{}))
+		},
+		func(db *gorm.DB) *migrate.Migrate {
+			return rese.P1(newmigrate.NewWithScriptsAndDatabase(&newmigrate.ScriptsAndDatabaseParam{
+				ScriptsInRoot:    scriptsInRoot,
+				DatabaseName:     "sqlite3",
+				DatabaseInstance: rese.V1(sqlite3.WithInstance(rese.P1(db.DB()), &sqlite3.Config{})),
+			}))
+		},
+	)
+	migration, cleanup := param.GetMigration()
+	require.NoError(t, migration.Up())
+	cleanup()
+	require.NoError(t, newscripts.WriteManifest(newscripts.NewOptions(scriptsInRoot), 1))
+
+	cfg := &migrationstate.Config{Param: param, ScriptsPath: scriptsInRoot}
+	cmd := migrationstate.NewStatusCmd(cfg)
+	cmd.SetArgs([]string{})
+	require.NoError(t, cmd.Execute())
+
+	// Editing an applied script turns status into a drift failure
+	// 修改已应用的脚本会使 status 以漂移失败
+	require.NoError(t, os.WriteFile(forwardPath, []byte("CREATE TABLE `users` (`id` integer, `name` text);\n"), 0644))
+	cmd = migrationstate.NewStatusCmd(cfg)
+	cmd.SetArgs([]string{})
+	require.ErrorIs(t, cmd.Execute(), migrationerrors.ErrDrift)
+}
@@ -0,0 +1,304 @@
+package multimigration
+
+import (
+	"io"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationstate"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/eroticgo"
+	"github.com/yyle88/zaplog"
+)
+
+// Config contains modules managed by the multi-module command tree
+//
+// Config 包含由多模块命令树管理的模块
+type Config struct {
+	Modules []*Module // Modules in declared sequence // 按声明顺序排列的模块
+}
+
+// NewModulesCmd creates command tree running status, all and new-script on each module
+// The --module flag selects modules, all modules run when it is not given
+// Modules always run in dependency sequence
+//
+// NewModulesCmd 创建在每个模块上运行 status、all 和 new-script 的命令树
+// --module 参数用于选择模块，未指定时运行全部模块
+// 模块始终按依赖顺序运行
+func NewModulesCmd(config *Config) *cobra.Command {
+	var moduleNames []string
+
+	// Create root command
+	var rootCmd = &cobra.Command{
+		Use:          "module",
+		Short:        "Multi-module migration",
+		Long:         "Multi-module migration",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				results := &ModulesResult{}
+				modules, err := SelectModules(config.Modules, moduleNames)
+				if err != nil {
+					return nil, err
+				}
+				for _, module := range modules {
+					versionInfo, err := cobramigration.GetVersionInfo(module.Param)
+					if err != nil {
+						return results, err
+					}
+					if versionInfo.Dirty {
+						outputs.ShowMessage(writer, eroticgo.RED, module.Name, versionInfo.Version, "(DIRTY)")
+					} else {
+						outputs.ShowMessage(writer, eroticgo.GREEN, module.Name, versionInfo.Version)
+					}
+					results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Version: versionInfo})
+				}
+				return results, nil
+			})
+		},
+	}
+	outputs.AddFlag(rootCmd)
+	rootCmd.PersistentFlags().StringSliceVar(&moduleNames, "module", nil, "module names to run, all modules when empty")
+
+	selectModules := func() ([]*Module, error) {
+		return SelectModules(config.Modules, moduleNames)
+	}
+	rootCmd.AddCommand(newStatusCmd(selectModules))    // Append `status` subcommand // 添加 `status` 子命令
+	rootCmd.AddCommand(newAllCmd(selectModules))       // Append `all` subcommand // 添加 `all` 子命令
+	rootCmd.AddCommand(newNewScriptCmd(selectModules)) // Append `new-script` subcommand // 添加 `new-script` 子命令
+
+	return rootCmd
+}
+
+// ModuleResult contains outcome of one module, only fields set by the command are filled
+//
+// ModuleResult 包含单个模块的结果，仅填充命令设置的字段
+type ModuleResult struct {
+	Name    string                      // Module name // 模块名称
+	Version *cobramigration.VersionInfo `json:",omitempty"` // Version info, set by root and all commands // 版本信息，由根命令和 all 命令设置
+	Status  *migrationstate.Status      `json:",omitempty"` // Migration status, set by status command // 迁移状态，由 status 命令设置
+	Scripts *newscripts.WriteResult     `json:",omitempty"` // Scripts written, set by new-script commands // 写入的脚本，由 new-script 命令设置
+	Error   string                      `json:",omitempty"` // Reason the module was skipped or failed // 模块被跳过或失败的原因
+}
+
+// ModulesResult contains results of selected modules, written as JSON object of module commands
+//
+// ModulesResult 包含所选模块的结果，作为 module 命令的 JSON 对象输出
+type ModulesResult struct {
+	Modules []*ModuleResult // Results in dependency sequence // 按依赖顺序排列的结果
+}
+
+// showModule writes module banner into writer before running a module
+//
+// showModule 在运行模块前将模块标题写入 writer
+func showModule(writer io.Writer, module *Module) {
+	outputs.ShowMessage(writer, eroticgo.CYAN, "=== Module:", module.Name, "===")
+}
+
+// newStatusCmd creates command showing migration status of each module
+//
+// newStatusCmd 创建显示每个模块迁移状态的命令
+func newStatusCmd(selectModules func() ([]*Module, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:          "status",
+		Short:        "Show migration status of modules",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				modules, err := selectModules()
+				if err != nil {
+					return nil, err
+				}
+				results := &ModulesResult{}
+				for _, module := range modules {
+					showModule(writer, module)
+					migration, cleanup := module.Param.GetMigration()
+					db, _ := module.Param.GetDB()
+					status, err := migrationstate.GetStatus(db, migration, module.ScriptsInRoot, module.Objects)
+					cleanup()
+					if err != nil {
+						return results, err
+					}
+					migrationstate.ShowStatusTo(writer, status)
+					results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Status: status})
+				}
+				return results, nil
+			})
+		},
+	}
+}
+
+// newAllCmd creates command running all pending migrations of each module in dependency sequence
+// Stops at the first failing module so dependent modules are not migrated, keeping results of modules before it
+//
+// newAllCmd 创建按依赖顺序执行每个模块所有待处理迁移的命令
+// 在第一个失败的模块处停止，依赖它的模块不会被迁移，并保留其之前模块的结果
+func newAllCmd(selectModules func() ([]*Module, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:          "all",
+		Short:        "Run all migration files of modules",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				modules, err := selectModules()
+				if err != nil {
+					return nil, err
+				}
+				results := &ModulesResult{}
+				for _, module := range modules {
+					showModule(writer, module)
+					if err := cobramigration.MigrateAll(&cobramigration.Config{
+						Param:         module.Param,
+						ScriptsInRoot: module.ScriptsInRoot,
+					}); err != nil {
+						outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Modules after", module.Name, "are not migrated.")
+						versionInfo, _ := cobramigration.GetVersionInfo(module.Param) // Keep version of the failed module when readable // 可读取时保留失败模块的版本
+						results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Version: versionInfo, Error: err.Error()})
+						return results, err
+					}
+					versionInfo, err := cobramigration.GetVersionInfo(module.Param)
+					if err != nil {
+						return results, err
+					}
+					results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Version: versionInfo})
+				}
+				return results, nil
+			})
+		},
+	}
+}
+
+// newNewScriptCmd creates command generating scripts of each module from its own models
+//
+// newNewScriptCmd 创建根据各模块自身模型生成脚本的命令
+func newNewScriptCmd(selectModules func() ([]*Module, error)) *cobra.Command {
+	var rootCmd = &cobra.Command{
+		Use:   "new-script",
+		Short: "Create next migration script of modules",
+		Args:  cobra.NoArgs,
+	}
+	rootCmd.AddCommand(newCreateScriptCmd(selectModules))
+	rootCmd.AddCommand(newUpdateScriptCmd(selectModules))
+	return rootCmd
+}
+
+// newScriptConfig builds newscripts config of module, writing script diffs and analysis problems into writer
+//
+// newScriptConfig 构建模块的 newscripts 配置，将脚本差异和分析问题写入 writer
+func newScriptConfig(writer io.Writer, module *Module) *newscripts.Config {
+	options := *module.getOptions()
+	options.Output = writer
+	return &newscripts.Config{
+		Param:   module.Param,
+		Options: &options,
+		Objects: module.Objects,
+	}
+}
+
+// newCreateScriptCmd creates command generating new scripts of each module with schema changes
+// Modules with unmigrated scripts are skipped with a notice
+//
+// newCreateScriptCmd 创建为每个存在结构变化的模块生成新脚本的命令
+// 存在未迁移脚本的模块会被跳过并给出提示
+func newCreateScriptCmd(selectModules func() ([]*Module, error)) *cobra.Command {
+	var versionTypeInput string
+	var descriptionTitle string
+
+	cmd := &cobra.Command{
+		Use:          "create",
+		Short:        "create new migration script of modules",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				results := &ModulesResult{}
+				versionType := newscripts.VersionPattern(versionTypeInput)
+				if _, ok := newscripts.LookupVersionGenerator(versionType); !ok && versionType != "" {
+					outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Unknown version-type:", versionTypeInput)
+					return nil, erero.Wrapf(migrationerrors.ErrUsage, "unknown version-type %q", versionTypeInput)
+				}
+				modules, err := selectModules()
+				if err != nil {
+					return nil, err
+				}
+				for _, module := range modules {
+					showModule(writer, module)
+					scriptNaming := &newscripts.ScriptNaming{
+						VersionType: versionType,
+						Description: descriptionTitle,
+					}
+					result, err := newscripts.CreateNewScript(newScriptConfig(writer, module), scriptNaming, false)
+					switch {
+					case errors.Is(err, newscripts.ErrScriptPending):
+						outputs.ShowMessage(writer, eroticgo.AMBER, "SKIPPED. Use [update script] when THERE ARE UNMIGRATED SCRIPTS.")
+						results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Error: err.Error()})
+						continue
+					case errors.Is(err, newscripts.ErrLintFailed):
+						outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Scripts break static analysis rules.")
+						results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Error: err.Error()})
+						return results, err
+					}
+					if err != nil {
+						results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Error: err.Error()})
+						return results, err
+					}
+					zaplog.SUG.Debugln("module", module.Name, "done")
+					results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Scripts: result})
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return results, nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&versionTypeInput, "version-type", "", "registered version pattern name, empty takes pattern of existing scripts")
+	cmd.Flags().StringVar(&descriptionTitle, "description", "script", "migration script description name")
+
+	return cmd
+}
+
+// newUpdateScriptCmd creates command rewriting top unmigrated scripts of each module
+// Modules without unmigrated scripts are skipped with a notice
+//
+// newUpdateScriptCmd 创建重写每个模块最新未迁移脚本的命令
+// 没有未迁移脚本的模块会被跳过并给出提示
+func newUpdateScriptCmd(selectModules func() ([]*Module, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:          "update",
+		Short:        "update top migration script of modules",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				modules, err := selectModules()
+				if err != nil {
+					return nil, err
+				}
+				results := &ModulesResult{}
+				for _, module := range modules {
+					showModule(writer, module)
+					result, err := newscripts.UpdateTopScript(newScriptConfig(writer, module))
+					if errors.Is(err, newscripts.ErrNoScriptPending) {
+						outputs.ShowMessage(writer, eroticgo.AMBER, "SKIPPED. Use [create script] when THERE ARE NO UNMIGRATED SCRIPTS.")
+						results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Error: err.Error()})
+						continue
+					}
+					if err != nil {
+						results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Error: err.Error()})
+						return results, err
+					}
+					results.Modules = append(results.Modules, &ModuleResult{Name: module.Name, Scripts: result})
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return results, nil
+			})
+		},
+	}
+}
@@ -0,0 +1,128 @@
+// Package multimigration: Multi-module migration management for modular applications
+// Each module owns its models, scripts DIR and version table, sharing one cobra command tree
+// Modules run in declared dependency sequence so dependent modules migrate after their dependencies
+//
+// multimigration: 面向模块化应用的多模块迁移管理
+// 每个模块拥有自己的模型、脚本 DIR 和版本表，共用一棵 cobra 命令树
+// 模块按声明的依赖顺序运行，使依赖方模块在其依赖之后迁移
+package multimigration
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/yyle88/erero"
+)
+
+// Module describes one module with its own scripts DIR and version table
+// Param should build migration using the module's own version table, see MigrationsTable
+//
+// Module 描述拥有独立脚本 DIR 和版本表的单个模块
+// Param 应使用模块自己的版本表构建迁移，参见 MigrationsTable
+type Module struct {
+	Name          string                         // Module name used in --module flag // 用于 --module 参数的模块名称
+	DependsOn     []string                       // Names of modules migrated before this one // 需在本模块之前迁移的模块名称
+	Param         *migrationparam.MigrationParam // Migration connection of this module // 本模块的迁移连接
+	ScriptsInRoot string                         // Path to scripts DIR of this module // 本模块的脚本 DIR 路径
+	Objects       []any                          // GORM model objects owned by this module // 本模块拥有的 GORM 模型对象
+	Options       *newscripts.Options            // Script generation options, optional // 脚本生成选项，可选
+}
+
+// getOptions returns configured options, falling back to default options of scripts DIR
+//
+// getOptions 返回配置的选项，未设置时回退到脚本 DIR 的默认选项
+func (module *Module) getOptions() *newscripts.Options {
+	if module.Options == nil {
+		module.Options = newscripts.NewOptions(module.ScriptsInRoot)
+	}
+	return module.Options
+}
+
+// MigrationsTable returns version table name of module, used as golang-migrate MigrationsTable config
+// Keeps versions of each module apart when modules share one database
+//
+// MigrationsTable 返回模块的版本表名，用作 golang-migrate 的 MigrationsTable 配置
+// 当模块共用一个数据库时使各模块的版本互不干扰
+func MigrationsTable(moduleName string) string {
+	return "schema_migrations_" + strings.ReplaceAll(moduleName, "-", "_")
+}
+
+// SortModules sorts modules so each module comes after the modules it depends on
+// Keeps declared sequence among independent modules, returns error on unknown names or cycles
+//
+// SortModules 排序模块，使每个模块排在其依赖的模块之后
+// 相互独立的模块保持声明顺序，遇到未知名称或循环依赖时返回错误
+func SortModules(modules []*Module) ([]*Module, error) {
+	moduleMap := make(map[string]*Module, len(modules))
+	for _, module := range modules {
+		if module.Name == "" {
+			return nil, erero.New("module name is empty")
+		}
+		if _, exists := moduleMap[module.Name]; exists {
+			return nil, erero.Errorf("duplicate module name: %s", module.Name)
+		}
+		moduleMap[module.Name] = module
+	}
+	for _, module := range modules {
+		for _, name := range module.DependsOn {
+			if _, exists := moduleMap[name]; !exists {
+				return nil, erero.Errorf("module %s depends on unknown module %s", module.Name, name)
+			}
+		}
+	}
+
+	// Repeatedly take the first module in declared sequence whose dependencies are all placed
+	// 反复取出声明顺序中第一个依赖已全部就位的模块
+	placed := make(map[string]bool, len(modules))
+	results := make([]*Module, 0, len(modules))
+	for len(results) < len(modules) {
+		idx := slices.IndexFunc(modules, func(module *Module) bool {
+			if placed[module.Name] {
+				return false
+			}
+			return !slices.ContainsFunc(module.DependsOn, func(name string) bool { return !placed[name] })
+		})
+		if idx < 0 {
+			var names []string
+			for _, module := range modules {
+				if !placed[module.Name] {
+					names = append(names, module.Name)
+				}
+			}
+			return nil, erero.Errorf("dependency cycle among modules: %v", names)
+		}
+		placed[modules[idx].Name] = true
+		results = append(results, modules[idx])
+	}
+	return results, nil
+}
+
+// SelectModules sorts modules and keeps the named ones, keeping all when names are empty
+// Dependencies of named modules are not added implicitly, unknown names return ErrUsage
+//
+// SelectModules 排序模块并保留指定名称的模块，名称为空时保留全部
+// 不会隐式添加指定模块的依赖，未知名称返回 ErrUsage
+func SelectModules(modules []*Module, names []string) ([]*Module, error) {
+	sorted, err := SortModules(modules)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	if len(names) == 0 {
+		return sorted, nil
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(sorted, func(module *Module) bool { return module.Name == name }) {
+			return nil, erero.Wrapf(migrationerrors.ErrUsage, "unknown module: %s", name)
+		}
+	}
+	var results []*Module
+	for _, module := range sorted {
+		if slices.Contains(names, module.Name) {
+			results = append(results, module)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,136 @@
+package multimigration_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/go-xlan/go-migrate/multimigration"
+	"github.com/go-xlan/go-migrate/newmigrate"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSortModules(t *testing.T) {
+	modules := []*multimigration.Module{
+		{Name: "billing", DependsOn: []string{"users"}},
+		{Name: "audit"},
+		{Name: "users", DependsOn: []string{"core"}},
+		{Name: "core"},
+	}
+	sorted, err := multimigration.SortModules(modules)
+	require.NoError(t, err)
+	var names []string
+	for _, module := range sorted {
+		names = append(names, module.Name)
+	}
+	require.Equal(t, []string{"audit", "core", "users", "billing"}, names)
+
+	selected, err := multimigration.SelectModules(modules, []string{"billing", "core"})
+	require.NoError(t, err)
+	require.Len(t, selected, 2)
+	require.Equal(t, "core", selected[0].Name)
+	require.Equal(t, "billing", selected[1].Name)
+
+	_, err = multimigration.SelectModules(modules, []string{"unknown"})
+	require.ErrorIs(t, err, migrationerrors.ErrUsage)
+}
+
+func TestSortModules_Cycle(t *testing.T) {
+	_, err := multimigration.SortModules([]*multimigration.Module{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"a"}},
+	})
+	require.Error(t, err)
+
+	_, err = multimigration.SortModules([]*multimigration.Module{
+		{Name: "a", DependsOn: []string{"missing"}},
+	})
+	require.Error(t, err)
+}
+
+func newModule(t *testing.T, dsn string, name string, script string, dependsOn ...string) *multimigration.Module {
+	scriptsInRoot := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.MkdirAll(scriptsInRoot, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00001_init.up.sql"), []byte(script), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsInRoot, "00001_init.down.sql"), []byte(""), 0644))
+
+	param := migrationparam.NewMigrationParam(
+		func() *gorm.DB {
+			return rese.P1(gorm.Open(sqlite.Open(dsn), &gorm.Config{}))
+		},
+		func(db *gorm.DB) *migrate.Migrate {
+			migrationDriver := rese.V1(sqlite3.WithInstance(rese.P1(db.DB()), &sqlite3.Config{
+				MigrationsTable: multimigration.MigrationsTable(name),
+			}))
+			return rese.P1(newmigrate.NewWithScriptsAndDatabase(&newmigrate.ScriptsAndDatabaseParam{
+				ScriptsInRoot:    scriptsInRoot,
+				DatabaseName:     "sqlite3",
+				DatabaseInstance: migrationDriver,
+			}))
+		},
+	)
+	return &multimigration.Module{
+		Name:          name,
+		DependsOn:     dependsOn,
+		Param:         param,
+		ScriptsInRoot: scriptsInRoot,
+	}
+}
+
+func TestNewModulesCmd(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "modules.db")
+	config := &multimigration.Config{
+		Modules: []*multimigration.Module{
+			newModule(t, dsn, "plugin", "CREATE TABLE `plugin_items` (`id` integer, `user_id` integer REFERENCES `users`(`id`));\n", "core"),
+			newModule(t, dsn, "core", "CREATE TABLE `users` (`id` integer PRIMARY KEY);\n"),
+		},
+	}
+
+	cmd := multimigration.NewModulesCmd(config)
+	cmd.SetArgs([]string{"all"})
+	require.NoError(t, cmd.Execute())
+
+	db := rese.P1(gorm.Open(sqlite.Open(dsn), &gorm.Config{}))
+	defer rese.F0(rese.P1(db.DB()).Close)
+	require.True(t, db.Migrator().HasTable("users"))
+	require.True(t, db.Migrator().HasTable("plugin_items"))
+
+	for _, name := range []string{"core", "plugin"} {
+		var version uint
+		require.NoError(t, db.Table(multimigration.MigrationsTable(name)).Select("version").Scan(&version).Error)
+		require.Equal(t, uint(1), version)
+	}
+}
+
+func TestNewModulesCmd_Failure(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "modules.db")
+	config := &multimigration.Config{
+		Modules: []*multimigration.Module{
+			newModule(t, dsn, "core", "CREATE TABLE `users` (`id` integer PRIMARY KEY);\n"),
+			newModule(t, dsn, "broken", "CREATE TABLE `users` (`id` integer PRIMARY KEY);\n", "core"),
+			newModule(t, dsn, "plugin", "CREATE TABLE `plugin_items` (`id` integer);\n", "broken"),
+		},
+	}
+
+	cmd := multimigration.NewModulesCmd(config)
+	cmd.SetArgs([]string{"all", "--module", "unknown"})
+	require.ErrorIs(t, cmd.Execute(), migrationerrors.ErrUsage)
+
+	// Modules after the failing one are not migrated
+	// 失败模块之后的模块不会被迁移
+	cmd = multimigration.NewModulesCmd(config)
+	cmd.SetArgs([]string{"all"})
+	require.Error(t, cmd.Execute())
+
+	db := rese.P1(gorm.Open(sqlite.Open(dsn), &gorm.Config{}))
+	defer rese.F0(rese.P1(db.DB()).Close)
+	require.True(t, db.Migrator().HasTable("users"))
+	require.False(t, db.Migrator().HasTable("plugin_items"))
+}
@@ -0,0 +1,201 @@
+package newscripts
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+)
+
+// Severity represents how serious a reported script problem is
+//
+// Severity 表示报告的脚本问题的严重程度
+type Severity string
+
+const (
+	SeverityError   Severity = "ERROR"   // Problem that breaks migrations // 会导致迁移失败的问题
+	SeverityWarning Severity = "WARNING" // Problem that needs attention // 需要关注的问题
+	SeverityInfo    Severity = "INFO"    // Notice that is usually harmless // 通常无害的提示
+)
+
+// ScriptProblem describes a single problem found in scripts DIR
+//
+// ScriptProblem 描述在脚本 DIR 中发现的单个问题
+type ScriptProblem struct {
+	Severity Severity // Problem severity // 问题严重程度
+	Name     string   // Script filename, empty when problem concerns the DIR // 脚本文件名，问题涉及整个 DIR 时为空
+	Message  string   // Problem description // 问题描述
+}
+
+// CheckReport collects problems found when checking scripts DIR
+//
+// CheckReport 收集检查脚本 DIR 时发现的问题
+type CheckReport struct {
+	Problems []*ScriptProblem // Problems in discovery sequence // 按发现顺序排列的问题
+}
+
+// add appends a problem to report
+//
+// add 向报告追加一个问题
+func (report *CheckReport) add(severity Severity, name string, format string, args ...any) {
+	report.Problems = append(report.Problems, &ScriptProblem{
+		Severity: severity,
+		Name:     name,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// HasErrors reports whether any problem has error severity
+//
+// HasErrors 判断是否存在错误级别的问题
+func (report *CheckReport) HasErrors() bool {
+	for _, problem := range report.Problems {
+		if problem.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckScripts validates structure of scripts DIR and reports every problem found
+// Covers unparsable names, duplicate versions, missing pairs, mixed suffixes, gaps,
+// empty up scripts, leftover TODO placeholders and non-UTF-8 content
+//
+// CheckScripts 校验脚本 DIR 的结构并报告发现的所有问题
+// 覆盖无法解析的文件名、重复版本、缺失配对、混合后缀、版本间隙、
+// 空的正向脚本、遗留的 TODO 占位符以及非 UTF-8 内容
+func CheckScripts(options *Options) (*CheckReport, error) {
+	report := &CheckReport{}
+	files, badNames, err := scanScriptFiles(options)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	for _, name := range badNames {
+		report.add(SeverityError, name, "filename does not match pattern {version}_{title}.{up|down}.{suffix}")
+	}
+
+	type versionPair struct {
+		forwards []string
+		reverses []string
+	}
+	pairs := map[uint]*versionPair{}
+	suffixes := map[string][]string{}
+	versionTexts := map[uint]string{}
+	for _, file := range files {
+		versionTexts[file.Migration.Version] = file.VersionText
+		pair, ok := pairs[file.Migration.Version]
+		if !ok {
+			pair = &versionPair{}
+			pairs[file.Migration.Version] = pair
+		}
+		if file.Migration.Direction == source.Up {
+			pair.forwards = append(pair.forwards, file.Migration.Raw)
+		} else {
+			pair.reverses = append(pair.reverses, file.Migration.Raw)
+		}
+		if matches := source.DefaultRegex.FindStringSubmatch(file.Migration.Raw); len(matches) == 5 {
+			suffixes[matches[4]] = append(suffixes[matches[4]], file.Migration.Raw)
+		}
+
+		content, err := options.getScriptsFS().ReadFile(filepath.Join(options.ScriptsInRoot, file.Migration.Raw))
+		if err != nil {
+			return nil, erero.Wro(err)
+		}
+		checkScriptContent(report, file.Migration, content)
+	}
+
+	versions := make([]uint, 0, len(pairs))
+	for version := range pairs {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+	for _, version := range versions {
+		pair := pairs[version]
+		if len(pair.forwards) > 1 || len(pair.reverses) > 1 {
+			report.add(SeverityError, "", "duplicate version %d: %v", version, append(pair.forwards, pair.reverses...))
+		}
+		if len(pair.forwards) == 0 {
+			report.add(SeverityError, strings.Join(pair.reverses, ","), "missing up script of version %d", version)
+		}
+		if len(pair.reverses) == 0 {
+			report.add(SeverityWarning, strings.Join(pair.forwards, ","), "missing down script of version %d", version)
+		}
+	}
+
+	if len(suffixes) > 1 {
+		keys := make([]string, 0, len(suffixes))
+		for suffix := range suffixes {
+			keys = append(keys, suffix)
+		}
+		sort.Strings(keys)
+		report.add(SeverityWarning, "", "mixed script suffixes: %v", keys)
+	}
+
+	// Gaps only make sense with sequential numbering, timestamps always have gaps
+	// 版本间隙只对顺序编号有意义，时间戳总是存在间隙
+	for idx := 1; idx < len(versions); idx++ {
+		previous, version := versions[idx-1], versions[idx]
+		if isTimestampVersion(versionTexts[previous]) || isTimestampVersion(versionTexts[version]) {
+			continue
+		}
+		if version-previous > 1 {
+			report.add(SeverityInfo, "", "version gap between %d and %d", previous, version)
+		}
+	}
+	return report, nil
+}
+
+// isTimestampVersion reports whether version text matches one of the time based version patterns
+// Uses the generator matchers, so the decision follows the patterns that created the scripts
+//
+// isTimestampVersion 判断版本文本是否匹配某个基于时间的版本模式
+// 使用生成器的匹配器判断，使结论与生成脚本的模式保持一致
+func isTimestampVersion(versionText string) bool {
+	for _, versionType := range []VersionPattern{VersionUnix, VersionTime, VersionDateSeq} {
+		generator, ok := LookupVersionGenerator(versionType)
+		if !ok {
+			continue
+		}
+		if matcher, ok := generator.(VersionMatcher); ok && matcher.MatchVersion(versionText) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkScriptContent checks content of one script file
+//
+// checkScriptContent 检查单个脚本文件的内容
+func checkScriptContent(report *CheckReport, migration *source.Migration, content []byte) {
+	if !utf8.Valid(content) {
+		report.add(SeverityError, migration.Raw, "content is not valid UTF-8")
+		return
+	}
+	script := string(content)
+	if checkmigration.ContainsRaiseStatement(script) {
+		report.add(SeverityError, migration.Raw, "contains TODO placeholder of unimplemented reverse statement")
+	}
+	if migration.Direction == source.Up && isBlankScript(script) {
+		report.add(SeverityWarning, migration.Raw, "up script has no statements")
+	}
+}
+
+// isBlankScript reports whether script contains only blank lines and line comments
+//
+// isBlankScript 判断脚本是否只包含空行和行注释
+func isBlankScript(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package newscripts_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/neatjson/neatjsons"
+)
+
+func TestCheckScripts(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(name string, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+	}
+	writeFile("00001_create_users.up.sql", "CREATE TABLE `users` (`id` integer PRIMARY KEY);\n")
+	writeFile("00001_create_users.down.sql", "DROP TABLE `users`;\n")
+	writeFile("00002_alter_users.up.sql", "-- nothing yet\n")
+	writeFile("00002_alter_users.down.sql", "SELECT TODO / PANIC / RAISE / THROW; -- DROP INDEX; -- TODO\n")
+	writeFile("00004_create_posts.up.sql", "CREATE TABLE `posts` (`id` integer PRIMARY KEY);\n")
+	writeFile("00005_create_tags.up.txt", "CREATE TABLE `tags` (`id` integer PRIMARY KEY);\n")
+	writeFile("00005_create_tags.down.txt", "DROP TABLE `tags`;\n")
+	writeFile("00006_binary.up.sql", "\xff\xfe\n")
+	writeFile("00006_binary.down.sql", "\n")
+	writeFile("README.md", "scripts\n")
+
+	report, err := newscripts.CheckScripts(newscripts.NewOptions(root))
+	require.NoError(t, err)
+	t.Log(neatjsons.S(report))
+	require.True(t, report.HasErrors())
+
+	messages := map[newscripts.Severity]int{}
+	for _, problem := range report.Problems {
+		messages[problem.Severity]++
+	}
+	require.Equal(t, 3, messages[newscripts.SeverityError])   // README.md, TODO placeholder, non-UTF-8
+	require.Equal(t, 3, messages[newscripts.SeverityWarning]) // empty up, missing down, mixed suffixes
+	require.Equal(t, 1, messages[newscripts.SeverityInfo])    // gap between 2 and 4
+}
+
+func TestCheckScripts_TimestampGaps(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(name string, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+	}
+	writeFile("20250621103045_create_users.up.sql", "CREATE TABLE `users` (`id` integer PRIMARY KEY);\n")
+	writeFile("20250621103045_create_users.down.sql", "DROP TABLE `users`;\n")
+	writeFile("20250622090000_create_posts.up.sql", "CREATE TABLE `posts` (`id` integer PRIMARY KEY);\n")
+	writeFile("20250622090000_create_posts.down.sql", "DROP TABLE `posts`;\n")
+
+	report, err := newscripts.CheckScripts(newscripts.NewOptions(root))
+	require.NoError(t, err)
+	require.Empty(t, report.Problems) // Timestamps always have gaps // 时间戳总是存在间隙
+}
@@ -1,14 +1,24 @@
 package newscripts
 
 import (
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/go-xlan/go-migrate/internal/outputs"
 	"github.com/go-xlan/go-migrate/internal/utils"
+	"github.com/go-xlan/go-migrate/migrationerrors"
 	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/yyle88/erero"
 	"github.com/yyle88/eroticgo"
 	"github.com/yyle88/must"
 	"github.com/yyle88/neatjson/neatjsons"
+	"github.com/yyle88/rese"
 	"github.com/yyle88/zaplog"
+	"gorm.io/gorm"
 )
 
 // Config contains all necessary components for migration script generation via CLI
@@ -19,11 +29,41 @@ import (
 // 使用 MigrationParam 接口统一管理连接和资源清理
 // 确保迁移操作完成后正确释放资源
 type Config struct {
-	Param   *migrationparam.MigrationParam // Migration connection // 迁移连接
-	Options *Options                       // Script generation options // 脚本生成选项
-	Objects []interface{}                  // GORM model objects for migration analysis // 用于迁移分析的 GORM 模型对象
+	Param        *migrationparam.MigrationParam // Migration connection // 迁移连接
+	Options      *Options                       // Script generation options // 脚本生成选项
+	Objects      []interface{}                  // GORM model objects for migration analysis // 用于迁移分析的 GORM 模型对象
+	NewScratchDB func() *gorm.DB                // Factory creating empty scratch database used by squash // 创建合并所用空临时数据库的工厂函数
+	SchemaDumper SchemaDumper                   // Custom schema dumper used by squash, optional // 合并所用的自定义结构导出器，可选
+}
+
+// withOutput returns a copy of config whose options write script diffs and analysis problems into writer
+//
+// withOutput 返回配置的副本，其选项将脚本差异和分析问题写入 writer
+func (config *Config) withOutput(writer io.Writer) *Config {
+	options := *config.Options
+	options.Output = writer
+	result := *config
+	result.Options = &options
+	return &result
 }
 
+var (
+	// ErrScriptPending reports that unmigrated scripts exist, so the top script should be updated instead
+	//
+	// ErrScriptPending 表示存在未迁移的脚本，应更新最新脚本而不是创建
+	ErrScriptPending = errors.WithMessage(migrationerrors.ErrScriptAction, "unmigrated scripts exist, update the top script instead")
+
+	// ErrNoScriptPending reports that no unmigrated script exists, so a new script should be created instead
+	//
+	// ErrNoScriptPending 表示不存在未迁移的脚本，应创建新脚本而不是更新
+	ErrNoScriptPending = errors.WithMessage(migrationerrors.ErrScriptAction, "no unmigrated script exists, create a new script instead")
+
+	// ErrLintFailed reports that generated scripts break static analysis rules
+	//
+	// ErrLintFailed 表示生成的脚本违反静态分析规则
+	ErrLintFailed = errors.WithMessage(migrationerrors.ErrCheckFailed, "scripts break static analysis rules")
+)
+
 // NewScriptCmd creates the main command for migration script management with subcommands
 // Provides root command that displays current migration status and script information
 // Includes create and update subcommands for comprehensive script management
@@ -34,53 +74,67 @@ type Config struct {
 func NewScriptCmd(config *Config) *cobra.Command {
 	// Create root command
 	var rootCmd = &cobra.Command{
-		Use:     "new-script",
-		Short:   "Create next migration script",
-		Long:    "Create next migration script",
-		Aliases: []string{"next-script"},
-		Args:    cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := config.Param.GetMigration()
-			defer cleanup()
-
-			version, dirtyFlag, err := migration.Version()
-			utils.WhistleCause(err) //panic when cause is not expected
-			if dirtyFlag {
-				eroticgo.RED.ShowMessage(version, "(DIRTY)")
-			} else {
-				eroticgo.GREEN.ShowMessage(version)
-			}
-
-			scriptInfo := GetNewScriptInfo(migration, config.Options, NewScriptNaming())
-			zaplog.SUG.Infoln("new-script-info:", neatjsons.S(scriptInfo))
-
-			db, cleanup2 := config.Param.GetDB()
-			defer cleanup2()
-			migrationOps := checkmigration.GetMigrateOps(db, config.Objects)
-			if len(migrationOps) > 0 {
-				if forwardScript := migrationOps.GetForwardScript(); true {
-					zaplog.SUG.Debugln(eroticgo.GREEN.Sprint(forwardScript))
-				}
-				if reverseScript, ok := migrationOps.GetReverseScript(); ok {
-					zaplog.SUG.Debugln(eroticgo.AMBER.Sprint(reverseScript))
-				}
-			}
-			eroticgo.GREEN.ShowMessage("SUCCESS")
+		Use:          "new-script",
+		Short:        "Create next migration script",
+		Long:         "Create next migration script",
+		Aliases:      []string{"next-script"},
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				migration, cleanup := config.Param.GetMigration()
+				defer cleanup()
+
+				version, dirtyFlag, err := migration.Version()
+				if err := utils.WhistleCauseE(err); err != nil {
+					return nil, err
+				}
+				if dirtyFlag {
+					outputs.ShowMessage(writer, eroticgo.RED, version, "(DIRTY)")
+				} else {
+					outputs.ShowMessage(writer, eroticgo.GREEN, version)
+				}
+
+				scriptInfo, err := GetNewScriptInfo(migration, config.Options, NewScriptNaming())
+				if err != nil {
+					return nil, err
+				}
+				zaplog.SUG.Infoln("new-script-info:", neatjsons.S(scriptInfo))
+
+				db, cleanup2 := config.Param.GetDB()
+				defer cleanup2()
+				migrationOps := checkmigration.GetMigrateOps(db, config.Objects)
+				if len(migrationOps) > 0 {
+					if forwardScript := migrationOps.GetForwardScript(); true {
+						zaplog.SUG.Debugln(eroticgo.GREEN.Sprint(forwardScript))
+					}
+					if reverseScript, ok := migrationOps.GetReverseScript(); ok {
+						zaplog.SUG.Debugln(eroticgo.AMBER.Sprint(reverseScript))
+					}
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return scriptInfo, nil
+			})
 		},
 	}
+	outputs.AddFlag(rootCmd)
 
 	rootCmd.AddCommand(createNewScriptCmd(config)) // Add `create` command
 	rootCmd.AddCommand(updateTopScriptCmd(config)) // Add `update` command
+	rootCmd.AddCommand(squashScriptsCmd(config))   // Add `squash` command
+	rootCmd.AddCommand(rebaseScriptsCmd(config))   // Add `rebase` command
+	rootCmd.AddCommand(checkScriptsCmd(config))    // Add `check` command
+	rootCmd.AddCommand(lintScriptsCmd(config))     // Add `lint` command
 
 	return rootCmd
 }
 
 // createNewScriptCmd creates command for generating new migration scripts with version control
-// Supports multiple version patterns (NEXT, UNIX, TIME) and custom descriptions
+// Supports registered version patterns (NEXT, UNIX, TIME, DATESEQ and custom ones) and custom descriptions
 // Validates that new scripts should be created rather than updated
 //
 // createNewScriptCmd 创建用于生成带版本控制的新迁移脚本的命令
-// 支持多种版本模式（NEXT、UNIX、TIME）和自定义描述
+// 支持已注册的版本模式（NEXT、UNIX、TIME、DATESEQ 及自定义模式）和自定义描述
 // 验证应该创建新脚本而不是更新现有脚本
 func createNewScriptCmd(config *Config) *cobra.Command {
 	var versionTypeInput string
@@ -88,56 +142,114 @@ func createNewScriptCmd(config *Config) *cobra.Command {
 	var allowEmptyScript bool
 
 	cmd := &cobra.Command{
-		Use:   "create",
-		Short: "create new migration script",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := config.Param.GetMigration()
-			defer cleanup()
-
-			// 将字符串转换为 VersionPattern 枚举
-			versionType := parseVersionType(versionTypeInput)
-
-			// 创建 ScriptNaming（传入参数）
-			scriptNaming := &ScriptNaming{
-				VersionType: versionType,
-				Description: descriptionTitle,
-			}
-			zaplog.SUG.Infoln("script-naming:", neatjsons.S(scriptNaming))
-
-			// 获取下一组脚本名
-			scriptInfo := GetNewScriptInfo(migration, config.Options, scriptNaming)
-			zaplog.SUG.Infoln("script-names:", neatjsons.S(scriptInfo.GetScriptNames()))
-
-			// 假设系统建议你更新最新的脚本内容，而你选择的是创建，就报错
-			if scriptInfo.Action == UpdateScript {
-				eroticgo.RED.ShowMessage("FAILED. Use [update script] when THERE ARE UNMIGRATED SCRIPTS.")
-				zaplog.SUG.Infoln(eroticgo.RED.Sprint("FAILED"))
-				return
-			}
-			// 需要符合预期-避免出现其它情况，比如既非创建也非更新的其它情况
-			must.Same(scriptInfo.Action, CreateScript)
-
-			// 获取迁移操作并生成文件
-			db, cleanup2 := config.Param.GetDB()
-			defer cleanup2()
-			migrateOps := checkmigration.GetMigrateOps(db, config.Objects)
-			if len(migrateOps) > 0 || allowEmptyScript || scriptInfo.ScriptExists(config.Options) {
-				scriptInfo.WriteScripts(migrateOps, config.Options)
-			}
-
-			eroticgo.GREEN.ShowMessage("SUCCESS")
+		Use:          "create",
+		Short:        "create new migration script",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				// 创建 ScriptNaming（传入参数），将字符串转换为 VersionPattern 枚举，为空时沿用已有脚本的模式
+				var versionType VersionPattern
+				if versionTypeInput != "" {
+					var err error
+					versionType, err = parseVersionType(versionTypeInput)
+					if err != nil {
+						outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Unknown version-type:", versionTypeInput)
+						return nil, err
+					}
+				}
+				scriptNaming := &ScriptNaming{
+					VersionType: versionType,
+					Description: descriptionTitle,
+				}
+				zaplog.SUG.Infoln("script-naming:", neatjsons.S(scriptNaming))
+
+				result, err := CreateNewScript(config.withOutput(writer), scriptNaming, allowEmptyScript)
+				switch {
+				case errors.Is(err, ErrScriptPending):
+					// 假设系统建议你更新最新的脚本内容，而你选择的是创建，就报错
+					outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Use [update script] when THERE ARE UNMIGRATED SCRIPTS.")
+					zaplog.SUG.Infoln(eroticgo.RED.Sprint("FAILED"))
+					return nil, err
+				case errors.Is(err, ErrLintFailed):
+					outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Scripts break static analysis rules.")
+					return nil, err
+				case err != nil:
+					return nil, err
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return result, nil
+			})
 		},
 	}
 
 	// 增加 flag 参数
-	cmd.Flags().StringVar(&versionTypeInput, "version-type", "NEXT", "version pattern: NEXT, UNIX, TIME")
+	cmd.Flags().StringVar(&versionTypeInput, "version-type", string(config.Options.VersionType), "version pattern: "+strings.Join(VersionGeneratorNames(), ", ")+", empty takes pattern of existing scripts")
 	cmd.Flags().StringVar(&descriptionTitle, "description", "script", "migration script description name")
 	cmd.Flags().BoolVar(&allowEmptyScript, "allow-empty-script", false, "allow creating script when no schema changes")
+	cmd.Flags().BoolVar(&config.Options.AutoVersionType, "auto-version-type", config.Options.AutoVersionType, "use version pattern of existing scripts when version-type conflicts")
+	cmd.Flags().BoolVar(&config.Options.WriteHeader, "write-header", config.Options.WriteHeader, "prepend metadata header comment to scripts")
 
 	return cmd
 }
 
+// CreateNewScript generates next migration scripts from schema differences of config objects
+// Returns names of the scripts, ErrScriptPending when unmigrated scripts exist and ErrLintFailed when scripts break rules
+//
+// CreateNewScript 根据配置对象的结构差异生成下一组迁移脚本
+// 返回脚本名称，存在未迁移脚本时返回 ErrScriptPending，脚本违反规则时返回 ErrLintFailed
+func CreateNewScript(config *Config, scriptNaming *ScriptNaming, allowEmptyScript bool) (*WriteResult, error) {
+	migration, cleanup := config.Param.GetMigration()
+	defer cleanup()
+
+	// 获取下一组脚本名
+	scriptInfo, err := GetNewScriptInfo(migration, config.Options, scriptNaming)
+	if err != nil {
+		return nil, err
+	}
+	zaplog.SUG.Infoln("script-names:", neatjsons.S(scriptInfo.GetScriptNames()))
+	if scriptInfo.Action == UpdateScript {
+		return nil, erero.Wro(ErrScriptPending)
+	}
+	// 需要符合预期-避免出现其它情况，比如既非创建也非更新的其它情况
+	must.Same(scriptInfo.Action, CreateScript)
+
+	result := &WriteResult{
+		Action:      scriptInfo.Action,
+		ForwardName: scriptInfo.ForwardName,
+		ReverseName: scriptInfo.ReverseName,
+	}
+
+	// 获取迁移操作并生成文件
+	db, cleanup2 := config.Param.GetDB()
+	defer cleanup2()
+	migrateOps := checkmigration.GetMigrateOps(db, config.Objects)
+	scriptInfo.Meta = NewScriptMeta(db, config.Objects)
+
+	// 写入前对脚本正文做静态分析，存在错误时拒绝写入
+	forwardScript, reverseScript, err := scriptInfo.RenderScripts(migrateOps, config.Options)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	problems := LintScript(scriptInfo.ForwardName, forwardScript, reverseScript, config.Options.Rules)
+	showProblems(config.Options.getOutput(), problems)
+	if (&CheckReport{Problems: problems}).HasErrors() {
+		return nil, erero.Wro(ErrLintFailed)
+	}
+
+	if len(migrateOps) > 0 || allowEmptyScript || scriptInfo.ScriptExists(config.Options) {
+		result.WrittenNames = scriptInfo.WriteScripts(migrateOps, config.Options)
+		databaseVersion, err := ReadDatabaseVersion(migration)
+		if err != nil {
+			return nil, erero.Wro(err)
+		}
+		if err := WriteManifest(config.Options, databaseVersion); err != nil {
+			return nil, erero.Wro(err)
+		}
+	}
+	return result, nil
+}
+
 // updateTopScriptCmd creates command for updating the latest uncommitted migration script
 // Updates existing script files with current database schema differences
 // Validates that scripts exist and should be updated rather than newly created
@@ -146,33 +258,266 @@ func createNewScriptCmd(config *Config) *cobra.Command {
 // 使用当前数据库结构差异更新现有脚本文件
 // 验证脚本存在并应该被更新而不是新创建
 func updateTopScriptCmd(config *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "update",
+		Short:        "update top migration script",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				result, err := UpdateTopScript(config.withOutput(writer))
+				if errors.Is(err, ErrNoScriptPending) {
+					// 假设系统建议你创建最脚本内容，而你选择的是更新旧文件，就报错
+					outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Use [create script] when THERE ARE NO UNMIGRATED SCRIPTS.")
+					zaplog.SUG.Infoln(eroticgo.RED.Sprint("FAILED"))
+					return nil, err
+				}
+				if err != nil {
+					return nil, err
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return result, nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&config.Options.WriteHeader, "write-header", config.Options.WriteHeader, "prepend metadata header comment to scripts")
+	return cmd
+}
+
+// UpdateTopScript rewrites the latest unmigrated scripts with current schema differences
+// Returns names of the scripts, ErrNoScriptPending when there is no unmigrated script to update
+//
+// UpdateTopScript 使用当前结构差异重写最新的未迁移脚本
+// 返回脚本名称，没有可更新的未迁移脚本时返回 ErrNoScriptPending
+func UpdateTopScript(config *Config) (*WriteResult, error) {
+	migration, cleanup := config.Param.GetMigration()
+	defer cleanup()
+
+	scriptInfo, err := GetNewScriptInfo(migration, config.Options, NewScriptNaming())
+	if err != nil {
+		return nil, err
+	}
+	zaplog.SUG.Infoln("script-names:", neatjsons.S(scriptInfo.GetScriptNames()))
+	if scriptInfo.Action == CreateScript {
+		return nil, erero.Wro(ErrNoScriptPending)
+	}
+	// 需要符合预期-避免出现其它情况，比如既非创建也非更新的其它情况
+	must.Same(scriptInfo.Action, UpdateScript)
+
+	result := &WriteResult{
+		Action:      scriptInfo.Action,
+		ForwardName: scriptInfo.ForwardName,
+		ReverseName: scriptInfo.ReverseName,
+	}
+
+	db, cleanup2 := config.Param.GetDB()
+	defer cleanup2()
+	migrateOps := checkmigration.GetMigrateOps(db, config.Objects)
+	scriptInfo.Meta = NewScriptMeta(db, config.Objects) // Refresh header when script is rewritten // 重写脚本时刷新头部
+	if len(migrateOps) > 0 || scriptInfo.ScriptExists(config.Options) {
+		result.WrittenNames = scriptInfo.WriteScripts(migrateOps, config.Options)
+		databaseVersion, err := ReadDatabaseVersion(migration)
+		if err != nil {
+			return nil, erero.Wro(err)
+		}
+		if err := WriteManifest(config.Options, databaseVersion); err != nil {
+			return nil, erero.Wro(err)
+		}
+	}
+	return result, nil
+}
+
+// squashScriptsCmd creates command for collapsing old scripts into a single baseline script
+// Applies scripts to scratch database, dumps the schema and archives the squashed files
+// Only safe when every deployed database has reached the through version
+//
+// squashScriptsCmd 创建将旧脚本合并为单个基线脚本的命令
+// 在临时数据库上执行脚本，导出结构并归档被合并的文件
+// 只有当所有已部署的数据库都达到 through 版本时才是安全的
+func squashScriptsCmd(config *Config) *cobra.Command {
+	var throughVersion uint
+
+	cmd := &cobra.Command{
+		Use:          "squash",
+		Short:        "squash scripts through version into baseline script",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				if config.NewScratchDB == nil {
+					outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Squash needs NewScratchDB in config to build the baseline.")
+					return nil, erero.New("squash needs NewScratchDB in config")
+				}
+
+				migration, cleanup := config.Param.GetMigration()
+				defer cleanup()
+
+				version, dirtyFlag, err := migration.Version()
+				if err := utils.WhistleCauseE(err); err != nil {
+					return nil, err
+				}
+				if dirtyFlag {
+					outputs.ShowMessage(writer, eroticgo.RED, version, "(DIRTY)", "FAILED. Repair the database before squashing.")
+					return nil, erero.Wrapf(migrationerrors.ErrDirty, "version=%d", version)
+				}
+
+				scratchDB := config.NewScratchDB()
+				defer rese.F0(rese.P1(scratchDB.DB()).Close)
+
+				result, err := SquashScripts(scratchDB, config.SchemaDumper, version, throughVersion, config.Options)
+				if errors.Is(err, ErrSquashAhead) {
+					outputs.ShowMessage(writer, eroticgo.RED, "FAILED. Database version", version, "has not reached", throughVersion)
+					outputs.ShowMessage(writer, eroticgo.RED, "Migrate every deployed database to at least", throughVersion, "before squashing.")
+					return nil, err
+				}
+				if err != nil {
+					return nil, err
+				}
+				zaplog.SUG.Infoln("squash-result:", neatjsons.S(result))
+
+				outputs.ShowMessage(writer, eroticgo.AMBER, "NOTICE: baseline keeps version", throughVersion, "- databases at or past it are not affected.")
+				outputs.ShowMessage(writer, eroticgo.AMBER, "NOTICE: databases below", throughVersion, "can no longer migrate incrementally, they must be migrated before deploying.")
+
+				if err := result.Apply(config.Options); err != nil {
+					return nil, err
+				}
+				if err := WriteManifest(config.Options, int(version)); err != nil {
+					return nil, err
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return result, nil
+			})
+		},
+	}
+
+	cmd.Flags().UintVar(&throughVersion, "through", 0, "last version to squash into baseline script")
+	must.Done(cmd.MarkFlagRequired("through"))
+
+	return cmd
+}
+
+// rebaseScriptsCmd creates command for renumbering colliding scripts after branch merges
+// Moves duplicate or late-arriving unapplied scripts after the highest version
+// Renames both up and down files and keeps their descriptions
+//
+// rebaseScriptsCmd 创建在分支合并后重新编号冲突脚本的命令
+// 将重复或后到的未应用脚本移动到最高版本之后
+// 同时重命名正向和反向文件并保留描述
+func rebaseScriptsCmd(config *Config) *cobra.Command {
+	return &cobra.Command{
+		Use:          "rebase",
+		Short:        "renumber colliding unapplied scripts",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				migration, cleanup := config.Param.GetMigration()
+				defer cleanup()
+
+				version, dirtyFlag, err := migration.Version()
+				if err := utils.WhistleCauseE(err); err != nil {
+					return nil, err
+				}
+				if dirtyFlag {
+					outputs.ShowMessage(writer, eroticgo.RED, version, "(DIRTY)", "FAILED. Repair the database before rebasing.")
+					return nil, erero.Wrapf(migrationerrors.ErrDirty, "version=%d", version)
+				}
+
+				plan, err := PlanRebase(version, config.Options)
+				if err != nil {
+					return nil, err
+				}
+				if len(plan.Moves) == 0 {
+					outputs.ShowMessage(writer, eroticgo.GREEN, "NOTHING TO REBASE")
+					return plan, nil
+				}
+				for _, move := range plan.Moves {
+					outputs.ShowMessage(writer, eroticgo.AMBER, move.Version, "->", move.NewVersion, move.Description)
+				}
+				zaplog.SUG.Infoln("rebase-plan:", neatjsons.S(plan))
+
+				if err := plan.Apply(config.Options); err != nil {
+					return nil, err
+				}
+				if err := WriteManifest(config.Options, int(version)); err != nil {
+					return nil, err
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return plan, nil
+			})
+		},
+	}
+}
+
+// checkScriptsCmd creates command for validating structure of scripts DIR
+// Prints every problem with severity and exits non-zero when errors exist
+//
+// checkScriptsCmd 创建校验脚本 DIR 结构的命令
+// 输出每个问题及其严重程度，存在错误时以非零状态退出
+func checkScriptsCmd(config *Config) *cobra.Command {
+	return &cobra.Command{
+		Use:          "check",
+		Short:        "check scripts DIR for structural problems",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				report, err := CheckScripts(config.Options)
+				if err != nil {
+					return nil, err
+				}
+				showProblems(writer, report.Problems)
+				if report.HasErrors() {
+					return report, erero.Wrapf(migrationerrors.ErrCheckFailed, "scripts check found %d problems", len(report.Problems))
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return report, nil
+			})
+		},
+	}
+}
+
+// lintScriptsCmd creates command for applying static analysis rules to script bodies
+// Prints every problem with severity and exits non-zero when errors exist
+//
+// lintScriptsCmd 创建对脚本正文应用静态分析规则的命令
+// 输出每个问题及其严重程度，存在错误时以非零状态退出
+func lintScriptsCmd(config *Config) *cobra.Command {
 	return &cobra.Command{
-		Use:   "update",
-		Short: "update top migration script",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := config.Param.GetMigration()
-			defer cleanup()
-
-			scriptInfo := GetNewScriptInfo(migration, config.Options, NewScriptNaming())
-			zaplog.SUG.Infoln("script-names:", neatjsons.S(scriptInfo.GetScriptNames()))
-
-			// 假设系统建议你创建最脚本内容，而你选择的是更新旧文件，就报错
-			if scriptInfo.Action == CreateScript {
-				eroticgo.RED.ShowMessage("FAILED. Use [create script] when THERE ARE NO UNMIGRATED SCRIPTS.")
-				zaplog.SUG.Infoln(eroticgo.RED.Sprint("FAILED"))
-				return
-			}
-			// 需要符合预期-避免出现其它情况，比如既非创建也非更新的其它情况
-			must.Same(scriptInfo.Action, UpdateScript)
-
-			db, cleanup2 := config.Param.GetDB()
-			defer cleanup2()
-			migrateOps := checkmigration.GetMigrateOps(db, config.Objects)
-			if len(migrateOps) > 0 || scriptInfo.ScriptExists(config.Options) {
-				scriptInfo.WriteScripts(migrateOps, config.Options)
-			}
-			eroticgo.GREEN.ShowMessage("SUCCESS")
+		Use:          "lint",
+		Short:        "check script bodies with static analysis rules",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				report, err := LintScripts(config.Options)
+				if err != nil {
+					return nil, err
+				}
+				showProblems(writer, report.Problems)
+				if report.HasErrors() {
+					return report, erero.Wrapf(migrationerrors.ErrCheckFailed, "scripts lint found %d problems", len(report.Problems))
+				}
+				outputs.ShowMessage(writer, eroticgo.GREEN, "SUCCESS")
+				return report, nil
+			})
 		},
 	}
 }
+
+// showProblems writes problems into writer colored by severity
+//
+// showProblems 按严重程度以不同颜色将问题写入 writer
+func showProblems(writer io.Writer, problems []*ScriptProblem) {
+	for _, problem := range problems {
+		message := fmt.Sprintf("[%s] %s %s", problem.Severity, problem.Name, problem.Message)
+		switch problem.Severity {
+		case SeverityError:
+			outputs.ShowMessage(writer, eroticgo.RED, message)
+		case SeverityWarning:
+			outputs.ShowMessage(writer, eroticgo.AMBER, message)
+		default:
+			outputs.ShowMessage(writer, eroticgo.BLUE, message)
+		}
+	}
+}
@@ -12,10 +12,13 @@ package newscripts
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/go-xlan/go-migrate/migrationerrors"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/pkg/errors"
@@ -25,7 +28,6 @@ import (
 	"github.com/yyle88/must/mustnum"
 	"github.com/yyle88/must/muststrings"
 	"github.com/yyle88/neatjson/neatjsons"
-	"github.com/yyle88/osexistpath/osmustexist"
 	"github.com/yyle88/rese"
 	"github.com/yyle88/rese/resb"
 	"github.com/yyle88/zaplog"
@@ -46,12 +48,12 @@ const (
 
 // GetNewScriptInfo analyzes current migration state and determines next script information
 // Examines existing migration files and database version to calculate appropriate next action
-// Returns script naming details and action type for migration script creation
+// Returns script naming details and action type, ErrUsage when the version pattern does not suit existing scripts
 //
 // GetNewScriptInfo 分析当前迁移状态并确定下一个脚本信息
 // 检查现有迁移文件和数据库版本来计算适当的下一步操作
-// 返回用于迁移脚本创建的脚本命名详情和操作类型
-func GetNewScriptInfo(migration *migrate.Migrate, options *Options, naming *ScriptNaming) *NewScriptInfo {
+// 返回脚本命名详情和操作类型，版本模式与已有脚本不符时返回 ErrUsage
+func GetNewScriptInfo(migration *migrate.Migrate, options *Options, naming *ScriptNaming) (*NewScriptInfo, error) {
 	var migrateState enumMigrateState
 	version, dirtyFlag, err := migration.Version()
 	if err != nil {
@@ -59,83 +61,174 @@ func GetNewScriptInfo(migration *migrate.Migrate, options *Options, naming *Scri
 			must.Zero(version)
 			migrateState = noneMigrated
 		} else {
-			panic(erero.Wro(err))
+			return nil, erero.Wro(err)
 		}
 	} else {
-		must.False(dirtyFlag)
+		if dirtyFlag {
+			return nil, erero.Wrapf(migrationerrors.ErrDirty, "database version %d is dirty, repair it before writing scripts", version)
+		}
 		migrateState = onceMigrated
 	}
 	must.Nice(migrateState)
 	mustnum.Gte(version, 0)
 
-	migrations := newMigrationsFromPath(options.ScriptsInRoot)
+	migrations, err := newMigrationsFromPath(options)
+	if err != nil {
+		return nil, err
+	}
 
-	nextVersion, nextAction := obtainNextVersion(migrateState, version, migrations, options)
+	nextVersion, nextAction, err := obtainNextVersion(migrateState, version, migrations, options)
+	if err != nil {
+		return nil, err
+	}
 	mustnum.Gt(nextVersion, version)
-	scriptNames := obtainScriptNames(nextVersion, nextAction, options, migrations, naming)
+	scriptNames, err := obtainScriptNames(version, nextVersion, nextAction, options, migrations, naming)
+	if err != nil {
+		return nil, err
+	}
 	checkScriptName(scriptNames, version)
 
 	zaplog.SUG.Debugln("next-action:", nextAction)
 	zaplog.SUG.Debugln("script-name:", neatjsons.S(scriptNames))
 
 	return &NewScriptInfo{
-		Action:      nextAction,
-		ForwardName: scriptNames.ForwardName,
-		ReverseName: scriptNames.ReverseName,
-	}
+		Action:          nextAction,
+		ForwardName:     scriptNames.ForwardName,
+		ReverseName:     scriptNames.ReverseName,
+		PreviousVersion: version,
+	}, nil
 }
 
 // newMigrationsFromPath scans DIR and builds migrations collection from script files
+// Skips files not matching the migration pattern, the same as golang-migrate sources
+// Returns ErrUsage when two scripts share version and direction
 //
 // newMigrationsFromPath 扫描 DIR 并从脚本文件构建迁移集合
-func newMigrationsFromPath(scriptsInRoot string) *source.Migrations {
+// 跳过不匹配迁移模式的文件，与 golang-migrate 的源一致
+// 两个脚本的版本和方向相同时返回 ErrUsage
+func newMigrationsFromPath(options *Options) (*source.Migrations, error) {
+	entries, err := options.getScriptsFS().ReadDir(options.ScriptsInRoot)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
 	migrations := source.NewMigrations()
-	for _, e := range rese.V1(os.ReadDir(scriptsInRoot)) {
-		if e.IsDir() {
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == ManifestName {
 			continue
 		}
-		migration := rese.P1(source.DefaultParse(e.Name()))
+		migration, err := source.DefaultParse(e.Name())
+		if err != nil {
+			continue // Skip files that don't match migration pattern // 跳过不匹配迁移模式的文件
+		}
 		zaplog.SUG.Debugln("append migration to migrations:", "version:", migration.Version, "direction:", migration.Direction)
-		must.True(migrations.Append(migration))
+		if !migrations.Append(migration) {
+			return nil, erero.Wrapf(migrationerrors.ErrUsage, "duplicate %s script %s of version %d", migration.Direction, e.Name(), migration.Version)
+		}
 	}
-	return migrations
+	return migrations, nil
 }
 
 // mustWriteScript writes migration script to file system with validation and confirmation
 // Validates file paths and handles both create and update scenarios
-// Supports dry-run mode and interactive confirmation to ensure safe script generation
+// Shows unified diff against existing content, in dry-run mode as well
+// Supports interactive confirmation with the choice to edit content in $EDITOR before writing
 //
 // mustWriteScript 将迁移脚本写入文件系统，具有安全检查和用户确认
 // 验证文件路径并处理创建和更新场景
-// 支持试运行模式和交互式确认，实现安全的脚本生成
-func mustWriteScript(nextAction ScriptAction, shortName string, script string, options *Options) {
+// 显示与已有内容的统一差异，试运行模式下同样显示
+// 支持交互式确认，并可选择写入前在 $EDITOR 中编辑内容
+// Returns whether the file was written
+// 返回文件是否被写入
+func mustWriteScript(nextAction ScriptAction, shortName string, script string, options *Options) bool {
 	var path = filepath.Join(options.ScriptsInRoot, shortName)
+	var existing string
 	if nextAction == CreateScript {
-		must.False(osmustexist.IsFile(path))
+		must.False(isFile(options.getScriptsFS(), path))
 	} else {
 		must.Same(nextAction, UpdateScript)
-		osmustexist.FILE(path)
+		must.True(isFile(options.getScriptsFS(), path))
+		existing = string(rese.V1(options.getScriptsFS().ReadFile(path)))
 	}
 	zaplog.SUG.Debugln("path:", path, "script:", script)
+	if nextAction == UpdateScript && existing == script {
+		zaplog.SUG.Debugln("script not changed:", shortName)
+		return false
+	}
+	showDiff(options.getOutput(), UnifiedDiff(shortName, shortName, existing, script))
 	if options.DryRun {
 		zaplog.SUG.Debugln("dry-run mode", options.DryRun)
-		return
+		return false
 	}
 	if options.SurveyWritten {
-		var written bool
-		prompt := &survey.Confirm{
-			Message: "write script to path?",
-			Default: true,
-		}
-		done.Done(survey.AskOne(prompt, &written))
-		if !written {
-			zaplog.SUG.Debugln("input_written", written)
-			return
+		for {
+			var choice string
+			prompt := &survey.Select{
+				Message: "write script to path " + path + "?",
+				Options: []string{writeChoiceYes, writeChoiceNo, writeChoiceEdit},
+				Default: writeChoiceYes,
+			}
+			done.Done(survey.AskOne(prompt, &choice))
+			if choice == writeChoiceYes {
+				break
+			}
+			if choice == writeChoiceNo {
+				zaplog.SUG.Debugln("input_written", choice)
+				return false
+			}
+			script = rese.V1(editScript(shortName, script))
+			showDiff(options.getOutput(), UnifiedDiff(shortName, shortName, existing, script))
 		}
 	}
-	// when file exist WriteFile truncates it before writing, without changing permissions.
-	must.Done(os.WriteFile(path, []byte(script), 0644))
+	must.Done(options.getScriptsFS().WriteFile(path, []byte(script), 0644))
 	zaplog.SUG.Debugln("done")
+	return true
+}
+
+const (
+	writeChoiceYes  = "yes"  // Write script // 写入脚本
+	writeChoiceNo   = "no"   // Skip writing // 跳过写入
+	writeChoiceEdit = "edit" // Edit content in $EDITOR and ask again // 在 $EDITOR 中编辑内容后再次询问
+)
+
+// editScript opens script content in $EDITOR through a temp file and returns edited content
+// Falls back to vi when $EDITOR is not set
+//
+// editScript 通过临时文件在 $EDITOR 中打开脚本内容并返回编辑后的内容
+// 未设置 $EDITOR 时回退到 vi
+func editScript(shortName string, script string) (string, error) {
+	tempFile, err := os.CreateTemp("", "*-"+shortName)
+	if err != nil {
+		return "", erero.Wro(err)
+	}
+	defer func() {
+		if err := os.Remove(tempFile.Name()); err != nil {
+			zaplog.SUG.Errorln("remove temp file failed:", tempFile.Name(), err)
+		}
+	}()
+	if _, err := tempFile.WriteString(script); err != nil {
+		return "", erero.Wro(err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return "", erero.Wro(err)
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// $EDITOR may contain arguments, e.g., "code --wait"
+	// $EDITOR 可能包含参数，例如 "code --wait"
+	fields := strings.Fields(editor)
+	command := exec.Command(fields[0], append(fields[1:], tempFile.Name())...)
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := command.Run(); err != nil {
+		return "", erero.Wro(err)
+	}
+	content, err := os.ReadFile(tempFile.Name())
+	if err != nil {
+		return "", erero.Wro(err)
+	}
+	return string(content), nil
 }
 
 // checkScriptName validates script names match expected version sequence
@@ -163,11 +256,24 @@ type NewScriptNames struct {
 // obtainScriptNames generates script filenames based on action type and naming rules
 //
 // obtainScriptNames 基于操作类型和命名规则生成脚本文件名
-func obtainScriptNames(nextVersion uint, nextAction ScriptAction, options *Options, migrations *source.Migrations, naming *ScriptNaming) *NewScriptNames {
+func obtainScriptNames(previousVersion uint, nextVersion uint, nextAction ScriptAction, options *Options, migrations *source.Migrations, naming *ScriptNaming) (*NewScriptNames, error) {
 	var scriptNames = &NewScriptNames{}
 	switch nextAction {
 	case CreateScript:
-		prefix := naming.NewScriptPrefix(nextVersion)
+		// Refuse or auto-select when pattern conflicts with existing scripts
+		// 当模式与已有脚本冲突时拒绝或自动选择
+		versionType, err := resolveVersionType(naming.VersionType, migrations, options)
+		if err != nil {
+			return nil, err
+		}
+		naming = &ScriptNaming{
+			VersionType: versionType,
+			Description: naming.Description,
+		}
+		prefix, err := naming.NewScriptPrefixAfter(previousVersion, migrations)
+		if err != nil {
+			return nil, err
+		}
 		muststrings.Contains(prefix, "_")
 		must.True(regexp.MustCompile(`^([0-9]+)_(.*)$`).MatchString(prefix))
 		muststrings.NotContains(prefix, ".")
@@ -184,13 +290,22 @@ func obtainScriptNames(nextVersion uint, nextAction ScriptAction, options *Optio
 
 		scriptNames.ReverseName = fmt.Sprintf("%s.%s.%s", prefix, source.Down, suffix)
 		must.True(source.DefaultRegex.MatchString(scriptNames.ReverseName))
+
+		// New script must sort after every existing script, otherwise it would never run
+		// 新脚本必须排在所有已有脚本之后，否则永远不会被执行
+		if versions := listVersions(migrations); len(versions) > 0 {
+			newVersion := rese.P1(source.DefaultParse(scriptNames.ForwardName)).Version
+			if newVersion <= versions[len(versions)-1] {
+				return nil, erero.Wrapf(migrationerrors.ErrUsage, "new version %d does not sort after highest script version %d", newVersion, versions[len(versions)-1])
+			}
+		}
 	case UpdateScript:
 		scriptNames.ForwardName = resb.P1(migrations.Up(nextVersion)).Raw   // 123_name.up.ext
 		scriptNames.ReverseName = resb.P1(migrations.Down(nextVersion)).Raw // 123_name.down.ext
 	default:
 		panic(erero.Errorf("IMPOSSIBLE case-value=%v", nextAction))
 	}
-	return scriptNames
+	return scriptNames, nil
 }
 
 // obtainFirstUpScriptNameSuffix extracts file extension from first migration script
@@ -213,7 +328,7 @@ func obtainFirstUpScriptNameSuffix(migrations *source.Migrations) (string, bool)
 // obtainNextVersion determines next version number and action based on migration state
 //
 // obtainNextVersion 基于迁移状态确定下一个版本号和操作
-func obtainNextVersion(migrateState enumMigrateState, previousVersion uint, migrations *source.Migrations, options *Options) (uint, ScriptAction) {
+func obtainNextVersion(migrateState enumMigrateState, previousVersion uint, migrations *source.Migrations, options *Options) (uint, ScriptAction, error) {
 	var nextVersion uint
 	var ok bool
 	switch migrateState {
@@ -227,22 +342,27 @@ func obtainNextVersion(migrateState enumMigrateState, previousVersion uint, migr
 	}
 	if !ok {
 		must.Zero(nextVersion)
-		nextVersion = previousVersion + 1 // Return next version reference, can use timestamp etc. instead // 返回新版本号的参考值，当然后面也可以不使用这个参考值，而使用时间戳等版本号
-		return nextVersion, CreateScript  // No script found, need to create new one // 假如取不到，就说明需要新建个脚本写内容
+		nextVersion = previousVersion + 1     // Return next version reference, can use timestamp etc. instead // 返回新版本号的参考值，当然后面也可以不使用这个参考值，而使用时间戳等版本号
+		return nextVersion, CreateScript, nil // No script found, need to create new one // 假如取不到，就说明需要新建个脚本写内容
 	}
 	// if !options.ForceEdit {
-	mustNoNextNextVersion(migrations, nextVersion) // Ensure this version is the latest, not intermediate // 需要确认获得的这个版本号就是最高的，而不是中间的，你也只能修改最高的
+	// Ensure this version is the latest, not intermediate // 需要确认获得的这个版本号就是最高的，而不是中间的，你也只能修改最高的
+	if err := checkNoNextNextVersion(migrations, nextVersion); err != nil {
+		return 0, "", err
+	}
 	// }
-	return nextVersion, UpdateScript
+	return nextVersion, UpdateScript, nil
 }
 
-// mustNoNextNextVersion ensures no versions exist after the given version
+// checkNoNextNextVersion ensures no versions exist after the given version
+// Returns ErrScriptAction when more than one unmigrated script exists, since only the top one can be updated
 //
-// mustNoNextNextVersion 确保给定版本之后不存在其他版本
-func mustNoNextNextVersion(migrations *source.Migrations, nextVersion uint) {
+// checkNoNextNextVersion 确保给定版本之后不存在其他版本
+// 存在多个未迁移脚本时返回 ErrScriptAction，因为只能更新最高的脚本
+func checkNoNextNextVersion(migrations *source.Migrations, nextVersion uint) error {
 	nextNextVersion, ok := migrations.Next(nextVersion)
 	if !ok {
-		return // Expected: no version after this means this is the latest // 这才是我们需要的，即没有下下个版本号的时候，就认为下个版本号就是最新的版本号
+		return nil // Expected: no version after this means this is the latest // 这才是我们需要的，即没有下下个版本号的时候，就认为下个版本号就是最新的版本号
 	}
-	zaplog.LOG.Panic("script-is-not-latest-version", zap.Uint("next_version", nextVersion), zap.Uint("next_next_version", nextNextVersion))
+	return erero.Wrapf(migrationerrors.ErrScriptAction, "unmigrated script version %d is not the latest, version %d follows it, migrate it first", nextVersion, nextNextVersion)
 }
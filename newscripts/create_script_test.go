@@ -1 +1,104 @@
 package newscripts_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/newmigrate"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestNewScriptInfo_WriteScripts_DryRun(t *testing.T) {
+	root := t.TempDir()
+	options := newscripts.NewOptions(root)
+	options.ScriptsFS = newscripts.NewMemFS()
+
+	path := filepath.Join(root, "00001_script.up.sql")
+	require.NoError(t, options.ScriptsFS.WriteFile(path, []byte("SELECT 1;\n"), 0644))
+	require.NoError(t, options.ScriptsFS.WriteFile(filepath.Join(root, "00001_script.down.sql"), []byte(""), 0644))
+
+	scriptInfo := &newscripts.NewScriptInfo{
+		Action:      newscripts.UpdateScript,
+		ForwardName: "00001_script.up.sql",
+		ReverseName: "00001_script.down.sql",
+	}
+	options.DryRun = true
+	scriptInfo.WriteScripts(newTemplateMigrationOps(t), options) // Prints the diff only // 仅打印差异
+
+	content, err := options.ScriptsFS.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "SELECT 1;\n", string(content))
+
+	options.DryRun = false
+	scriptInfo.WriteScripts(newTemplateMigrationOps(t), options)
+
+	content, err = options.ScriptsFS.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, newTemplateMigrationOps(t).GetForwardScript(), string(content))
+}
+
+// newTestMigration creates migration of scripts DIR over a new sqlite database
+// newTestMigration 基于新的 sqlite 数据库创建脚本 DIR 的迁移
+func newTestMigration(t *testing.T, scriptsInRoot string) *migrate.Migrate {
+	db := rese.P1(gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "main.db")), &gorm.Config{}))
+	migration := rese.P1(newmigrate.NewWithScriptsAndDatabase(&newmigrate.ScriptsAndDatabaseParam{
+		ScriptsInRoot:    scriptsInRoot,
+		DatabaseName:     "sqlite3",
+		DatabaseInstance: rese.V1(sqlite3.WithInstance(rese.P1(db.DB()), &sqlite3.Config{})),
+	}))
+	t.Cleanup(func() {
+		_, _ = migration.Close()
+	})
+	return migration
+}
+
+func TestGetNewScriptInfo_VersionPattern(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "20250908101010_init.up.sql"), []byte("CREATE TABLE `users` (`id` integer);\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "20250908101010_init.down.sql"), []byte("DROP TABLE `users`;\n"), 0644))
+	migration := newTestMigration(t, root)
+	require.NoError(t, migration.Up())
+
+	// Without a picked pattern the pattern of scripts DIR is taken
+	// 未指定模式时沿用脚本 DIR 的模式
+	scriptInfo, err := newscripts.GetNewScriptInfo(migration, newscripts.NewOptions(root), newscripts.NewScriptNaming())
+	require.NoError(t, err)
+	require.Equal(t, newscripts.CreateScript, scriptInfo.Action)
+	require.Regexp(t, `^[0-9]{14}_script\.up\.sql$`, scriptInfo.ForwardName)
+
+	// A picked pattern conflicting with scripts DIR is refused
+	// 与脚本 DIR 冲突的指定模式会被拒绝
+	naming := &newscripts.ScriptNaming{VersionType: newscripts.VersionNext, Description: "script"}
+	_, err = newscripts.GetNewScriptInfo(migration, newscripts.NewOptions(root), naming)
+	require.ErrorIs(t, err, migrationerrors.ErrUsage)
+}
+
+func TestGetNewScriptInfo_Refused(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"00001_init", "00002_name", "00003_items"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name+".up.sql"), []byte("SELECT 1;\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, name+".down.sql"), []byte("SELECT 1;\n"), 0644))
+	}
+	migration := newTestMigration(t, root)
+	require.NoError(t, migration.Steps(1))
+
+	// Two unmigrated scripts exist, only the top one could be updated
+	// 存在两个未迁移脚本，只有最高的脚本可以更新
+	_, err := newscripts.GetNewScriptInfo(migration, newscripts.NewOptions(root), newscripts.NewScriptNaming())
+	require.ErrorIs(t, err, migrationerrors.ErrScriptAction)
+
+	// A failed script leaves the database dirty, scripts are not written until it is repaired
+	// 失败的脚本使数据库变脏，修复之前不写入脚本
+	require.NoError(t, os.WriteFile(filepath.Join(root, "00002_name.up.sql"), []byte("BROKEN SQL;\n"), 0644))
+	require.Error(t, migration.Up())
+	_, err = newscripts.GetNewScriptInfo(migration, newscripts.NewOptions(root), newscripts.NewScriptNaming())
+	require.ErrorIs(t, err, migrationerrors.ErrDirty)
+}
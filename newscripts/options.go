@@ -2,11 +2,17 @@ package newscripts
 
 import (
 	"fmt"
-	"strconv"
+	"io"
+	"os"
 	"strings"
-	"time"
+	"text/template"
 
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
 	"github.com/yyle88/must"
+	"github.com/yyle88/rese"
+	"github.com/yyle88/tern/zerotern"
 )
 
 // Options contains configuration parameters to generate and execute migration scripts
@@ -17,10 +23,19 @@ import (
 // 控制脚本位置、执行模式和用户交互行为
 // 为不同的部署和开发场景提供灵活配置
 type Options struct {
-	ScriptsInRoot string // Path to migration scripts DIR // 迁移脚本 DIR 路径
-	DryRun        bool   // Enable dry-run mode without file writes // 启用试运行模式，不写入文件
-	SurveyWritten bool   // Enable interactive confirmation prompts // 启用交互式确认提示
-	DefaultSuffix string // Default file extension for scripts // 脚本的默认文件扩展名
+	ScriptsInRoot   string             // Path to migration scripts DIR // 迁移脚本 DIR 路径
+	DryRun          bool               // Enable dry-run mode without file writes // 启用试运行模式，不写入文件
+	SurveyWritten   bool               // Enable interactive confirmation prompts // 启用交互式确认提示
+	DefaultSuffix   string             // Default file extension for scripts // 脚本的默认文件扩展名
+	WriteHeader     bool               // Prepend metadata header comment to scripts // 在脚本前添加元数据头部注释
+	MarkGenerated   bool               // Bracket generated content with markers and keep hand-written SQL on update // 使用标记包裹生成内容，更新时保留手写 SQL
+	VersionType     VersionPattern     // Registered version generator name used by create command, empty takes pattern of scripts DIR // 创建命令使用的已注册版本生成器名称，为空时使用脚本 DIR 的模式
+	AutoVersionType bool               // Switch to pattern of scripts DIR instead of refusing conflicting one // 切换到脚本 DIR 的模式，而不是拒绝冲突的模式
+	Rules           *RuleOptions       // Static analysis rules of script bodies // 脚本正文的静态分析规则
+	ScriptsFS       ScriptsFS          // File system used to read and write scripts // 用于读写脚本的文件系统
+	ForwardTemplate *template.Template // Template rendering up scripts // 渲染正向脚本的模板
+	ReverseTemplate *template.Template // Template rendering down scripts // 渲染反向脚本的模板
+	Output          io.Writer          // Writer of script diffs and analysis problems // 脚本差异和分析问题的输出 writer
 }
 
 // NewOptions creates default configuration for script generation with specified root DIR
@@ -32,13 +47,62 @@ type Options struct {
 // 返回已配置的选项，可进一步自定义
 func NewOptions(scriptsInRoot string) *Options {
 	return &Options{
-		ScriptsInRoot: scriptsInRoot,
-		DryRun:        false,
-		SurveyWritten: false,
-		DefaultSuffix: "sql",
+		ScriptsInRoot:   scriptsInRoot,
+		DryRun:          false,
+		SurveyWritten:   false,
+		DefaultSuffix:   "sql",
+		WriteHeader:     false,
+		MarkGenerated:   false,
+		VersionType:     "",
+		AutoVersionType: false,
+		Rules:           NewRuleOptions(),
+		ScriptsFS:       NewOsFS(),
+		ForwardTemplate: DefaultForwardTemplate,
+		ReverseTemplate: DefaultReverseTemplate,
+		Output:          os.Stdout,
 	}
 }
 
+// getScriptsFS returns configured file system, falling back to the real disk when not set
+//
+// getScriptsFS 返回配置的文件系统，未设置时回退到真实磁盘
+func (options *Options) getScriptsFS() ScriptsFS {
+	if options.ScriptsFS == nil {
+		return NewOsFS()
+	}
+	return options.ScriptsFS
+}
+
+// getOutput returns configured output writer, falling back to stdout when not set
+//
+// getOutput 返回配置的输出 writer，未设置时回退到 stdout
+func (options *Options) getOutput() io.Writer {
+	if options.Output == nil {
+		return os.Stdout
+	}
+	return options.Output
+}
+
+// getForwardTemplate returns configured up script template, falling back to the default one
+//
+// getForwardTemplate 返回配置的正向脚本模板，未设置时回退到默认模板
+func (options *Options) getForwardTemplate() *template.Template {
+	if options.ForwardTemplate == nil {
+		return DefaultForwardTemplate
+	}
+	return options.ForwardTemplate
+}
+
+// getReverseTemplate returns configured down script template, falling back to the default one
+//
+// getReverseTemplate 返回配置的反向脚本模板，未设置时回退到默认模板
+func (options *Options) getReverseTemplate() *template.Template {
+	if options.ReverseTemplate == nil {
+		return DefaultReverseTemplate
+	}
+	return options.ReverseTemplate
+}
+
 // VersionPattern defines the approach to generate migration script version numbers
 // Supports different versioning methods to suit various project needs
 // Each pattern provides unique benefits suited to different development workflows
@@ -49,25 +113,23 @@ func NewOptions(scriptsInRoot string) *Options {
 type VersionPattern string
 
 const (
-	VersionNext VersionPattern = "NEXT" // Auto-incrementing numbers, e.g., 00001, 00002 // 自动递增编号，例如 00001, 00002
-	VersionUnix VersionPattern = "UNIX" // Unix timestamp versions, e.g., 1678693920 // Unix 时间戳版本，例如 1678693920
-	VersionTime VersionPattern = "TIME" // Formatted datetime versions, e.g., 20250621103045 // 格式化日时版本，例如 20250621103045
+	VersionNext    VersionPattern = "NEXT"    // Auto-incrementing numbers, e.g., 00001, 00002 // 自动递增编号，例如 00001, 00002
+	VersionUnix    VersionPattern = "UNIX"    // Unix timestamp versions, e.g., 1678693920 // Unix 时间戳版本，例如 1678693920
+	VersionTime    VersionPattern = "TIME"    // Formatted datetime versions, e.g., 20250621103045 // 格式化日时版本，例如 20250621103045
+	VersionDateSeq VersionPattern = "DATESEQ" // Date plus sequence versions, e.g., 2025062101 // 日期加序号版本，例如 2025062101
 )
 
-// parseVersionType converts string input to VersionPattern enum with validation
+// parseVersionType converts string input to VersionPattern with validation against registered generators
+// Returns ErrUsage when no generator is registered under the name
 //
-// parseVersionType 将字符串输入转换为 VersionPattern 枚举，并进行验证
-func parseVersionType(s string) VersionPattern {
-	switch strings.ToUpper(s) {
-	case "NEXT":
-		return VersionNext
-	case "UNIX":
-		return VersionUnix
-	case "TIME": // Supports both TIME and DATETIME formats // 支持 TIME 和 DATETIME 两种写法
-		return VersionTime
-	default:
-		panic("unknown version-type: " + s + " (must be NEXT, UNIX, TIME)")
+// parseVersionType 将字符串输入转换为 VersionPattern，并根据已注册的生成器进行验证
+// 名称下没有注册生成器时返回 ErrUsage
+func parseVersionType(s string) (VersionPattern, error) {
+	versionType := VersionPattern(strings.ToUpper(s))
+	if _, ok := LookupVersionGenerator(versionType); !ok {
+		return "", erero.Wrapf(migrationerrors.ErrUsage, "unknown version-type %q (must be %s)", s, strings.Join(VersionGeneratorNames(), ", "))
 	}
+	return versionType, nil
 }
 
 // ScriptNaming contains configuration for migration script naming conventions
@@ -78,43 +140,58 @@ func parseVersionType(s string) VersionPattern {
 // 将版本生成策略与描述性命名相结合
 // 用于创建一致且有意义的脚本文件名
 type ScriptNaming struct {
-	VersionType VersionPattern // Version number generation strategy // 版本号生成策略
+	VersionType VersionPattern // Version number generation strategy, empty takes pattern of existing scripts // 版本号生成策略，为空时使用已有脚本的模式
 	Description string         // Descriptive name for migration scripts // 迁移脚本的描述性名称
 }
 
-// NewScriptNaming creates default script naming configuration with incremental versioning
-// Sets up standard naming patterns suitable for most migration scenarios
+// NewScriptNaming creates default script naming configuration following pattern of existing scripts
+// Takes incremental versioning when scripts DIR is empty, suitable for most migration scenarios
 // Returns naming configuration ready for immediate use or further customization
 //
-// NewScriptNaming 创建具有增量版本控制的默认脚本命名配置
-// 设置适用于大多数迁移场景的标准命名模式
+// NewScriptNaming 创建沿用已有脚本模式的默认脚本命名配置
+// 脚本 DIR 为空时使用增量版本控制，适用于大多数迁移场景
 // 返回可立即使用或进一步自定义的命名配置
 func NewScriptNaming() *ScriptNaming {
 	return &ScriptNaming{
-		VersionType: VersionNext,
+		VersionType: "",
 		Description: "script",
 	}
 }
 
-// newVersion generates version string based on configured pattern
+// newVersion generates version string through generator registered with configured pattern
+// Empty pattern takes NEXT, returns ErrUsage when no generator is registered under the pattern
 //
-// newVersion 基于配置的模式生成版本字符串
-func (T *ScriptNaming) newVersion(versionNum uint) string {
-	switch T.VersionType {
-	case VersionNext:
-		return fmt.Sprintf("%05d", versionNum) // Zero-pad to 5 digits, e.g., 00001, 00002 // 在数字左侧补零宽度 5 位，例如 00001, 00002
-	case VersionUnix:
-		return strconv.FormatInt(time.Now().Unix(), 10) // Current Unix timestamp in seconds // 当前 Unix 时间戳（秒数）
-	case VersionTime:
-		return time.Now().Format("20060102150405") // Format: YYYYMMDDHHMMSS, e.g., 20250621103045 // 格式：YYYYMMDDHHMMSS，例如 20250621103045
-	default:
-		panic("unknown VersionPattern: " + string(T.VersionType)) // Panic on unmatched pattern // 未匹配模式时 panic
+// newVersion 通过以配置模式注册的生成器生成版本字符串
+// 空模式使用 NEXT，模式下没有注册生成器时返回 ErrUsage
+func (T *ScriptNaming) newVersion(previousVersion uint, migrations *source.Migrations) (string, error) {
+	versionType, err := parseVersionType(string(zerotern.VV(T.VersionType, VersionNext)))
+	if err != nil {
+		return "", err
 	}
+	generator, _ := LookupVersionGenerator(versionType)
+	return generator.NewVersion(previousVersion, migrations), nil
 }
 
 // NewScriptPrefix creates script filename prefix combining version and description
+// Version is the number taken by incremental patterns, timestamp patterns ignore it
+// Panics on unregistered pattern, use NewScriptPrefixAfter to get the error
 //
 // NewScriptPrefix 创建结合版本和描述的脚本文件名前缀
+// version 是递增模式使用的编号，时间戳模式会忽略它
+// 模式未注册时 panic，需要获取错误时使用 NewScriptPrefixAfter
 func (T *ScriptNaming) NewScriptPrefix(version uint) string {
-	return fmt.Sprintf("%s_%s", must.Nice(T.newVersion(version)), must.Nice(T.Description))
+	return rese.V1(T.NewScriptPrefixAfter(max(version, 1)-1, source.NewMigrations()))
+}
+
+// NewScriptPrefixAfter creates script filename prefix of the script following database version and existing migrations
+// Returns ErrUsage when no generator is registered under the pattern
+//
+// NewScriptPrefixAfter 创建数据库版本和已有迁移之后的脚本文件名前缀
+// 模式下没有注册生成器时返回 ErrUsage
+func (T *ScriptNaming) NewScriptPrefixAfter(previousVersion uint, migrations *source.Migrations) (string, error) {
+	version, err := T.newVersion(previousVersion, migrations)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s_%s", must.Nice(version), must.Nice(T.Description)), nil
 }
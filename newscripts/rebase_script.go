@@ -0,0 +1,219 @@
+package newscripts
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/zaplog"
+)
+
+// scriptFile represents a parsed script file in scripts DIR
+// Keeps the version text so renumbering can keep the same width
+//
+// scriptFile 表示脚本 DIR 中已解析的脚本文件
+// 保留版本文本，以便重新编号时保持相同宽度
+type scriptFile struct {
+	Migration   *source.Migration // Parsed migration // 解析后的迁移
+	VersionText string            // Version prefix text in filename // 文件名中的版本前缀文本
+}
+
+// scanScriptFiles reads scripts DIR and parses each script filename
+// Unlike newMigrationsFromPath it tolerates duplicates and returns unparsable names
+//
+// scanScriptFiles 读取脚本 DIR 并解析每个脚本文件名
+// 与 newMigrationsFromPath 不同，它容忍重复并返回无法解析的文件名
+func scanScriptFiles(options *Options) ([]*scriptFile, []string, error) {
+	entries, err := options.getScriptsFS().ReadDir(options.ScriptsInRoot)
+	if err != nil {
+		return nil, nil, erero.Wro(err)
+	}
+	var results []*scriptFile
+	var badNames []string
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == ManifestName {
+			continue
+		}
+		migration, err := source.DefaultParse(e.Name())
+		if err != nil {
+			badNames = append(badNames, e.Name())
+			continue
+		}
+		versionText, _, _ := strings.Cut(e.Name(), "_")
+		results = append(results, &scriptFile{
+			Migration:   migration,
+			VersionText: versionText,
+		})
+	}
+	return results, badNames, nil
+}
+
+// RebaseMove describes renumbering of one script pair
+//
+// RebaseMove 描述单个脚本对的重新编号
+type RebaseMove struct {
+	Version     uint              // Original version // 原版本
+	NewVersion  uint              // Version after renumbering // 重新编号后的版本
+	Description string            // Description kept from filename // 从文件名保留的描述
+	Renames     map[string]string // Filename renames, original name to new name // 文件重命名，原名到新名
+}
+
+// RebasePlan contains renumbering moves that resolve version collisions
+//
+// RebasePlan 包含解决版本冲突的重新编号操作
+type RebasePlan struct {
+	DatabaseVersion uint          // Current database version // 当前数据库版本
+	Moves           []*RebaseMove // Moves in renumbering sequence // 按重新编号顺序排列的操作
+}
+
+// scriptUnit groups up and down files sharing version and description
+//
+// scriptUnit 将共享版本和描述的正向和反向文件分组
+type scriptUnit struct {
+	version     uint
+	versionText string
+	description string
+	names       []string
+}
+
+// PlanRebase detects duplicate or out-of-order unapplied scripts and plans renumbering
+// Scripts at or below databaseVersion that are missing from manifest are treated as unapplied
+// Unapplied colliding scripts are renumbered after the highest remaining version
+//
+// PlanRebase 检测重复或乱序的未应用脚本并规划重新编号
+// 版本不高于 databaseVersion 且不在清单中的脚本被视为未应用
+// 冲突的未应用脚本会在剩余最高版本之后重新编号
+func PlanRebase(databaseVersion uint, options *Options) (*RebasePlan, error) {
+	files, badNames, err := scanScriptFiles(options)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	if len(badNames) > 0 {
+		return nil, erero.Errorf("unparsable script names: %v", badNames)
+	}
+	manifest, manifestExists, err := ReadManifest(options)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	isRecorded := func(unit *scriptUnit) bool {
+		for _, name := range unit.names {
+			if manifest.SearchEntry(name) != nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Group files into units by version and description
+	// 按版本和描述将文件分组为单元
+	unitMap := map[string]*scriptUnit{}
+	var units []*scriptUnit
+	for _, file := range files {
+		key := fmt.Sprintf("%d_%s", file.Migration.Version, file.Migration.Identifier)
+		unit, ok := unitMap[key]
+		if !ok {
+			unit = &scriptUnit{
+				version:     file.Migration.Version,
+				versionText: file.VersionText,
+				description: file.Migration.Identifier,
+			}
+			unitMap[key] = unit
+			units = append(units, unit)
+		}
+		unit.names = append(unit.names, file.Migration.Raw)
+	}
+	sort.SliceStable(units, func(i, j int) bool {
+		if units[i].version != units[j].version {
+			return units[i].version < units[j].version
+		}
+		return units[i].description < units[j].description
+	})
+
+	// Decide which units keep the version and which need renumbering
+	// 决定哪些单元保留版本，哪些需要重新编号
+	var kept = map[uint]bool{}
+	var highest = databaseVersion
+	var movings []*scriptUnit
+	for _, unit := range units {
+		switch {
+		case unit.version <= databaseVersion && manifestExists:
+			if isRecorded(unit) && !kept[unit.version] {
+				kept[unit.version] = true
+				continue
+			}
+			movings = append(movings, unit) // Applied range but not recorded, arrived late // 在已应用范围内但未记录，属于后到的脚本
+		case unit.version <= databaseVersion:
+			if kept[unit.version] {
+				return nil, erero.Errorf("duplicate applied version %d and no %s to tell which one was applied", unit.version, ManifestName)
+			}
+			kept[unit.version] = true
+		default:
+			if kept[unit.version] {
+				movings = append(movings, unit) // Duplicate unapplied version // 重复的未应用版本
+				continue
+			}
+			kept[unit.version] = true
+			highest = max(highest, unit.version)
+		}
+	}
+
+	plan := &RebasePlan{DatabaseVersion: databaseVersion}
+	for _, unit := range movings {
+		highest++
+		move := &RebaseMove{
+			Version:     unit.version,
+			NewVersion:  highest,
+			Description: unit.description,
+			Renames:     map[string]string{},
+		}
+		newVersionText := fmt.Sprintf("%0*d", len(unit.versionText), highest)
+		for _, name := range unit.names {
+			move.Renames[name] = newVersionText + strings.TrimPrefix(name, unit.versionText)
+		}
+		plan.Moves = append(plan.Moves, move)
+	}
+	return plan, nil
+}
+
+// Apply renames files of each move, rolling back finished renames on failure
+// Does nothing in dry-run mode
+//
+// Apply 重命名每个操作的文件，失败时回滚已完成的重命名
+// 试运行模式下不执行任何操作
+func (plan *RebasePlan) Apply(options *Options) error {
+	if options.DryRun {
+		zaplog.SUG.Debugln("dry-run mode", options.DryRun)
+		return nil
+	}
+	type renameStep struct {
+		oldPath string
+		newPath string
+	}
+	var steps []*renameStep
+	for _, move := range plan.Moves {
+		for oldName, newName := range move.Renames {
+			newPath := filepath.Join(options.ScriptsInRoot, newName)
+			if isFile(options.getScriptsFS(), newPath) {
+				return erero.Errorf("rename target already exists: %s", newName)
+			}
+			steps = append(steps, &renameStep{
+				oldPath: filepath.Join(options.ScriptsInRoot, oldName),
+				newPath: newPath,
+			})
+		}
+	}
+	for idx, step := range steps {
+		if err := options.getScriptsFS().Rename(step.oldPath, step.newPath); err != nil {
+			for back := idx - 1; back >= 0; back-- {
+				if errBack := options.getScriptsFS().Rename(steps[back].newPath, steps[back].oldPath); errBack != nil {
+					zaplog.SUG.Errorln("rollback rename failed:", steps[back].newPath, errBack)
+				}
+			}
+			return erero.Wro(err)
+		}
+	}
+	return nil
+}
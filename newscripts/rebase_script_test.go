@@ -0,0 +1,50 @@
+package newscripts_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanRebase(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(name string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("SELECT 1;\n"), 0644))
+	}
+	writeFile("00011_create_users.up.sql")
+	writeFile("00011_create_users.down.sql")
+
+	options := newscripts.NewOptions(root)
+	require.NoError(t, newscripts.WriteManifest(options, -1))
+
+	// Two branches both create 00012 and one more branch brings late 00010
+	// 两个分支都创建了 00012，另一个分支带来了后到的 00010
+	writeFile("00010_late_branch.up.sql")
+	writeFile("00010_late_branch.down.sql")
+	writeFile("00012_branch_a.up.sql")
+	writeFile("00012_branch_a.down.sql")
+	writeFile("00012_branch_b.up.sql")
+	writeFile("00012_branch_b.down.sql")
+
+	plan, err := newscripts.PlanRebase(11, options)
+	require.NoError(t, err)
+	require.Len(t, plan.Moves, 2)
+	require.Equal(t, uint(10), plan.Moves[0].Version)
+	require.Equal(t, uint(13), plan.Moves[0].NewVersion)
+	require.Equal(t, "00013_late_branch.up.sql", plan.Moves[0].Renames["00010_late_branch.up.sql"])
+	require.Equal(t, uint(12), plan.Moves[1].Version)
+	require.Equal(t, uint(14), plan.Moves[1].NewVersion)
+	require.Equal(t, "00014_branch_b.down.sql", plan.Moves[1].Renames["00012_branch_b.down.sql"])
+
+	require.NoError(t, plan.Apply(options))
+	require.FileExists(t, filepath.Join(root, "00013_late_branch.up.sql"))
+	require.FileExists(t, filepath.Join(root, "00014_branch_b.up.sql"))
+	require.NoFileExists(t, filepath.Join(root, "00012_branch_b.up.sql"))
+
+	plan, err = newscripts.PlanRebase(11, options)
+	require.NoError(t, err)
+	require.Empty(t, plan.Moves) // Collisions are resolved // 冲突已解决
+}
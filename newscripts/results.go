@@ -4,7 +4,7 @@ import (
 	"path/filepath"
 
 	"github.com/go-xlan/go-migrate/checkmigration"
-	"github.com/yyle88/osexistpath/osmustexist"
+	"github.com/yyle88/rese"
 )
 
 // ScriptAction represents the type of action to execute on migration scripts
@@ -27,24 +27,85 @@ const (
 // 包含操作类型和正向、反向脚本文件名
 // 用于协调脚本创建和更新
 type NewScriptInfo struct {
-	Action      ScriptAction // Type of script action to perform // 要执行的脚本操作类型
-	ForwardName string       // Filename for forward migration script // 正向迁移脚本的文件名
-	ReverseName string       // Filename for reverse migration script // 反向迁移脚本的文件名
+	Action          ScriptAction // Type of script action to perform // 要执行的脚本操作类型
+	ForwardName     string       // Filename for forward migration script // 正向迁移脚本的文件名
+	ReverseName     string       // Filename for reverse migration script // 反向迁移脚本的文件名
+	PreviousVersion uint         // Database version before this script // 此脚本之前的数据库版本
+	Meta            *ScriptMeta  // Provenance details used in script header // 用于脚本头部的来源信息
 }
 
 // WriteScripts generates and writes both forward and reverse migration scripts to file system
-// Creates script content from migration operations and handles file writing
+// Renders script content through templates in options and handles file writing
 // Supports both create and update scenarios based on script action
 //
 // WriteScripts 生成并将正向和反向迁移脚本写入文件系统
-// 从迁移操作创建脚本内容并处理文件写入
+// 通过选项中的模板渲染脚本内容并处理文件写入
 // 基于脚本操作支持创建和更新场景
-func (scriptInfo *NewScriptInfo) WriteScripts(migrationOps checkmigration.MigrationOps, options *Options) {
-	forwardScript := migrationOps.GetForwardScript()
-	mustWriteScript(scriptInfo.Action, scriptInfo.ForwardName, forwardScript, options)
+// Returns names of files actually written, skipping unchanged, declined and dry-run files
+// 返回实际写入的文件名，跳过未变化、被拒绝和试运行的文件
+func (scriptInfo *NewScriptInfo) WriteScripts(migrationOps checkmigration.MigrationOps, options *Options) []string {
+	forwardScript, reverseScript := rese.V2(scriptInfo.RenderScripts(migrationOps, options))
+	var writtenNames []string
+	if mustWriteScript(scriptInfo.Action, scriptInfo.ForwardName, scriptInfo.composeScript(scriptInfo.ForwardName, forwardScript, options), options) {
+		writtenNames = append(writtenNames, scriptInfo.ForwardName)
+	}
+	if mustWriteScript(scriptInfo.Action, scriptInfo.ReverseName, scriptInfo.composeScript(scriptInfo.ReverseName, reverseScript, options), options) {
+		writtenNames = append(writtenNames, scriptInfo.ReverseName)
+	}
+	return writtenNames
+}
+
+// WriteResult contains script names of a create or update run, written as JSON object of script commands
+//
+// WriteResult 包含创建或更新运行的脚本名称，作为脚本命令的 JSON 对象输出
+type WriteResult struct {
+	Action       ScriptAction // Script action performed // 执行的脚本操作
+	ForwardName  string       // Filename of forward script // 正向脚本的文件名
+	ReverseName  string       // Filename of reverse script // 反向脚本的文件名
+	WrittenNames []string     // Filenames actually written // 实际写入的文件名
+}
 
-	reverseScript, _ := migrationOps.GetReverseScript()
-	mustWriteScript(scriptInfo.Action, scriptInfo.ReverseName, reverseScript, options)
+// composeScript builds file content from generated body, adding markers and header as configured
+// On update the generated region of existing file is replaced and hand-written SQL is kept
+// On update the header keeps the original timestamp, so unchanged scripts are not rewritten
+//
+// composeScript 根据生成的正文构建文件内容，按配置添加标记和头部
+// 更新时替换已有文件的生成区域，并保留手写 SQL
+// 更新时头部保留原始时间戳，使未变化的脚本不会被重写
+func (scriptInfo *NewScriptInfo) composeScript(shortName string, script string, options *Options) string {
+	var existingHeader *ScriptHeader
+	var existingBody string
+	var hasExisting bool
+	path := filepath.Join(options.ScriptsInRoot, shortName)
+	if scriptInfo.Action == UpdateScript && isFile(options.getScriptsFS(), path) {
+		existingHeader, existingBody, _ = ParseScriptHeader(string(rese.V1(options.getScriptsFS().ReadFile(path))))
+		hasExisting = true
+	}
+
+	body := script
+	if options.MarkGenerated {
+		body = WrapGenerated(script)
+		if hasExisting {
+			body = MergeGenerated(existingBody, script)
+		}
+	}
+	return scriptInfo.withHeader(body, existingHeader, options)
+}
+
+// withHeader prepends metadata header to script body when header writing is enabled
+// Keeps timestamp of existing header when there is one
+//
+// withHeader 当启用头部写入时在脚本正文前添加元数据头部
+// 存在已有头部时保留其时间戳
+func (scriptInfo *NewScriptInfo) withHeader(script string, existingHeader *ScriptHeader, options *Options) string {
+	if !options.WriteHeader {
+		return script
+	}
+	header := NewScriptHeader(scriptInfo.Meta, scriptInfo.PreviousVersion, script)
+	if existingHeader != nil && existingHeader.Timestamp != "" {
+		header.Timestamp = existingHeader.Timestamp
+	}
+	return header.Render() + script
 }
 
 // ScriptExists checks if migration script files exist in the target DIR
@@ -55,10 +116,10 @@ func (scriptInfo *NewScriptInfo) WriteScripts(migrationOps checkmigration.Migrat
 // 验证正向和反向脚本文件的存在性
 // 如果找到任何脚本文件则返回 true
 func (scriptInfo *NewScriptInfo) ScriptExists(options *Options) bool {
-	if osmustexist.IsFile(filepath.Join(options.ScriptsInRoot, scriptInfo.ForwardName)) {
+	if isFile(options.getScriptsFS(), filepath.Join(options.ScriptsInRoot, scriptInfo.ForwardName)) {
 		return true
 	}
-	if osmustexist.IsFile(filepath.Join(options.ScriptsInRoot, scriptInfo.ReverseName)) {
+	if isFile(options.getScriptsFS(), filepath.Join(options.ScriptsInRoot, scriptInfo.ReverseName)) {
 		return true
 	}
 	return false
@@ -1 +1,43 @@
 package newscripts_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScriptInfo_WriteScripts_KeepHeaderTimestamp(t *testing.T) {
+	root := t.TempDir()
+	options := newscripts.NewOptions(root)
+	options.WriteHeader = true
+	options.ScriptsFS = newscripts.NewMemFS()
+
+	scriptInfo := &newscripts.NewScriptInfo{
+		Action:      newscripts.CreateScript,
+		ForwardName: "00001_script.up.sql",
+		ReverseName: "00001_script.down.sql",
+	}
+	require.Len(t, scriptInfo.WriteScripts(newTemplateMigrationOps(t)[:1], options), 2)
+
+	path := root + "/00001_script.up.sql"
+	content, err := options.ScriptsFS.ReadFile(path)
+	require.NoError(t, err)
+	header, _, ok := newscripts.ParseScriptHeader(string(content))
+	require.True(t, ok)
+
+	// Updating with the same schema changes writes nothing
+	// 使用相同的结构变化更新时不写入任何文件
+	scriptInfo.Action = newscripts.UpdateScript
+	require.Empty(t, scriptInfo.WriteScripts(newTemplateMigrationOps(t)[:1], options))
+
+	// Updating with new schema changes keeps the original timestamp
+	// 使用新的结构变化更新时保留原始时间戳
+	require.NotEmpty(t, scriptInfo.WriteScripts(newTemplateMigrationOps(t), options))
+	content, err = options.ScriptsFS.ReadFile(path)
+	require.NoError(t, err)
+	updatedHeader, body, ok := newscripts.ParseScriptHeader(string(content))
+	require.True(t, ok)
+	require.Equal(t, header.Timestamp, updatedHeader.Timestamp)
+	require.True(t, updatedHeader.Verify(body))
+}
@@ -0,0 +1,162 @@
+package newscripts
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yyle88/eroticgo"
+)
+
+// diffContext is the count of unchanged lines shown around each change
+//
+// diffContext 是每处变更前后显示的未变更行数
+const diffContext = 3
+
+// diffLine is one line of the line-based edit script between two texts
+//
+// diffLine 是两段文本之间基于行的编辑脚本中的一行
+type diffLine struct {
+	kind   byte   // ' ' unchanged, '-' removed, '+' added // ' ' 未变更，'-' 删除，'+' 新增
+	text   string // Line content without newline // 不含换行符的行内容
+	oldIdx int    // Count of old lines before this line // 此行之前的旧行数
+	newIdx int    // Count of new lines before this line // 此行之前的新行数
+}
+
+// UnifiedDiff renders line-based unified diff between old and new text
+// Returns empty string when both texts are the same
+//
+// UnifiedDiff 渲染旧文本与新文本之间基于行的统一差异
+// 两段文本相同时返回空字符串
+func UnifiedDiff(oldName string, newName string, oldText string, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+
+	var sb strings.Builder
+	sb.WriteString("--- " + oldName + "\n")
+	sb.WriteString("+++ " + newName + "\n")
+	for _, hunk := range groupHunks(lines) {
+		var oldCount, newCount int
+		for _, line := range hunk {
+			if line.kind != '+' {
+				oldCount++
+			}
+			if line.kind != '-' {
+				newCount++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(hunk[0].oldIdx, oldCount), hunkRange(hunk[0].newIdx, newCount)))
+		for _, line := range hunk {
+			sb.WriteString(string(line.kind) + line.text + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// splitLines splits text into lines without trailing newlines
+//
+// splitLines 将文本拆分为不含换行符的行
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes edit script of two line lists through longest common subsequence
+//
+// diffLines 通过最长公共子序列计算两个行列表的编辑脚本
+func diffLines(oldLines []string, newLines []string) []*diffLine {
+	n, m := len(oldLines), len(newLines)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var results []*diffLine
+	var i, j int
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && oldLines[i] == newLines[j]:
+			results = append(results, &diffLine{kind: ' ', text: oldLines[i], oldIdx: i, newIdx: j})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			results = append(results, &diffLine{kind: '-', text: oldLines[i], oldIdx: i, newIdx: j})
+			i++
+		default:
+			results = append(results, &diffLine{kind: '+', text: newLines[j], oldIdx: i, newIdx: j})
+			j++
+		}
+	}
+	return results
+}
+
+// groupHunks groups changed lines with surrounding context into hunks
+// Hunks whose context overlaps are merged into one
+//
+// groupHunks 将变更行及其上下文分组为块
+// 上下文重叠的块会合并为一个
+func groupHunks(lines []*diffLine) [][]*diffLine {
+	var hunks [][]*diffLine
+	var start, end = -1, -1
+	for idx, line := range lines {
+		if line.kind == ' ' {
+			continue
+		}
+		from, to := max(0, idx-diffContext), min(len(lines), idx+diffContext+1)
+		if start >= 0 && from <= end {
+			end = to
+			continue
+		}
+		if start >= 0 {
+			hunks = append(hunks, lines[start:end])
+		}
+		start, end = from, to
+	}
+	if start >= 0 {
+		hunks = append(hunks, lines[start:end])
+	}
+	return hunks
+}
+
+// hunkRange formats start and count of hunk header, start is one-based unless the range is empty
+//
+// hunkRange 格式化块头部的起始行和行数，除空范围外起始行从 1 开始
+func hunkRange(before int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// showDiff writes unified diff into writer with removed lines in red and added lines in green
+//
+// showDiff 将统一差异写入 writer，删除行显示为红色，新增行显示为绿色
+func showDiff(writer io.Writer, diff string) {
+	for idx, line := range splitLines(diff) {
+		switch {
+		case idx < 2: // File names, removed SQL comments also start with "---" so match by position // 文件名，删除的 SQL 注释也以 "---" 开头，因此按位置匹配
+			fmt.Fprintln(writer, eroticgo.PINK.Sprint(line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Fprintln(writer, eroticgo.BLUE.Sprint(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Fprintln(writer, eroticgo.RED.Sprint(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Fprintln(writer, eroticgo.GREEN.Sprint(line))
+		default:
+			fmt.Fprintln(writer, line)
+		}
+	}
+}
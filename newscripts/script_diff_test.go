@@ -0,0 +1,38 @@
+package newscripts_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	newText := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	diff := newscripts.UnifiedDiff("old.sql", "new.sql", oldText, newText)
+	t.Log(diff)
+	require.Equal(t, "--- old.sql\n"+
+		"+++ new.sql\n"+
+		"@@ -1,5 +1,5 @@\n"+
+		" a\n"+
+		"-b\n"+
+		"+B\n"+
+		" c\n"+
+		" d\n"+
+		" e\n"+
+		"@@ -8,3 +8,4 @@\n"+
+		" h\n"+
+		" i\n"+
+		" j\n"+
+		"+k\n", diff)
+}
+
+func TestUnifiedDiff_Same(t *testing.T) {
+	require.Empty(t, newscripts.UnifiedDiff("a.sql", "a.sql", "SELECT 1;\n", "SELECT 1;\n"))
+}
+
+func TestUnifiedDiff_Create(t *testing.T) {
+	diff := newscripts.UnifiedDiff("a.sql", "a.sql", "", "SELECT 1;\n")
+	require.Equal(t, "--- a.sql\n+++ a.sql\n@@ -0,0 +1,1 @@\n+SELECT 1;\n", diff)
+}
@@ -0,0 +1,303 @@
+package newscripts
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScriptsFS is the writable file system that newscripts reads and writes scripts through
+// Paths are joined with ScriptsInRoot, so implementations receive full paths
+// Allows generating scripts into memory or archive targets instead of the real disk
+//
+// ScriptsFS 是 newscripts 读写脚本所使用的可写文件系统
+// 路径已与 ScriptsInRoot 拼接，因此实现收到的是完整路径
+// 支持将脚本生成到内存或归档目标，而不是真实磁盘
+type ScriptsFS interface {
+	ReadDir(name string) ([]fs.DirEntry, error)                 // Lists entries of DIR sorted by name // 列出 DIR 中按名称排序的条目
+	ReadFile(name string) ([]byte, error)                       // Reads whole content of file // 读取文件的全部内容
+	WriteFile(name string, data []byte, perm fs.FileMode) error // Writes file, truncating existing content // 写入文件，会截断已有内容
+	Stat(name string) (fs.FileInfo, error)                      // Describes file or DIR at path // 描述路径处的文件或 DIR
+	Rename(oldPath string, newPath string) error                // Moves file to new path // 将文件移动到新路径
+	Remove(name string) error                                   // Deletes file or empty DIR // 删除文件或空 DIR
+	MkdirAll(path string, perm fs.FileMode) error               // Creates DIR with missing parents // 创建 DIR 及缺失的上级 DIR
+}
+
+// isFile reports whether path exists and is a regular file in the file system
+//
+// isFile 判断路径在文件系统中是否存在且为普通文件
+func isFile(fsys ScriptsFS, path string) bool {
+	info, err := fsys.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// osFS implements ScriptsFS using the os package
+//
+// osFS 使用 os 包实现 ScriptsFS
+type osFS struct{}
+
+// NewOsFS creates ScriptsFS backed by the real disk
+//
+// NewOsFS 创建基于真实磁盘的 ScriptsFS
+func NewOsFS() ScriptsFS {
+	return &osFS{}
+}
+
+// ReadDir lists entries of DIR on disk sorted by name
+//
+// ReadDir 列出磁盘上 DIR 中按名称排序的条目
+func (*osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// ReadFile reads whole content of file on disk
+//
+// ReadFile 读取磁盘上文件的全部内容
+func (*osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// WriteFile writes file on disk
+//
+// WriteFile 将文件写入磁盘
+func (*osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	// when file exist WriteFile truncates it before writing, without changing permissions.
+	return os.WriteFile(name, data, perm)
+}
+
+// Stat describes file or DIR on disk
+//
+// Stat 描述磁盘上的文件或 DIR
+func (*osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Rename moves file on disk to new path
+//
+// Rename 将磁盘上的文件移动到新路径
+func (*osFS) Rename(oldPath string, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+// Remove deletes file or empty DIR on disk
+//
+// Remove 删除磁盘上的文件或空 DIR
+func (*osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// MkdirAll creates DIR on disk with missing parents
+//
+// MkdirAll 在磁盘上创建 DIR 及缺失的上级 DIR
+func (*osFS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// MemFS implements ScriptsFS in memory, safe to use across goroutines
+// Useful in tests and when scripts are generated into a non-disk target
+//
+// MemFS 在内存中实现 ScriptsFS，可在多个 goroutine 中安全使用
+// 适用于测试以及将脚本生成到非磁盘目标的场景
+type MemFS struct {
+	mutex sync.RWMutex
+	files map[string][]byte // File content keyed by clean path // 以规范路径为键的文件内容
+	dirs  map[string]bool   // Explicitly created DIRs // 显式创建的 DIR
+}
+
+// NewMemFS creates empty in-memory ScriptsFS
+//
+// NewMemFS 创建空的内存 ScriptsFS
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: map[string][]byte{},
+		dirs:  map[string]bool{},
+	}
+}
+
+// isDir reports whether path is a DIR, either created explicitly or implied by a file path
+// Caller must hold the lock
+//
+// isDir 判断路径是否为 DIR，可以是显式创建的或由文件路径隐含的
+// 调用方必须持有锁
+func (m *MemFS) isDir(path string) bool {
+	if m.dirs[path] {
+		return true
+	}
+	prefix := path + string(filepath.Separator)
+	for name := range m.files {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for name := range m.dirs {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadDir lists direct children of DIR sorted by name, DIRs implied by file paths included
+//
+// ReadDir 列出 DIR 中按名称排序的直接子条目，包括由文件路径隐含的 DIR
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	name = filepath.Clean(name)
+	if !m.isDir(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	prefix := name + string(filepath.Separator)
+	children := map[string]*memFileInfo{}
+	collect := func(path string, size int, isDir bool) {
+		rest, ok := strings.CutPrefix(path, prefix)
+		if !ok || rest == "" {
+			return
+		}
+		child, _, nested := strings.Cut(rest, string(filepath.Separator))
+		if nested || isDir {
+			children[child] = &memFileInfo{name: child, isDir: true}
+		} else {
+			children[child] = &memFileInfo{name: child, size: int64(size)}
+		}
+	}
+	for path, data := range m.files {
+		collect(path, len(data), false)
+	}
+	for path := range m.dirs {
+		collect(path, 0, true)
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, info := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// ReadFile returns copy of file content, fs.ErrNotExist when missing
+//
+// ReadFile 返回文件内容的副本，文件不存在时返回 fs.ErrNotExist
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	data, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// WriteFile stores copy of data at path, perm is ignored
+//
+// WriteFile 在路径处保存数据的副本，忽略 perm
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.files[filepath.Clean(name)] = append([]byte(nil), data...)
+	return nil
+}
+
+// Stat describes file or DIR at path, fs.ErrNotExist when missing
+//
+// Stat 描述路径处的文件或 DIR，不存在时返回 fs.ErrNotExist
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	name = filepath.Clean(name)
+	if data, ok := m.files[name]; ok {
+		return &memFileInfo{name: filepath.Base(name), size: int64(len(data))}, nil
+	}
+	if m.isDir(name) {
+		return &memFileInfo{name: filepath.Base(name), isDir: true}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Rename moves file content to new path, replacing existing file there
+//
+// Rename 将文件内容移动到新路径，会替换该路径已有的文件
+func (m *MemFS) Rename(oldPath string, newPath string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+	data, ok := m.files[oldPath]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldPath, Err: fs.ErrNotExist}
+	}
+	delete(m.files, oldPath)
+	m.files[newPath] = data
+	return nil
+}
+
+// Remove deletes file or explicitly created empty DIR, fs.ErrNotExist when missing
+//
+// Remove 删除文件或显式创建的空 DIR，不存在时返回 fs.ErrNotExist
+func (m *MemFS) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		return nil
+	}
+	if !m.dirs[name] {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.dirs, name)
+	if m.isDir(name) {
+		m.dirs[name] = true
+		return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+	}
+	return nil
+}
+
+// MkdirAll records DIR as existing, perm is ignored
+//
+// MkdirAll 将 DIR 记录为已存在，忽略 perm
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.dirs[filepath.Clean(path)] = true
+	return nil
+}
+
+// memFileInfo implements fs.FileInfo of MemFS entries
+//
+// memFileInfo 实现 MemFS 条目的 fs.FileInfo
+type memFileInfo struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+func (info *memFileInfo) Name() string { return info.name }
+
+func (info *memFileInfo) Size() int64 { return info.size }
+
+func (info *memFileInfo) Mode() fs.FileMode {
+	if info.isDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (info *memFileInfo) ModTime() time.Time { return time.Time{} }
+
+func (info *memFileInfo) IsDir() bool { return info.isDir }
+
+func (info *memFileInfo) Sys() any { return nil }
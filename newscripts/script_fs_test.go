@@ -0,0 +1,53 @@
+package newscripts_test
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFS(t *testing.T) {
+	root := filepath.Join("/", "scripts")
+	memFS := newscripts.NewMemFS()
+	require.NoError(t, memFS.WriteFile(filepath.Join(root, "00001_script.up.sql"), []byte("CREATE TABLE `a` (`id` integer);\n"), 0644))
+	require.NoError(t, memFS.WriteFile(filepath.Join(root, "00001_script.down.sql"), []byte("DROP TABLE `a`;\n"), 0644))
+	require.NoError(t, memFS.MkdirAll(filepath.Join(root, newscripts.ArchiveDirName), 0755))
+
+	entries, err := memFS.ReadDir(root)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, "00001_script.down.sql", entries[0].Name())
+	require.Equal(t, newscripts.ArchiveDirName, entries[2].Name())
+	require.True(t, entries[2].IsDir())
+
+	options := newscripts.NewOptions(root)
+	options.ScriptsFS = memFS
+	require.NoError(t, newscripts.WriteManifest(options, -1))
+	require.NoError(t, newscripts.VerifyManifest(options, 1))
+
+	report, err := newscripts.CheckScripts(options)
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+
+	require.NoError(t, memFS.WriteFile(filepath.Join(root, "00001_script.up.sql"), []byte("CREATE TABLE `b` (`id` integer);\n"), 0644))
+	require.ErrorIs(t, newscripts.VerifyManifest(options, 1), newscripts.ErrScriptModified)
+}
+
+func TestMemFS_Remove(t *testing.T) {
+	root := filepath.Join("/", "scripts")
+	archiveRoot := filepath.Join(root, newscripts.ArchiveDirName)
+	memFS := newscripts.NewMemFS()
+	require.NoError(t, memFS.MkdirAll(archiveRoot, 0755))
+	require.NoError(t, memFS.WriteFile(filepath.Join(archiveRoot, "00001_script.up.sql"), []byte("SELECT 1;\n"), 0644))
+
+	// DIR with content is kept // 有内容的 DIR 会被保留
+	require.Error(t, memFS.Remove(archiveRoot))
+	require.NoError(t, memFS.Remove(filepath.Join(archiveRoot, "00001_script.up.sql")))
+	require.NoError(t, memFS.Remove(archiveRoot))
+
+	_, err := memFS.Stat(archiveRoot)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
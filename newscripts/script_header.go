@@ -0,0 +1,170 @@
+package newscripts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// scriptHeaderPrefix marks each line of the metadata header block in generated scripts
+	// Uses SQL line comments so the header is ignored when the script is executed
+	//
+	// scriptHeaderPrefix 标记生成脚本中元数据头部块的每一行
+	// 使用 SQL 行注释，因此脚本执行时会忽略头部
+	scriptHeaderPrefix = "-- +go-migrate "
+
+	// scriptGenerator identifies this package as the generator of the script
+	//
+	// scriptGenerator 标识脚本由本包生成
+	scriptGenerator = "github.com/go-xlan/go-migrate/newscripts"
+)
+
+// ScriptMeta contains provenance details collected from the models and database connection
+// Used to fill the metadata header when writing scripts
+//
+// ScriptMeta 包含从模型和数据库连接收集的来源信息
+// 用于在写入脚本时填充元数据头部
+type ScriptMeta struct {
+	Dialect    string   // Database dialect name, e.g., mysql, postgres, sqlite // 数据库方言名称，例如 mysql, postgres, sqlite
+	ModelTypes []string // Go type names of GORM models // GORM 模型的 Go 类型名称
+}
+
+// NewScriptMeta collects dialect name and model type names from database connection and objects
+//
+// NewScriptMeta 从数据库连接和对象收集方言名称和模型类型名称
+func NewScriptMeta(db *gorm.DB, objects []interface{}) *ScriptMeta {
+	modelTypes := make([]string, 0, len(objects))
+	for _, object := range objects {
+		typ := reflect.TypeOf(object)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		modelTypes = append(modelTypes, typ.String())
+	}
+	return &ScriptMeta{
+		Dialect:    db.Dialector.Name(),
+		ModelTypes: modelTypes,
+	}
+}
+
+// ScriptHeader represents the metadata header block at the top of generated scripts
+// Records generator, timestamp, models, dialect, previous version and body checksum
+// Rendered as SQL comments and parsed back through ParseScriptHeader
+//
+// ScriptHeader 表示生成脚本顶部的元数据头部块
+// 记录生成器、时间戳、模型、方言、前一版本和正文校验和
+// 以 SQL 注释形式渲染，并可通过 ParseScriptHeader 解析回来
+type ScriptHeader struct {
+	Generator       string   // Generator name // 生成器名称
+	Timestamp       string   // Generation time in RFC3339 format // RFC3339 格式的生成时间
+	ModelTypes      []string // Go type names of GORM models // GORM 模型的 Go 类型名称
+	Dialect         string   // Database dialect name // 数据库方言名称
+	PreviousVersion uint     // Database version when script was generated // 生成脚本时的数据库版本
+	Checksum        string   // Checksum of script body // 脚本正文的校验和
+}
+
+// NewScriptHeader creates header describing the given script body
+// Meta can be nil when provenance details are not available
+//
+// NewScriptHeader 创建描述给定脚本正文的头部
+// 当没有来源信息时 meta 可以为 nil
+func NewScriptHeader(meta *ScriptMeta, previousVersion uint, body string) *ScriptHeader {
+	header := &ScriptHeader{
+		Generator:       scriptGenerator,
+		Timestamp:       time.Now().UTC().Format(time.RFC3339),
+		PreviousVersion: previousVersion,
+		Checksum:        ScriptChecksum(body),
+	}
+	if meta != nil {
+		header.Dialect = meta.Dialect
+		header.ModelTypes = meta.ModelTypes
+	}
+	return header
+}
+
+// Render formats header as SQL comment lines followed by a blank line
+//
+// Render 将头部格式化为 SQL 注释行，并以空行结尾
+func (h *ScriptHeader) Render() string {
+	var sb strings.Builder
+	writeLine := func(key string, value string) {
+		sb.WriteString(scriptHeaderPrefix + key + ": " + value + "\n")
+	}
+	writeLine("generator", h.Generator)
+	writeLine("timestamp", h.Timestamp)
+	writeLine("models", strings.Join(h.ModelTypes, ", "))
+	writeLine("dialect", h.Dialect)
+	writeLine("previous-version", strconv.FormatUint(uint64(h.PreviousVersion), 10))
+	writeLine("checksum", h.Checksum)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// Verify checks that body matches the checksum recorded in header
+//
+// Verify 检查正文是否与头部记录的校验和匹配
+func (h *ScriptHeader) Verify(body string) bool {
+	return h.Checksum == ScriptChecksum(body)
+}
+
+// ParseScriptHeader reads metadata header from script content
+// Returns header, remaining body and whether a header block was found
+//
+// ParseScriptHeader 从脚本内容读取元数据头部
+// 返回头部、剩余正文以及是否找到头部块
+func ParseScriptHeader(content string) (*ScriptHeader, string, bool) {
+	header := &ScriptHeader{}
+	var found bool
+	var rest = content
+	for strings.HasPrefix(rest, scriptHeaderPrefix) {
+		line, tail, _ := strings.Cut(rest, "\n")
+		rest = tail
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, scriptHeaderPrefix), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "generator":
+			header.Generator = value
+		case "timestamp":
+			header.Timestamp = value
+		case "models":
+			if value != "" {
+				header.ModelTypes = strings.Split(value, ", ")
+			}
+		case "dialect":
+			header.Dialect = value
+		case "previous-version":
+			num, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			header.PreviousVersion = uint(num)
+		case "checksum":
+			header.Checksum = value
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil, content, false
+	}
+	return header, strings.TrimPrefix(rest, "\n"), true
+}
+
+// ScriptChecksum computes sha256 checksum of script body
+//
+// ScriptChecksum 计算脚本正文的 sha256 校验和
+func ScriptChecksum(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:]))
+}
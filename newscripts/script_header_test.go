@@ -0,0 +1,34 @@
+package newscripts_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScriptHeader(t *testing.T) {
+	body := "CREATE TABLE `users` (`id` integer PRIMARY KEY AUTOINCREMENT);\n"
+	meta := &newscripts.ScriptMeta{
+		Dialect:    "sqlite",
+		ModelTypes: []string{"models.UserV1", "models.InfoV1"},
+	}
+	header := newscripts.NewScriptHeader(meta, 20250621174801, body)
+	content := header.Render() + body
+	t.Log(content)
+
+	parsed, rest, ok := newscripts.ParseScriptHeader(content)
+	require.True(t, ok)
+	require.Equal(t, body, rest)
+	require.Equal(t, header, parsed)
+	require.True(t, parsed.Verify(rest))
+	require.False(t, parsed.Verify(rest+"DROP TABLE `users`;\n"))
+}
+
+func TestParseScriptHeader_NoHeader(t *testing.T) {
+	body := "-- plain comment\nSELECT 1;\n"
+	parsed, rest, ok := newscripts.ParseScriptHeader(body)
+	require.False(t, ok)
+	require.Nil(t, parsed)
+	require.Equal(t, body, rest)
+}
@@ -0,0 +1,305 @@
+package newscripts
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/pkg/errors"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/zaplog"
+)
+
+// ManifestName is the checksum manifest filename kept in scripts DIR
+// Not a migration script, so it is skipped when scanning migrations
+//
+// ManifestName 是保存在脚本 DIR 中的校验和清单文件名
+// 它不是迁移脚本，因此扫描迁移时会跳过
+const ManifestName = "go-migrate.sum"
+
+// ErrScriptModified reports that an applied script was edited after being recorded in manifest
+//
+// ErrScriptModified 表示已应用的脚本在记录到清单后被修改
+var ErrScriptModified = errors.WithMessage(migrationerrors.ErrDrift, "applied migration script was modified")
+
+// ManifestEntry records the checksum of one script file
+//
+// ManifestEntry 记录单个脚本文件的校验和
+type ManifestEntry struct {
+	Name string // Script filename // 脚本文件名
+	Hash string // Checksum of file content // 文件内容的校验和
+}
+
+// Manifest contains checksums of script files with a rolling total checksum
+// Total covers every entry, so manual edits of the manifest itself are detected
+//
+// Manifest 包含脚本文件的校验和以及滚动总校验和
+// 总校验和覆盖所有条目，因此能检测到对清单本身的手动修改
+type Manifest struct {
+	Total   string           // Rolling checksum over all entries // 所有条目的滚动校验和
+	Entries []*ManifestEntry // Entries sorted by filename // 按文件名排序的条目
+}
+
+// ManifestMismatch describes an applied script whose content differs from manifest record
+//
+// ManifestMismatch 描述内容与清单记录不一致的已应用脚本
+type ManifestMismatch struct {
+	Name     string // Script filename // 脚本文件名
+	Version  uint   // Script version // 脚本版本
+	Expected string // Checksum recorded in manifest // 清单中记录的校验和
+	Actual   string // Checksum of current content, empty when file is missing // 当前内容的校验和，文件缺失时为空
+}
+
+// ComputeManifest scans scripts DIR and computes checksums of each migration script
+//
+// ComputeManifest 扫描脚本 DIR 并计算每个迁移脚本的校验和
+func ComputeManifest(options *Options) (*Manifest, error) {
+	entries, err := options.getScriptsFS().ReadDir(options.ScriptsInRoot)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	var items = make([]*ManifestEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == ManifestName {
+			continue
+		}
+		if _, err := source.DefaultParse(e.Name()); err != nil {
+			continue // Skip files that don't match migration pattern // 跳过不匹配迁移模式的文件
+		}
+		content, err := options.getScriptsFS().ReadFile(filepath.Join(options.ScriptsInRoot, e.Name()))
+		if err != nil {
+			return nil, erero.Wro(err)
+		}
+		items = append(items, &ManifestEntry{
+			Name: e.Name(),
+			Hash: ScriptChecksum(string(content)),
+		})
+	}
+	return newManifest(items), nil
+}
+
+// newManifest sorts entries and computes rolling total checksum
+//
+// newManifest 排序条目并计算滚动总校验和
+func newManifest(entries []*ManifestEntry) *Manifest {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return &Manifest{
+		Total:   computeManifestTotal(entries),
+		Entries: entries,
+	}
+}
+
+// computeManifestTotal chains entry checksums into one total checksum
+//
+// computeManifestTotal 将条目校验和串联成一个总校验和
+func computeManifestTotal(entries []*ManifestEntry) string {
+	hash := sha256.New()
+	for _, entry := range entries {
+		hash.Write([]byte(entry.Name + " " + entry.Hash + "\n"))
+	}
+	return fmt.Sprintf("sha256:%s", hex.EncodeToString(hash.Sum(nil)))
+}
+
+// Render formats manifest as text, total checksum first and then one line each entry
+//
+// Render 将清单格式化为文本，首行为总校验和，之后每个条目一行
+func (m *Manifest) Render() string {
+	var sb strings.Builder
+	sb.WriteString(m.Total + "\n")
+	for _, entry := range m.Entries {
+		sb.WriteString(entry.Name + " " + entry.Hash + "\n")
+	}
+	return sb.String()
+}
+
+// SearchEntry finds entry matching the given script filename
+//
+// SearchEntry 查找匹配给定脚本文件名的条目
+func (m *Manifest) SearchEntry(name string) *ManifestEntry {
+	for _, entry := range m.Entries {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// ReadManifest reads manifest file from scripts DIR
+// Returns false when manifest file does not exist yet
+//
+// ReadManifest 从脚本 DIR 读取清单文件
+// 当清单文件尚不存在时返回 false
+func ReadManifest(options *Options) (*Manifest, bool, error) {
+	path := filepath.Join(options.ScriptsInRoot, ManifestName)
+	if !isFile(options.getScriptsFS(), path) {
+		return nil, false, nil
+	}
+	content, err := options.getScriptsFS().ReadFile(path)
+	if err != nil {
+		return nil, false, erero.Wro(err)
+	}
+	manifest, err := parseManifest(string(content))
+	if err != nil {
+		return nil, false, erero.Wro(err)
+	}
+	return manifest, true, nil
+}
+
+// parseManifest parses manifest text and validates total checksum
+//
+// parseManifest 解析清单文本并校验总校验和
+func parseManifest(content string) (*Manifest, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	manifest := &Manifest{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if manifest.Total == "" {
+			manifest.Total = line
+			continue
+		}
+		name, hash, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, erero.Errorf("wrong manifest line: %q", line)
+		}
+		manifest.Entries = append(manifest.Entries, &ManifestEntry{Name: name, Hash: strings.TrimSpace(hash)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, erero.Wro(err)
+	}
+	if total := computeManifestTotal(manifest.Entries); total != manifest.Total {
+		return nil, erero.Errorf("manifest total checksum mismatch: expected=%s actual=%s", manifest.Total, total)
+	}
+	return manifest, nil
+}
+
+// WriteManifest recomputes checksums of scripts DIR and writes manifest file
+// Scripts at or below databaseVersion keep the checksum already recorded, so edits of applied scripts stay detectable
+// Use -1 as database version when no scripts applied, does nothing in dry-run mode
+//
+// WriteManifest 重新计算脚本 DIR 的校验和并写入清单文件
+// 版本不高于 databaseVersion 的脚本保留已记录的校验和，使对已应用脚本的修改仍能被检测到
+// 未应用任何脚本时数据库版本使用 -1，试运行模式下不执行任何操作
+func WriteManifest(options *Options, databaseVersion int) error {
+	manifest, err := ComputeManifest(options)
+	if err != nil {
+		return erero.Wro(err)
+	}
+	recorded, exists, err := ReadManifest(options)
+	if err != nil {
+		return erero.Wro(err)
+	}
+	if exists {
+		for _, entry := range manifest.Entries {
+			migration, err := source.DefaultParse(entry.Name)
+			if err != nil {
+				return erero.Wro(err)
+			}
+			if int(migration.Version) > databaseVersion {
+				continue
+			}
+			if previous := recorded.SearchEntry(entry.Name); previous != nil && previous.Hash != entry.Hash {
+				zaplog.SUG.Warnln("applied script was modified, keep recorded checksum:", entry.Name)
+				entry.Hash = previous.Hash
+			}
+		}
+		manifest = newManifest(manifest.Entries)
+	}
+	if options.DryRun {
+		zaplog.SUG.Debugln("dry-run mode", options.DryRun)
+		return nil
+	}
+	path := filepath.Join(options.ScriptsInRoot, ManifestName)
+	if err := options.getScriptsFS().WriteFile(path, []byte(manifest.Render()), 0644); err != nil {
+		return erero.Wro(err)
+	}
+	return nil
+}
+
+// ReadDatabaseVersion reads version of migration, returning -1 when no scripts applied
+//
+// ReadDatabaseVersion 读取迁移的版本，未应用脚本时返回 -1
+func ReadDatabaseVersion(migration *migrate.Migrate) (int, error) {
+	version, _, err := migration.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return -1, nil
+		}
+		return 0, erero.Wro(err)
+	}
+	return int(version), nil
+}
+
+// CompareManifest compares applied scripts with manifest records
+// Scripts with version above databaseVersion are not applied yet and can be edited freely
+// Returns nothing when manifest file does not exist
+//
+// CompareManifest 将已应用的脚本与清单记录进行比较
+// 版本高于 databaseVersion 的脚本尚未应用，可以自由编辑
+// 当清单文件不存在时不返回任何内容
+func CompareManifest(options *Options, databaseVersion uint) ([]*ManifestMismatch, error) {
+	manifest, exists, err := ReadManifest(options)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	var results []*ManifestMismatch
+	for _, entry := range manifest.Entries {
+		migration, err := source.DefaultParse(entry.Name)
+		if err != nil {
+			return nil, erero.Wro(err)
+		}
+		if migration.Version > databaseVersion {
+			continue
+		}
+		var actual string
+		path := filepath.Join(options.ScriptsInRoot, entry.Name)
+		if isFile(options.getScriptsFS(), path) {
+			content, err := options.getScriptsFS().ReadFile(path)
+			if err != nil {
+				return nil, erero.Wro(err)
+			}
+			actual = ScriptChecksum(string(content))
+		}
+		if actual != entry.Hash {
+			results = append(results, &ManifestMismatch{
+				Name:     entry.Name,
+				Version:  migration.Version,
+				Expected: entry.Hash,
+				Actual:   actual,
+			})
+		}
+	}
+	return results, nil
+}
+
+// VerifyManifest checks applied scripts against manifest and returns ErrScriptModified on mismatch
+//
+// VerifyManifest 根据清单检查已应用的脚本，不一致时返回 ErrScriptModified
+func VerifyManifest(options *Options, databaseVersion uint) error {
+	mismatches, err := CompareManifest(options, databaseVersion)
+	if err != nil {
+		return erero.Wro(err)
+	}
+	if len(mismatches) > 0 {
+		names := make([]string, 0, len(mismatches))
+		for _, mismatch := range mismatches {
+			names = append(names, mismatch.Name)
+		}
+		return erero.Wrapf(ErrScriptModified, "database-version=%d scripts=%v", databaseVersion, names)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package newscripts_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyManifest(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(name string, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+	}
+	writeFile("00001_script.up.sql", "CREATE TABLE `a` (`id` integer);\n")
+	writeFile("00001_script.down.sql", "DROP TABLE `a`;\n")
+	writeFile("00002_script.up.sql", "CREATE TABLE `b` (`id` integer);\n")
+	writeFile("00002_script.down.sql", "DROP TABLE `b`;\n")
+
+	options := newscripts.NewOptions(root)
+	require.NoError(t, newscripts.VerifyManifest(options, 2)) // No manifest yet // 尚无清单
+	require.NoError(t, newscripts.WriteManifest(options, -1))
+
+	manifest, exists, err := newscripts.ReadManifest(options)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Len(t, manifest.Entries, 4)
+	require.NoError(t, newscripts.VerifyManifest(options, 2))
+
+	// Editing unapplied script is allowed // 允许编辑未应用的脚本
+	writeFile("00002_script.up.sql", "CREATE TABLE `b` (`id` integer, `name` text);\n")
+	require.NoError(t, newscripts.VerifyManifest(options, 1))
+
+	// Editing applied script is reported // 编辑已应用的脚本会被报告
+	writeFile("00001_script.up.sql", "CREATE TABLE `a` (`id` integer, `name` text);\n")
+	mismatches, err := newscripts.CompareManifest(options, 1)
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	require.Equal(t, "00001_script.up.sql", mismatches[0].Name)
+	require.ErrorIs(t, newscripts.VerifyManifest(options, 1), newscripts.ErrScriptModified)
+
+	// Rewriting manifest keeps checksum of applied script, unapplied script is refreshed
+	// 重写清单时保留已应用脚本的校验和，未应用的脚本会被刷新
+	require.NoError(t, newscripts.WriteManifest(options, 1))
+	require.ErrorIs(t, newscripts.VerifyManifest(options, 1), newscripts.ErrScriptModified)
+	require.NoError(t, newscripts.VerifyManifest(options, 0))
+	mismatches, err = newscripts.CompareManifest(options, 2)
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+}
@@ -0,0 +1,91 @@
+package newscripts
+
+import (
+	"strings"
+)
+
+const (
+	// GeneratedBeginMarker marks the start of generated region in scripts
+	// Content outside the region is hand-written and kept when the script is regenerated
+	//
+	// GeneratedBeginMarker 标记脚本中生成区域的开始
+	// 区域外的内容为手写内容，重新生成脚本时会保留
+	GeneratedBeginMarker = "-- +go-migrate:generated-begin"
+
+	// GeneratedEndMarker marks the end of generated region in scripts
+	//
+	// GeneratedEndMarker 标记脚本中生成区域的结束
+	GeneratedEndMarker = "-- +go-migrate:generated-end"
+)
+
+// WrapGenerated brackets generated script body with begin and end markers
+//
+// WrapGenerated 使用开始和结束标记包裹生成的脚本正文
+func WrapGenerated(script string) string {
+	return GeneratedBeginMarker + "\n" + script + GeneratedEndMarker + "\n"
+}
+
+// SplitGenerated splits script content into hand-written head, generated region and hand-written tail
+// Generated region excludes the marker lines, returns false when markers are missing
+//
+// SplitGenerated 将脚本内容拆分为手写头部、生成区域和手写尾部
+// 生成区域不包含标记行，缺少标记时返回 false
+func SplitGenerated(content string) (string, string, string, bool) {
+	beginIdx := indexMarkerLine(content, GeneratedBeginMarker, 0)
+	if beginIdx < 0 {
+		return "", "", "", false
+	}
+	regionIdx := beginIdx + len(GeneratedBeginMarker) + 1
+	if regionIdx > len(content) {
+		return "", "", "", false
+	}
+	endIdx := indexMarkerLine(content, GeneratedEndMarker, regionIdx)
+	if endIdx < 0 {
+		return "", "", "", false
+	}
+	tailIdx := min(len(content), endIdx+len(GeneratedEndMarker)+1)
+	return content[:beginIdx], content[regionIdx:endIdx], content[tailIdx:], true
+}
+
+// indexMarkerLine finds marker occupying a whole line at or after offset
+//
+// indexMarkerLine 查找从 offset 开始独占一整行的标记
+func indexMarkerLine(content string, marker string, offset int) int {
+	for offset <= len(content) {
+		idx := strings.Index(content[offset:], marker)
+		if idx < 0 {
+			return -1
+		}
+		idx += offset
+		lineStart := idx == 0 || content[idx-1] == '\n'
+		lineEnd := idx+len(marker) == len(content) || content[idx+len(marker)] == '\n'
+		if lineStart && lineEnd {
+			return idx
+		}
+		offset = idx + len(marker)
+	}
+	return -1
+}
+
+// MergeGenerated replaces generated region of existing content with new generated body
+// Hand-written content outside the markers is kept as is
+// Existing content without markers is kept above a newly added generated region,
+// unless it is blank or equals the generated body, then the wrapped body is returned alone
+//
+// MergeGenerated 用新生成的正文替换已有内容中的生成区域
+// 标记之外的手写内容保持不变
+// 已有内容没有标记时保留在新添加的生成区域之上，
+// 除非其为空白或与生成的正文相同，此时仅返回包裹后的正文
+func MergeGenerated(existing string, script string) string {
+	head, _, tail, ok := SplitGenerated(existing)
+	if !ok {
+		if strings.TrimSpace(existing) == "" || strings.TrimSpace(existing) == strings.TrimSpace(script) {
+			return WrapGenerated(script)
+		}
+		if !strings.HasSuffix(existing, "\n") {
+			existing += "\n"
+		}
+		return existing + WrapGenerated(script)
+	}
+	return head + WrapGenerated(script) + tail
+}
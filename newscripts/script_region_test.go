@@ -0,0 +1,74 @@
+package newscripts_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeGenerated(t *testing.T) {
+	existing := "-- data fix\nUPDATE `users` SET `name` = '' WHERE `name` IS NULL;\n\n" +
+		newscripts.WrapGenerated("ALTER TABLE `users` ADD `name` text;\n") +
+		"\nINSERT INTO `users` (`name`) VALUES ('admin');\n"
+
+	head, region, tail, ok := newscripts.SplitGenerated(existing)
+	require.True(t, ok)
+	require.Equal(t, "-- data fix\nUPDATE `users` SET `name` = '' WHERE `name` IS NULL;\n\n", head)
+	require.Equal(t, "ALTER TABLE `users` ADD `name` text;\n", region)
+	require.Equal(t, "\nINSERT INTO `users` (`name`) VALUES ('admin');\n", tail)
+
+	merged := newscripts.MergeGenerated(existing, "ALTER TABLE `users` ADD `name` text;\n\nALTER TABLE `users` ADD `rank` integer;\n")
+	t.Log(merged)
+	require.Equal(t, head+newscripts.WrapGenerated("ALTER TABLE `users` ADD `name` text;\n\nALTER TABLE `users` ADD `rank` integer;\n")+tail, merged)
+}
+
+func TestMergeGenerated_NoMarkers(t *testing.T) {
+	_, _, _, ok := newscripts.SplitGenerated("ALTER TABLE `users` ADD `name` text;\n")
+	require.False(t, ok)
+
+	// Hand-written SQL is kept above the new generated region
+	// 手写 SQL 保留在新的生成区域之上
+	merged := newscripts.MergeGenerated("ALTER TABLE `users` ADD `name` text;", "SELECT 1;\n")
+	require.Equal(t, "ALTER TABLE `users` ADD `name` text;\n"+newscripts.WrapGenerated("SELECT 1;\n"), merged)
+
+	// Merging again replaces the generated region and keeps hand-written SQL
+	// 再次合并时替换生成区域并保留手写 SQL
+	merged = newscripts.MergeGenerated(merged, "SELECT 2;\n")
+	require.Equal(t, "ALTER TABLE `users` ADD `name` text;\n"+newscripts.WrapGenerated("SELECT 2;\n"), merged)
+
+	// Unmarked content equal to the generated body is not duplicated
+	// 与生成正文相同的无标记内容不会重复
+	require.Equal(t, newscripts.WrapGenerated("SELECT 1;\n"), newscripts.MergeGenerated("SELECT 1;\n", "SELECT 1;\n"))
+	require.Equal(t, newscripts.WrapGenerated("SELECT 1;\n"), newscripts.MergeGenerated("", "SELECT 1;\n"))
+}
+
+func TestNewScriptInfo_WriteScripts_KeepHandWritten(t *testing.T) {
+	root := t.TempDir()
+	options := newscripts.NewOptions(root)
+	options.MarkGenerated = true
+	options.ScriptsFS = newscripts.NewMemFS()
+
+	scriptInfo := &newscripts.NewScriptInfo{
+		Action:      newscripts.CreateScript,
+		ForwardName: "00001_script.up.sql",
+		ReverseName: "00001_script.down.sql",
+	}
+	scriptInfo.WriteScripts(newTemplateMigrationOps(t)[:1], options)
+
+	path := root + "/00001_script.up.sql"
+	content, err := options.ScriptsFS.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, newscripts.WrapGenerated("CREATE TABLE `users` (`id` integer);\n"), string(content))
+
+	handWritten := "INSERT INTO `users` (`id`) VALUES (1);\n"
+	require.NoError(t, options.ScriptsFS.WriteFile(path, append(content, []byte(handWritten)...), 0644))
+
+	scriptInfo.Action = newscripts.UpdateScript
+	scriptInfo.WriteScripts(newTemplateMigrationOps(t), options)
+
+	content, err = options.ScriptsFS.ReadFile(path)
+	require.NoError(t, err)
+	t.Log(string(content))
+	require.Equal(t, newscripts.WrapGenerated("CREATE TABLE `users` (`id` integer);\n\nCREATE INDEX `idx_users_id` ON `users`(`id`);\n")+handWritten, string(content))
+}
@@ -0,0 +1,168 @@
+package newscripts
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+)
+
+// SeverityOff disables a rule when set in RuleOptions
+//
+// SeverityOff 在 RuleOptions 中设置时禁用规则
+const SeverityOff Severity = "OFF"
+
+// RuleName identifies a static analysis rule applied to script bodies
+//
+// RuleName 标识应用于脚本正文的静态分析规则
+type RuleName string
+
+const (
+	RuleDropTableInUp  RuleName = "drop-table-in-up"     // DROP TABLE in up script // 正向脚本中的 DROP TABLE
+	RuleUpdateNoWhere  RuleName = "update-without-where" // UPDATE without WHERE // 不带 WHERE 的 UPDATE
+	RuleDeleteNoWhere  RuleName = "delete-without-where" // DELETE without WHERE // 不带 WHERE 的 DELETE
+	RuleAlterHotTable  RuleName = "alter-hot-table"      // ALTER TABLE on configured hot tables // 对配置的热点表执行 ALTER TABLE
+	RuleMissingReverse RuleName = "missing-reverse"      // Forward statement without reverse statement // 缺少反向语句的正向语句
+)
+
+// defaultRuleSeverities contains severity of each rule when not configured
+//
+// defaultRuleSeverities 包含未配置时每条规则的严重程度
+var defaultRuleSeverities = map[RuleName]Severity{
+	RuleDropTableInUp:  SeverityError,
+	RuleUpdateNoWhere:  SeverityError,
+	RuleDeleteNoWhere:  SeverityError,
+	RuleAlterHotTable:  SeverityWarning,
+	RuleMissingReverse: SeverityWarning,
+}
+
+// RuleOptions configures static analysis rules of script bodies
+// Severities overrides default severity of each rule, use SeverityOff to disable one
+//
+// RuleOptions 配置脚本正文的静态分析规则
+// Severities 覆盖每条规则的默认严重程度，使用 SeverityOff 禁用规则
+type RuleOptions struct {
+	Severities map[RuleName]Severity // Severity overrides of rules // 规则严重程度覆盖
+	HotTables  []string              // Tables where ALTER TABLE needs attention // 执行 ALTER TABLE 需要关注的表
+}
+
+// NewRuleOptions creates rule options using default severities
+//
+// NewRuleOptions 使用默认严重程度创建规则选项
+func NewRuleOptions() *RuleOptions {
+	return &RuleOptions{
+		Severities: map[RuleName]Severity{},
+		HotTables:  []string{},
+	}
+}
+
+// severity returns configured or default severity of rule
+//
+// severity 返回规则配置的或默认的严重程度
+func (ruleOptions *RuleOptions) severity(name RuleName) Severity {
+	if ruleOptions != nil {
+		if severity, ok := ruleOptions.Severities[name]; ok {
+			return severity
+		}
+	}
+	return defaultRuleSeverities[name]
+}
+
+// isHotTable reports whether table is configured as hot table
+//
+// isHotTable 判断表是否被配置为热点表
+func (ruleOptions *RuleOptions) isHotTable(table string) bool {
+	return ruleOptions != nil && slices.ContainsFunc(ruleOptions.HotTables, func(hotTable string) bool {
+		return strings.EqualFold(hotTable, table)
+	})
+}
+
+// LintScript applies static analysis rules to a pair of up and down script bodies
+// Statements are classified through checkmigration so rules share one classification
+//
+// LintScript 对一对正向和反向脚本正文应用静态分析规则
+// 语句通过 checkmigration 分类，使规则共享同一分类
+func LintScript(name string, forwardScript string, reverseScript string, ruleOptions *RuleOptions) []*ScriptProblem {
+	report := &CheckReport{}
+	addProblem := func(rule RuleName, format string, args ...any) {
+		if severity := ruleOptions.severity(rule); severity != SeverityOff {
+			report.add(severity, name, "["+string(rule)+"] "+format, args...)
+		}
+	}
+
+	reverseStatements := checkmigration.SplitStatements(reverseScript)
+	for _, statement := range checkmigration.SplitStatements(forwardScript) {
+		normalized := checkmigration.NormalizeStatement(statement)
+		target := checkmigration.StatementTarget(statement)
+		switch {
+		case strings.HasPrefix(normalized, "DROP TABLE"):
+			addProblem(RuleDropTableInUp, "up script drops table %s", target)
+		case strings.HasPrefix(normalized, "UPDATE ") && !strings.Contains(normalized, " WHERE "):
+			addProblem(RuleUpdateNoWhere, "UPDATE on %s without WHERE", target)
+		case strings.HasPrefix(normalized, "DELETE ") && !strings.Contains(normalized, " WHERE "):
+			addProblem(RuleDeleteNoWhere, "DELETE on %s without WHERE", target)
+		case strings.HasPrefix(normalized, "ALTER TABLE") && ruleOptions.isHotTable(target):
+			addProblem(RuleAlterHotTable, "ALTER TABLE on hot table %s", target)
+		}
+
+		if migrationOp, match := checkmigration.ClassifyStatement(statement); match {
+			if !hasReverseStatement(migrationOp, target, reverseStatements) {
+				addProblem(RuleMissingReverse, "no %s reverse of: %s", migrationOp.Kind.ReverseSubstr, statement)
+			}
+		}
+	}
+	return report.Problems
+}
+
+// hasReverseStatement reports whether reverse statements contain the reverse of migration operation
+//
+// hasReverseStatement 判断反向语句中是否包含迁移操作的反向语句
+func hasReverseStatement(migrationOp *checkmigration.MigrationOp, target string, reverseStatements []string) bool {
+	for _, statement := range reverseStatements {
+		if strings.HasPrefix(checkmigration.NormalizeStatement(statement), migrationOp.Kind.ReverseSubstr) &&
+			strings.EqualFold(checkmigration.StatementTarget(statement), target) {
+			return true
+		}
+	}
+	return false
+}
+
+// LintScripts applies static analysis rules to each script pair in scripts DIR
+//
+// LintScripts 对脚本 DIR 中的每对脚本应用静态分析规则
+func LintScripts(options *Options) (*CheckReport, error) {
+	files, _, err := scanScriptFiles(options)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	readScript := func(name string) (string, error) {
+		content, err := options.getScriptsFS().ReadFile(filepath.Join(options.ScriptsInRoot, name))
+		if err != nil {
+			return "", erero.Wro(err)
+		}
+		return string(content), nil
+	}
+
+	report := &CheckReport{}
+	for _, file := range files {
+		if file.Migration.Direction != source.Up {
+			continue
+		}
+		forwardScript, err := readScript(file.Migration.Raw)
+		if err != nil {
+			return nil, erero.Wro(err)
+		}
+		var reverseScript string
+		reverseName := strings.Replace(file.Migration.Raw, "."+string(source.Up)+".", "."+string(source.Down)+".", 1)
+		if slices.ContainsFunc(files, func(item *scriptFile) bool { return item.Migration.Raw == reverseName }) {
+			if reverseScript, err = readScript(reverseName); err != nil {
+				return nil, erero.Wro(err)
+			}
+		}
+		report.Problems = append(report.Problems, LintScript(file.Migration.Raw, forwardScript, reverseScript, options.Rules)...)
+	}
+	return report, nil
+}
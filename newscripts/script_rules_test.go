@@ -0,0 +1,45 @@
+package newscripts_test
+
+import (
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/neatjson/neatjsons"
+)
+
+func TestLintScript(t *testing.T) {
+	forwardScript := "" +
+		"CREATE TABLE `posts` (`id` integer PRIMARY KEY);\n" +
+		"ALTER TABLE `users` ADD `age` bigint;\n" +
+		"CREATE INDEX `idx_users_age` ON `users`(`age`);\n" +
+		"DROP TABLE `legacy`;\n" +
+		"update users set age = 0;\n" +
+		"DELETE FROM posts WHERE id = 0;\n"
+	reverseScript := "" +
+		"DROP INDEX `idx_users_age`;\n" +
+		"SELECT TODO / PANIC / RAISE / THROW; -- ALTER TABLE; -- TODO\n" +
+		"DROP TABLE `posts`;\n"
+
+	ruleOptions := newscripts.NewRuleOptions()
+	ruleOptions.HotTables = []string{"users"}
+	problems := newscripts.LintScript("00001_script.up.sql", forwardScript, reverseScript, ruleOptions)
+	t.Log(neatjsons.S(problems))
+
+	counts := map[newscripts.Severity]int{}
+	for _, problem := range problems {
+		counts[problem.Severity]++
+	}
+	require.Equal(t, 2, counts[newscripts.SeverityError])   // DROP TABLE in up, UPDATE without WHERE
+	require.Equal(t, 2, counts[newscripts.SeverityWarning]) // ALTER TABLE on hot table, missing reverse of ALTER TABLE
+
+	ruleOptions.Severities[newscripts.RuleDropTableInUp] = newscripts.SeverityOff
+	ruleOptions.Severities[newscripts.RuleMissingReverse] = newscripts.SeverityError
+	problems = newscripts.LintScript("00001_script.up.sql", forwardScript, reverseScript, ruleOptions)
+	counts = map[newscripts.Severity]int{}
+	for _, problem := range problems {
+		counts[problem.Severity]++
+	}
+	require.Equal(t, 2, counts[newscripts.SeverityError])   // UPDATE without WHERE, missing reverse of ALTER TABLE
+	require.Equal(t, 1, counts[newscripts.SeverityWarning]) // ALTER TABLE on hot table
+}
@@ -0,0 +1,96 @@
+package newscripts
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+)
+
+// DefaultForwardTemplate renders up scripts in the standard format
+// Statements are separated by blank lines and each ends with a semicolon
+//
+// DefaultForwardTemplate 以标准格式渲染正向脚本
+// 语句之间以空行分隔，每条语句以分号结尾
+var DefaultForwardTemplate = template.Must(template.New("forward").Parse("{{.ForwardScript}}"))
+
+// DefaultReverseTemplate renders down scripts in the standard format
+// Operations are reversed and TODO placeholders mark statements without reverse SQL
+//
+// DefaultReverseTemplate 以标准格式渲染反向脚本
+// 操作按倒序排列，没有反向 SQL 的语句以 TODO 占位符标记
+var DefaultReverseTemplate = template.Must(template.New("reverse").Parse("{{.ReverseScript}}"))
+
+// ScriptTemplateData is the data passed to forward and reverse script templates
+// ForwardScript and ReverseScript hold the standard format, so templates can wrap them
+// Templates needing full control can range over MigrationOps instead
+//
+// ScriptTemplateData 是传递给正向和反向脚本模板的数据
+// ForwardScript 和 ReverseScript 保存标准格式，模板可以直接包裹它们
+// 需要完全控制的模板可以遍历 MigrationOps
+type ScriptTemplateData struct {
+	MigrationOps    checkmigration.MigrationOps // Migration operations in forward sequence // 按正向顺序排列的迁移操作
+	Version         uint                        // Script version // 脚本版本
+	Description     string                      // Script description from filename // 来自文件名的脚本描述
+	Dialect         string                      // Database dialect name, empty when unknown // 数据库方言名称，未知时为空
+	ForwardScript   string                      // Forward statements in standard format // 标准格式的正向语句
+	ReverseScript   string                      // Reverse statements in standard format // 标准格式的反向语句
+	ReverseComplete bool                        // Whether each operation has reverse SQL // 是否每个操作都有反向 SQL
+}
+
+// newScriptTemplateData collects template data from migration operations and script names
+//
+// newScriptTemplateData 从迁移操作和脚本名称收集模板数据
+func (scriptInfo *NewScriptInfo) newScriptTemplateData(migrationOps checkmigration.MigrationOps) (*ScriptTemplateData, error) {
+	migration, err := source.DefaultParse(scriptInfo.ForwardName)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	reverseScript, reverseComplete := migrationOps.GetReverseScript()
+	data := &ScriptTemplateData{
+		MigrationOps:    migrationOps,
+		Version:         migration.Version,
+		Description:     migration.Identifier,
+		ForwardScript:   migrationOps.GetForwardScript(),
+		ReverseScript:   reverseScript,
+		ReverseComplete: reverseComplete,
+	}
+	if scriptInfo.Meta != nil {
+		data.Dialect = scriptInfo.Meta.Dialect
+	}
+	return data, nil
+}
+
+// RenderScripts renders forward and reverse script bodies through templates in options
+// Falls back to default templates when options do not set them
+//
+// RenderScripts 通过选项中的模板渲染正向和反向脚本正文
+// 选项未设置模板时回退到默认模板
+func (scriptInfo *NewScriptInfo) RenderScripts(migrationOps checkmigration.MigrationOps, options *Options) (string, string, error) {
+	data, err := scriptInfo.newScriptTemplateData(migrationOps)
+	if err != nil {
+		return "", "", erero.Wro(err)
+	}
+	forwardScript, err := executeTemplate(options.getForwardTemplate(), data)
+	if err != nil {
+		return "", "", erero.Wro(err)
+	}
+	reverseScript, err := executeTemplate(options.getReverseTemplate(), data)
+	if err != nil {
+		return "", "", erero.Wro(err)
+	}
+	return forwardScript, reverseScript, nil
+}
+
+// executeTemplate executes template with data and returns rendered text
+//
+// executeTemplate 使用数据执行模板并返回渲染后的文本
+func executeTemplate(tmpl *template.Template, data *ScriptTemplateData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", erero.Wro(err)
+	}
+	return sb.String(), nil
+}
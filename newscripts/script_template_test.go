@@ -0,0 +1,70 @@
+package newscripts_test
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/go-xlan/go-migrate/checkmigration"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/stretchr/testify/require"
+)
+
+func newTemplateMigrationOps(t *testing.T) checkmigration.MigrationOps {
+	var migrationOps checkmigration.MigrationOps
+	for _, forwardSQL := range []string{
+		"CREATE TABLE `users` (`id` integer)",
+		"CREATE INDEX `idx_users_id` ON `users`(`id`)",
+	} {
+		migrationOp, ok := checkmigration.NewMigrationOp(forwardSQL)
+		require.True(t, ok)
+		migrationOps = append(migrationOps, migrationOp)
+	}
+	return migrationOps
+}
+
+func TestNewScriptInfo_RenderScripts(t *testing.T) {
+	migrationOps := newTemplateMigrationOps(t)
+	scriptInfo := &newscripts.NewScriptInfo{
+		Action:      newscripts.CreateScript,
+		ForwardName: "00003_add_users.up.sql",
+		ReverseName: "00003_add_users.down.sql",
+	}
+
+	options := newscripts.NewOptions(t.TempDir())
+	forwardScript, reverseScript, err := scriptInfo.RenderScripts(migrationOps, options)
+	require.NoError(t, err)
+	require.Equal(t, migrationOps.GetForwardScript(), forwardScript)
+	expectReverse, _ := migrationOps.GetReverseScript()
+	require.Equal(t, expectReverse, reverseScript)
+}
+
+func TestNewScriptInfo_RenderScripts_CustomTemplate(t *testing.T) {
+	migrationOps := newTemplateMigrationOps(t)
+	scriptInfo := &newscripts.NewScriptInfo{
+		Action:      newscripts.CreateScript,
+		ForwardName: "00003_add_users.up.sql",
+		ReverseName: "00003_add_users.down.sql",
+		Meta:        &newscripts.ScriptMeta{Dialect: "postgres"},
+	}
+
+	options := newscripts.NewOptions(t.TempDir())
+	options.ForwardTemplate = template.Must(template.New("forward").Parse(
+		"-- owner: team-db version={{.Version}} description={{.Description}} dialect={{.Dialect}}\n" +
+			"{{if eq .Dialect \"postgres\"}}SET lock_timeout = '5s';\n{{end}}" +
+			"BEGIN;\n{{range .MigrationOps}}{{.GetForwardSQL}};\n{{end}}COMMIT;\n",
+	))
+	options.ReverseTemplate = template.Must(template.New("reverse").Parse("BEGIN;\n{{.ReverseScript}}COMMIT;\n"))
+
+	forwardScript, reverseScript, err := scriptInfo.RenderScripts(migrationOps, options)
+	require.NoError(t, err)
+	t.Log(forwardScript)
+	require.Equal(t, "-- owner: team-db version=3 description=add_users dialect=postgres\n"+
+		"SET lock_timeout = '5s';\n"+
+		"BEGIN;\n"+
+		"CREATE TABLE `users` (`id` integer);\n"+
+		"CREATE INDEX `idx_users_id` ON `users`(`id`);\n"+
+		"COMMIT;\n", forwardScript)
+	t.Log(reverseScript)
+	expectReverse, _ := migrationOps.GetReverseScript()
+	require.Equal(t, "BEGIN;\n"+expectReverse+"COMMIT;\n", reverseScript)
+}
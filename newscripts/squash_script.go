@@ -0,0 +1,288 @@
+package newscripts
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/pkg/errors"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/zaplog"
+	"gorm.io/gorm"
+)
+
+// ArchiveDirName is the sub DIR of scripts DIR where squashed scripts are moved
+// Sub DIRs are skipped when scanning migrations, so archived scripts are never executed
+//
+// ArchiveDirName 是脚本 DIR 中存放被合并脚本的子 DIR
+// 扫描迁移时会跳过子 DIR，因此归档脚本不会被执行
+const ArchiveDirName = "archive"
+
+// ErrSquashAhead reports that the database has not reached the squash version yet
+// Squashing then would remove scripts that this database still needs to run
+//
+// ErrSquashAhead 表示数据库尚未达到合并版本
+// 此时合并会移除该数据库仍需执行的脚本
+var ErrSquashAhead = errors.New("database version is below squash version")
+
+// SchemaDump contains statements that re-create database schema
+//
+// SchemaDump 包含重建数据库结构的语句
+type SchemaDump struct {
+	Tables     []string // Table names in creation sequence // 按创建顺序排列的表名
+	Statements []string // DDL statements without trailing semicolon // 不带结尾分号的 DDL 语句
+}
+
+// SchemaDumper dumps schema of the given database into DDL statements
+//
+// SchemaDumper 将给定数据库的结构导出为 DDL 语句
+type SchemaDumper func(db *gorm.DB) (*SchemaDump, error)
+
+// DumpSchema dumps schema using built-in dumper matching database dialect
+// Supports sqlite and mysql, other dialects need a custom SchemaDumper
+//
+// DumpSchema 使用与数据库方言匹配的内置导出器导出结构
+// 支持 sqlite 和 mysql，其它方言需要自定义 SchemaDumper
+func DumpSchema(db *gorm.DB) (*SchemaDump, error) {
+	switch name := db.Dialector.Name(); name {
+	case "sqlite":
+		return dumpSqliteSchema(db)
+	case "mysql":
+		return dumpMysqlSchema(db)
+	default:
+		return nil, erero.Errorf("no built-in schema dumper for dialect %q, set SchemaDumper in config", name)
+	}
+}
+
+// dumpSqliteSchema reads table and index DDL from sqlite_master in creation sequence
+//
+// dumpSqliteSchema 按创建顺序从 sqlite_master 读取表和索引的 DDL
+func dumpSqliteSchema(db *gorm.DB) (*SchemaDump, error) {
+	type sqliteObject struct {
+		Type string
+		Name string
+		SQL  string
+	}
+	var objects []*sqliteObject
+	if err := db.Raw("SELECT type, name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY rowid").Scan(&objects).Error; err != nil {
+		return nil, erero.Wro(err)
+	}
+	dump := &SchemaDump{}
+	for _, object := range objects {
+		if object.Type == "table" {
+			dump.Tables = append(dump.Tables, object.Name)
+		}
+		dump.Statements = append(dump.Statements, object.SQL)
+	}
+	return dump, nil
+}
+
+// dumpMysqlSchema reads table DDL through SHOW CREATE TABLE
+//
+// dumpMysqlSchema 通过 SHOW CREATE TABLE 读取表的 DDL
+func dumpMysqlSchema(db *gorm.DB) (*SchemaDump, error) {
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	dump := &SchemaDump{}
+	for _, table := range tables {
+		var name, createSQL string
+		if err := db.Raw("SHOW CREATE TABLE `"+table+"`").Row().Scan(&name, &createSQL); err != nil {
+			return nil, erero.Wro(err)
+		}
+		dump.Tables = append(dump.Tables, table)
+		dump.Statements = append(dump.Statements, createSQL)
+	}
+	return dump, nil
+}
+
+// SquashResult describes baseline scripts and archived scripts of a squash
+//
+// SquashResult 描述合并产生的基线脚本和被归档的脚本
+type SquashResult struct {
+	ThroughVersion uint     // Last squashed version, kept as baseline version // 最后一个被合并的版本，作为基线版本保留
+	ForwardName    string   // Baseline up script filename // 基线正向脚本文件名
+	ReverseName    string   // Baseline down script filename // 基线反向脚本文件名
+	ForwardScript  string   // Baseline up script content // 基线正向脚本内容
+	ReverseScript  string   // Baseline down script content // 基线反向脚本内容
+	ArchivedNames  []string // Script filenames moved into archive DIR // 移入归档 DIR 的脚本文件名
+	ArchiveRoot    string   // Archive DIR path // 归档 DIR 路径
+}
+
+// SquashScripts collapses scripts up to throughVersion into one baseline script
+// Applies the range to an empty scratch database and dumps the resulting schema
+// The baseline keeps throughVersion, so databases at or past it are not affected
+// Refuses with ErrSquashAhead when databaseVersion is below throughVersion
+// Each script runs as one Exec, so a MySQL scratch DSN needs multiStatements=true
+//
+// SquashScripts 将 throughVersion 及之前的脚本合并为一个基线脚本
+// 在空的临时数据库上执行该范围的脚本并导出得到的结构
+// 基线保留 throughVersion 版本号，因此已达到或超过该版本的数据库不受影响
+// 当 databaseVersion 低于 throughVersion 时以 ErrSquashAhead 拒绝
+// 每个脚本以一次 Exec 执行，因此 MySQL 临时库的 DSN 需要 multiStatements=true
+func SquashScripts(scratchDB *gorm.DB, dumper SchemaDumper, databaseVersion uint, throughVersion uint, options *Options) (*SquashResult, error) {
+	if databaseVersion < throughVersion {
+		return nil, erero.Wrapf(ErrSquashAhead, "database-version=%d through-version=%d", databaseVersion, throughVersion)
+	}
+	migrations, err := newMigrationsFromPath(options)
+	if err != nil {
+		return nil, err
+	}
+	throughMigration, ok := migrations.Up(throughVersion)
+	if !ok {
+		return nil, erero.Errorf("no up script with version %d", throughVersion)
+	}
+
+	// Apply scripts to scratch database in version sequence
+	// 按版本顺序在临时数据库上执行脚本
+	var archivedNames []string
+	for version, ok := migrations.First(); ok && version <= throughVersion; version, ok = migrations.Next(version) {
+		forward, ok := migrations.Up(version)
+		if !ok {
+			return nil, erero.Errorf("no up script with version %d", version)
+		}
+		content, err := options.getScriptsFS().ReadFile(filepath.Join(options.ScriptsInRoot, forward.Raw))
+		if err != nil {
+			return nil, erero.Wro(err)
+		}
+		if strings.TrimSpace(string(content)) != "" {
+			if err := scratchDB.Exec(string(content)).Error; err != nil {
+				return nil, erero.Wrapf(err, "apply script %s", forward.Raw)
+			}
+		}
+		archivedNames = append(archivedNames, forward.Raw)
+		if reverse, ok := migrations.Down(version); ok {
+			archivedNames = append(archivedNames, reverse.Raw)
+		}
+	}
+
+	if dumper == nil {
+		dumper = DumpSchema
+	}
+	dump, err := dumper(scratchDB)
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+
+	prefix, _, _ := strings.Cut(throughMigration.Raw, "_")
+	suffix := filepath.Ext(throughMigration.Raw)
+	result := &SquashResult{
+		ThroughVersion: throughVersion,
+		ForwardName:    fmt.Sprintf("%s_baseline.%s%s", prefix, source.Up, suffix),
+		ReverseName:    fmt.Sprintf("%s_baseline.%s%s", prefix, source.Down, suffix),
+		ForwardScript:  newBaselineForwardScript(dump, throughVersion),
+		ReverseScript:  newBaselineReverseScript(scratchDB, dump),
+		ArchivedNames:  archivedNames,
+		ArchiveRoot:    filepath.Join(options.ScriptsInRoot, ArchiveDirName, prefix),
+	}
+	return result, nil
+}
+
+// newBaselineForwardScript joins dumped statements into baseline up script
+//
+// newBaselineForwardScript 将导出的语句拼接为基线正向脚本
+func newBaselineForwardScript(dump *SchemaDump, throughVersion uint) string {
+	var sqs = make([]string, 0, len(dump.Statements)+1)
+	sqs = append(sqs, fmt.Sprintf("-- baseline: schema of scripts squashed through version %d", throughVersion))
+	for _, statement := range dump.Statements {
+		sqs = append(sqs, strings.TrimSuffix(strings.TrimSpace(statement), ";")+";")
+	}
+	return strings.Join(sqs, "\n\n") + "\n"
+}
+
+// newBaselineReverseScript drops dumped tables in reverse creation sequence
+// Table names are quoted by the dialect of db, so reserved and mixed-case names stay valid
+//
+// newBaselineReverseScript 按创建顺序的倒序删除导出的表
+// 表名按 db 的方言加引号，使保留字和大小写混合的名称保持有效
+func newBaselineReverseScript(db *gorm.DB, dump *SchemaDump) string {
+	var sqs = make([]string, 0, len(dump.Tables))
+	for idx := len(dump.Tables) - 1; idx >= 0; idx-- {
+		sqs = append(sqs, fmt.Sprintf("DROP TABLE %s;", db.Statement.Quote(dump.Tables[idx])))
+	}
+	if len(sqs) == 0 {
+		return ""
+	}
+	return strings.Join(sqs, "\n\n") + "\n"
+}
+
+// Apply moves squashed scripts into archive DIR and writes baseline scripts
+// Rolls back moved scripts, written baseline scripts and created archive DIRs when any step fails
+// Does nothing in dry-run mode
+//
+// Apply 将被合并的脚本移入归档 DIR 并写入基线脚本
+// 任一步骤失败时回滚已移动的脚本、已写入的基线脚本和已创建的归档 DIR
+// 试运行模式下不执行任何操作
+func (result *SquashResult) Apply(options *Options) error {
+	if options.DryRun {
+		zaplog.SUG.Debugln("dry-run mode", options.DryRun)
+		return nil
+	}
+	if options.SurveyWritten {
+		var written bool
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("archive %d scripts and write baseline scripts?", len(result.ArchivedNames)),
+			Default: false,
+		}
+		if err := survey.AskOne(prompt, &written); err != nil {
+			return erero.Wro(err)
+		}
+		if !written {
+			zaplog.SUG.Debugln("input_written", written)
+			return nil
+		}
+	}
+	fsys := options.getScriptsFS()
+	// Archive DIRs missing before are removed on rollback, deeper one first
+	// 之前不存在的归档 DIR 在回滚时删除，较深的先删除
+	var createdDirs []string
+	for _, path := range []string{result.ArchiveRoot, filepath.Dir(result.ArchiveRoot)} {
+		if _, err := fsys.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			createdDirs = append(createdDirs, path)
+		}
+	}
+	if err := fsys.MkdirAll(result.ArchiveRoot, 0755); err != nil {
+		return erero.Wro(err)
+	}
+	var movedNames []string
+	var writtenPaths []string
+	rollback := func(cause error) error {
+		for _, path := range writtenPaths {
+			if err := fsys.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				zaplog.SUG.Errorln("rollback baseline script failed:", path, err)
+			}
+		}
+		for _, name := range movedNames {
+			if err := fsys.Rename(filepath.Join(result.ArchiveRoot, name), filepath.Join(options.ScriptsInRoot, name)); err != nil {
+				zaplog.SUG.Errorln("rollback archived script failed:", name, err)
+			}
+		}
+		for _, path := range createdDirs {
+			if err := fsys.Remove(path); err != nil {
+				zaplog.SUG.Errorln("rollback archive DIR failed:", path, err)
+			}
+		}
+		return erero.Wro(cause)
+	}
+	for _, name := range result.ArchivedNames {
+		if err := fsys.Rename(filepath.Join(options.ScriptsInRoot, name), filepath.Join(result.ArchiveRoot, name)); err != nil {
+			return rollback(err)
+		}
+		movedNames = append(movedNames, name)
+	}
+	for _, script := range []struct{ name, content string }{
+		{result.ForwardName, result.ForwardScript},
+		{result.ReverseName, result.ReverseScript},
+	} {
+		path := filepath.Join(options.ScriptsInRoot, script.name)
+		writtenPaths = append(writtenPaths, path) // Remove partial content as well // 同时删除写入一半的内容
+		if err := fsys.WriteFile(path, []byte(script.content), 0644); err != nil {
+			return rollback(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package newscripts_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/rese"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSquashScripts(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(name string, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+	}
+	writeFile("00001_create_users.up.sql", "CREATE TABLE `users` (`id` integer PRIMARY KEY);\n")
+	writeFile("00001_create_users.down.sql", "DROP TABLE `users`;\n")
+	writeFile("00002_alter_users.up.sql", "ALTER TABLE `users` ADD `name` text;\n")
+	writeFile("00002_alter_users.down.sql", "ALTER TABLE `users` DROP COLUMN `name`;\n")
+	writeFile("00003_create_posts.up.sql", "CREATE TABLE `posts` (`id` integer PRIMARY KEY);\n")
+	writeFile("00003_create_posts.down.sql", "DROP TABLE `posts`;\n")
+
+	dsn := fmt.Sprintf("file:db-%s?mode=memory&cache=shared", uuid.New().String())
+	scratchDB := rese.P1(gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	}))
+	defer rese.F0(rese.P1(scratchDB.DB()).Close)
+
+	options := newscripts.NewOptions(root)
+
+	_, err := newscripts.SquashScripts(scratchDB, nil, 1, 2, options)
+	require.ErrorIs(t, err, newscripts.ErrSquashAhead)
+
+	result, err := newscripts.SquashScripts(scratchDB, nil, 3, 2, options)
+	require.NoError(t, err)
+	t.Log(result.ForwardScript)
+	require.Equal(t, "00002_baseline.up.sql", result.ForwardName)
+	require.Equal(t, "00002_baseline.down.sql", result.ReverseName)
+	require.Contains(t, result.ForwardScript, "`name` text")
+	require.Equal(t, "DROP TABLE `users`;\n", result.ReverseScript)
+	require.Len(t, result.ArchivedNames, 4)
+
+	require.NoError(t, result.Apply(options))
+	entries := rese.V1(os.ReadDir(root))
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.ElementsMatch(t, []string{
+		newscripts.ArchiveDirName,
+		"00002_baseline.up.sql",
+		"00002_baseline.down.sql",
+		"00003_create_posts.up.sql",
+		"00003_create_posts.down.sql",
+	}, names)
+	require.FileExists(t, filepath.Join(result.ArchiveRoot, "00001_create_users.up.sql"))
+}
+
+// failWriteFS fails writing the given path, to test rollback of Apply
+//
+// failWriteFS 写入指定路径时失败，用于测试 Apply 的回滚
+type failWriteFS struct {
+	*newscripts.MemFS
+	failPath string
+}
+
+func (f *failWriteFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if name == f.failPath {
+		return errors.New("disk full")
+	}
+	return f.MemFS.WriteFile(name, data, perm)
+}
+
+func TestSquashResult_Apply_Rollback(t *testing.T) {
+	root := t.TempDir()
+	memFS := newscripts.NewMemFS()
+	require.NoError(t, memFS.WriteFile(filepath.Join(root, "00001_create_users.up.sql"), []byte("CREATE TABLE `users` (`id` integer);\n"), 0644))
+	require.NoError(t, memFS.WriteFile(filepath.Join(root, "00001_create_users.down.sql"), []byte("DROP TABLE `users`;\n"), 0644))
+
+	options := newscripts.NewOptions(root)
+	options.ScriptsFS = &failWriteFS{MemFS: memFS, failPath: filepath.Join(root, "00001_baseline.down.sql")}
+
+	result := &newscripts.SquashResult{
+		ThroughVersion: 1,
+		ForwardName:    "00001_baseline.up.sql",
+		ReverseName:    "00001_baseline.down.sql",
+		ForwardScript:  "CREATE TABLE `users` (`id` integer);\n",
+		ReverseScript:  "DROP TABLE `users`;\n",
+		ArchivedNames:  []string{"00001_create_users.down.sql", "00001_create_users.up.sql"},
+		ArchiveRoot:    filepath.Join(root, newscripts.ArchiveDirName, "00001"),
+	}
+	require.Error(t, result.Apply(options))
+
+	entries, err := memFS.ReadDir(root)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.ElementsMatch(t, []string{
+		"00001_create_users.up.sql",
+		"00001_create_users.down.sql",
+	}, names)
+}
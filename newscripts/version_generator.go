@@ -0,0 +1,311 @@
+package newscripts
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/zaplog"
+)
+
+// VersionGenerator generates version text of the next migration script
+// Receives the database version and the migrations already in scripts DIR
+//
+// VersionGenerator 生成下一个迁移脚本的版本文本
+// 接收数据库版本以及脚本 DIR 中已有的迁移
+type VersionGenerator interface {
+	NewVersion(previousVersion uint, migrations *source.Migrations) string
+}
+
+// VersionGeneratorFunc adapts a function to VersionGenerator, without pattern matching
+//
+// VersionGeneratorFunc 将函数适配为 VersionGenerator
+type VersionGeneratorFunc func(previousVersion uint, migrations *source.Migrations) string
+
+// NewVersion calls the function itself
+//
+// NewVersion 调用函数本身
+func (fn VersionGeneratorFunc) NewVersion(previousVersion uint, migrations *source.Migrations) string {
+	return fn(previousVersion, migrations)
+}
+
+// VersionMatcher is optionally implemented by VersionGenerator to recognize its own version text
+// Used to infer the pattern already used in scripts DIR
+//
+// VersionMatcher 可由 VersionGenerator 选择实现，用于识别自身生成的版本文本
+// 用于推断脚本 DIR 中已使用的模式
+type VersionMatcher interface {
+	MatchVersion(versionText string) bool
+}
+
+// nextVersionGenerator generates auto-incrementing numbers zero-padded to width
+//
+// nextVersionGenerator 生成补零到指定宽度的自动递增编号
+type nextVersionGenerator struct {
+	width int
+}
+
+// NewNextVersionGenerator creates generator of auto-incrementing numbers zero-padded to width
+// Number continues from the highest of database version and existing script versions
+//
+// NewNextVersionGenerator 创建补零到指定宽度的自动递增编号生成器
+// 编号从数据库版本和已有脚本版本中的最高者继续递增
+func NewNextVersionGenerator(width int) VersionGenerator {
+	return &nextVersionGenerator{width: width}
+}
+
+func (g *nextVersionGenerator) NewVersion(previousVersion uint, migrations *source.Migrations) string {
+	highest := previousVersion
+	if versions := listVersions(migrations); len(versions) > 0 {
+		highest = max(highest, versions[len(versions)-1])
+	}
+	return fmt.Sprintf("%0*d", g.width, highest+1)
+}
+
+func (g *nextVersionGenerator) MatchVersion(versionText string) bool {
+	return len(versionText) == g.width && isDigits(versionText)
+}
+
+// unixVersionGenerator generates current Unix timestamp in seconds
+//
+// unixVersionGenerator 生成当前 Unix 时间戳（秒数）
+type unixVersionGenerator struct{}
+
+// NewUnixVersionGenerator creates generator of current Unix timestamp in seconds
+//
+// NewUnixVersionGenerator 创建当前 Unix 时间戳（秒数）生成器
+func NewUnixVersionGenerator() VersionGenerator {
+	return &unixVersionGenerator{}
+}
+
+func (g *unixVersionGenerator) NewVersion(previousVersion uint, migrations *source.Migrations) string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+// MatchVersion accepts 10-digit timestamps not in the future, which tells them apart from DATESEQ versions
+//
+// MatchVersion 接受不晚于当前时间的 10 位时间戳，以此与 DATESEQ 版本区分
+func (g *unixVersionGenerator) MatchVersion(versionText string) bool {
+	if len(versionText) != 10 || !isDigits(versionText) {
+		return false
+	}
+	num, err := strconv.ParseInt(versionText, 10, 64)
+	return err == nil && num <= time.Now().Unix()
+}
+
+// timeVersionGenerator generates formatted datetime, e.g., 20250621103045
+//
+// timeVersionGenerator 生成格式化日时，例如 20250621103045
+type timeVersionGenerator struct{}
+
+// NewTimeVersionGenerator creates generator of formatted datetime, e.g., 20250621103045
+//
+// NewTimeVersionGenerator 创建格式化日时生成器，例如 20250621103045
+func NewTimeVersionGenerator() VersionGenerator {
+	return &timeVersionGenerator{}
+}
+
+func (g *timeVersionGenerator) NewVersion(previousVersion uint, migrations *source.Migrations) string {
+	return time.Now().Format("20060102150405")
+}
+
+func (g *timeVersionGenerator) MatchVersion(versionText string) bool {
+	_, err := time.Parse("20060102150405", versionText)
+	return err == nil
+}
+
+// dateSeqVersionGenerator generates date plus two-digit sequence, e.g., 2025062101
+//
+// dateSeqVersionGenerator 生成日期加两位序号，例如 2025062101
+type dateSeqVersionGenerator struct{}
+
+// NewDateSeqVersionGenerator creates generator of date plus two-digit sequence, e.g., 2025062101
+// Sequence continues from the highest existing version of the same date
+//
+// NewDateSeqVersionGenerator 创建日期加两位序号的生成器，例如 2025062101
+// 序号从同一日期已有的最高版本继续递增
+func NewDateSeqVersionGenerator() VersionGenerator {
+	return &dateSeqVersionGenerator{}
+}
+
+func (g *dateSeqVersionGenerator) NewVersion(previousVersion uint, migrations *source.Migrations) string {
+	date := time.Now().Format("20060102")
+	dateNum, err := strconv.ParseUint(date, 10, 64)
+	if err != nil {
+		panic(erero.Wro(err))
+	}
+	var sequence uint
+	for _, version := range append(listVersions(migrations), previousVersion) {
+		if uint64(version/100) == dateNum {
+			sequence = max(sequence, version%100)
+		}
+	}
+	if sequence >= 99 {
+		panic(erero.Errorf("date sequence of %s is exhausted", date))
+	}
+	return fmt.Sprintf("%s%02d", date, sequence+1)
+}
+
+func (g *dateSeqVersionGenerator) MatchVersion(versionText string) bool {
+	if len(versionText) != 10 || !isDigits(versionText) {
+		return false
+	}
+	_, err := time.Parse("20060102", versionText[:8])
+	return err == nil
+}
+
+// isDigits reports whether text is non-empty and contains only ASCII digits
+//
+// isDigits 判断文本是否非空且只包含 ASCII 数字
+func isDigits(text string) bool {
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return text != ""
+}
+
+// listVersions returns versions of migrations in ascending sequence
+//
+// listVersions 按升序返回迁移的版本
+func listVersions(migrations *source.Migrations) []uint {
+	var versions []uint
+	version, ok := migrations.First()
+	for ok {
+		versions = append(versions, version)
+		version, ok = migrations.Next(version)
+	}
+	return versions
+}
+
+var (
+	versionGeneratorsMutex sync.RWMutex
+	versionGenerators      = map[VersionPattern]VersionGenerator{
+		VersionNext:    NewNextVersionGenerator(5),
+		VersionUnix:    NewUnixVersionGenerator(),
+		VersionTime:    NewTimeVersionGenerator(),
+		VersionDateSeq: NewDateSeqVersionGenerator(),
+	}
+)
+
+// RegisterVersionGenerator registers generator under the pattern name, replacing existing one
+// Names are case-insensitive, e.g., register NEXT8 with NewNextVersionGenerator(8)
+//
+// RegisterVersionGenerator 以模式名注册生成器，会替换已有的生成器
+// 名称不区分大小写，例如使用 NewNextVersionGenerator(8) 注册 NEXT8
+func RegisterVersionGenerator(name VersionPattern, generator VersionGenerator) {
+	versionGeneratorsMutex.Lock()
+	defer versionGeneratorsMutex.Unlock()
+
+	versionGenerators[VersionPattern(strings.ToUpper(string(name)))] = generator
+}
+
+// UnregisterVersionGenerator removes generator registered under the pattern name
+//
+// UnregisterVersionGenerator 移除以模式名注册的生成器
+func UnregisterVersionGenerator(name VersionPattern) {
+	versionGeneratorsMutex.Lock()
+	defer versionGeneratorsMutex.Unlock()
+
+	delete(versionGenerators, VersionPattern(strings.ToUpper(string(name))))
+}
+
+// LookupVersionGenerator finds registered generator by pattern name
+//
+// LookupVersionGenerator 按模式名查找已注册的生成器
+func LookupVersionGenerator(name VersionPattern) (VersionGenerator, bool) {
+	versionGeneratorsMutex.RLock()
+	defer versionGeneratorsMutex.RUnlock()
+
+	generator, ok := versionGenerators[VersionPattern(strings.ToUpper(string(name)))]
+	return generator, ok
+}
+
+// VersionGeneratorNames returns registered pattern names in sorted sequence
+//
+// VersionGeneratorNames 按排序返回已注册的模式名
+func VersionGeneratorNames() []string {
+	versionGeneratorsMutex.RLock()
+	defer versionGeneratorsMutex.RUnlock()
+
+	names := make([]string, 0, len(versionGenerators))
+	for name := range versionGenerators {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InferVersionPatterns returns registered patterns whose generator matches every script version in migrations
+// Generators without VersionMatcher are never inferred, returns nothing when migrations are empty
+//
+// InferVersionPatterns 返回其生成器匹配迁移中每个脚本版本的已注册模式
+// 未实现 VersionMatcher 的生成器不会被推断，迁移为空时不返回任何内容
+func InferVersionPatterns(migrations *source.Migrations) []VersionPattern {
+	var versionTexts []string
+	for _, version := range listVersions(migrations) {
+		migration, ok := migrations.Up(version)
+		if !ok {
+			migration, _ = migrations.Down(version)
+		}
+		versionText, _, _ := strings.Cut(migration.Raw, "_")
+		versionTexts = append(versionTexts, versionText)
+	}
+	if len(versionTexts) == 0 {
+		return nil
+	}
+
+	var results []VersionPattern
+	for _, name := range VersionGeneratorNames() {
+		generator, _ := LookupVersionGenerator(VersionPattern(name))
+		matcher, ok := generator.(VersionMatcher)
+		if !ok {
+			continue
+		}
+		if !slices.ContainsFunc(versionTexts, func(versionText string) bool { return !matcher.MatchVersion(versionText) }) {
+			results = append(results, VersionPattern(name))
+		}
+	}
+	return results
+}
+
+// resolveVersionType checks requested pattern against the pattern inferred from scripts DIR
+// Empty pattern means the caller did not pick one, it takes the inferred pattern, NEXT when scripts DIR is empty
+// Switches to the inferred pattern when auto-select is enabled, otherwise refuses conflicting pattern with ErrUsage
+//
+// resolveVersionType 根据从脚本 DIR 推断的模式检查请求的模式
+// 空模式表示调用方未指定，此时使用推断的模式，脚本 DIR 为空时使用 NEXT
+// 启用自动选择时切换到推断的模式，否则以 ErrUsage 拒绝冲突的模式
+func resolveVersionType(versionType VersionPattern, migrations *source.Migrations, options *Options) (VersionPattern, error) {
+	inferred := InferVersionPatterns(migrations)
+	if versionType == "" {
+		if len(inferred) > 0 {
+			return inferred[0], nil
+		}
+		return VersionNext, nil
+	}
+	versionType, err := parseVersionType(string(versionType))
+	if err != nil {
+		return "", err
+	}
+	generator, _ := LookupVersionGenerator(versionType)
+	if _, ok := generator.(VersionMatcher); !ok {
+		return versionType, nil // Custom generator without matcher can not be checked // 没有匹配器的自定义生成器无法检查
+	}
+	if len(inferred) == 0 || slices.Contains(inferred, versionType) {
+		return versionType, nil
+	}
+	if options.AutoVersionType {
+		zaplog.SUG.Warnln("version-type", versionType, "conflicts with scripts DIR, auto select", inferred[0])
+		return inferred[0], nil
+	}
+	return "", erero.Wrapf(migrationerrors.ErrUsage, "version-type %s conflicts with existing scripts using %v", versionType, inferred)
+}
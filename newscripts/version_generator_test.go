@@ -0,0 +1,91 @@
+package newscripts_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-xlan/go-migrate/migrationerrors"
+	"github.com/go-xlan/go-migrate/newscripts"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMigrations(t *testing.T, names ...string) *source.Migrations {
+	migrations := source.NewMigrations()
+	for _, name := range names {
+		migration, err := source.DefaultParse(name)
+		require.NoError(t, err)
+		require.True(t, migrations.Append(migration))
+	}
+	return migrations
+}
+
+func TestNewNextVersionGenerator(t *testing.T) {
+	migrations := newTestMigrations(t, "00001_script.up.sql")
+	require.Equal(t, "00002", newscripts.NewNextVersionGenerator(5).NewVersion(1, migrations))
+	require.Equal(t, "00000002", newscripts.NewNextVersionGenerator(8).NewVersion(1, migrations))
+}
+
+func TestNewDateSeqVersionGenerator(t *testing.T) {
+	date := time.Now().Format("20060102")
+	generator := newscripts.NewDateSeqVersionGenerator()
+	require.Equal(t, date+"01", generator.NewVersion(0, newTestMigrations(t)))
+
+	migrations := newTestMigrations(t,
+		"2001010105_old.up.sql",
+		fmt.Sprintf("%s01_a.up.sql", date),
+		fmt.Sprintf("%s02_b.up.sql", date),
+	)
+	require.Equal(t, date+"03", generator.NewVersion(0, migrations))
+}
+
+func TestRegisterVersionGenerator(t *testing.T) {
+	newscripts.RegisterVersionGenerator("next8", newscripts.NewNextVersionGenerator(8))
+	t.Cleanup(func() {
+		newscripts.UnregisterVersionGenerator("NEXT8")
+	})
+	require.Contains(t, newscripts.VersionGeneratorNames(), "NEXT8")
+
+	naming := &newscripts.ScriptNaming{
+		VersionType: "NEXT8",
+		Description: "script",
+	}
+	prefix, err := naming.NewScriptPrefixAfter(3, newTestMigrations(t))
+	require.NoError(t, err)
+	require.Equal(t, "00000004_script", prefix)
+	require.Equal(t, "00000004_script", naming.NewScriptPrefix(4))
+}
+
+func TestScriptNaming_NewScriptPrefixAfter_Unknown(t *testing.T) {
+	naming := &newscripts.ScriptNaming{
+		VersionType: "NEXT9",
+		Description: "script",
+	}
+	_, err := naming.NewScriptPrefixAfter(3, newTestMigrations(t))
+	require.ErrorIs(t, err, migrationerrors.ErrUsage)
+}
+
+func TestNewNextVersionGenerator_HighestScript(t *testing.T) {
+	migrations := newTestMigrations(t, "00001_a.up.sql", "00002_b.up.sql", "00005_c.up.sql")
+	require.Equal(t, "00006", newscripts.NewNextVersionGenerator(5).NewVersion(2, migrations))
+}
+
+func TestInferVersionPatterns(t *testing.T) {
+	require.Empty(t, newscripts.InferVersionPatterns(newTestMigrations(t)))
+
+	require.Equal(t, []newscripts.VersionPattern{newscripts.VersionNext},
+		newscripts.InferVersionPatterns(newTestMigrations(t, "00001_a.up.sql", "00002_b.up.sql")))
+
+	require.Equal(t, []newscripts.VersionPattern{newscripts.VersionTime},
+		newscripts.InferVersionPatterns(newTestMigrations(t, "20250908101010_a.up.sql", "20250909111111_b.down.sql")))
+
+	require.Equal(t, []newscripts.VersionPattern{newscripts.VersionDateSeq},
+		newscripts.InferVersionPatterns(newTestMigrations(t, "2025090801_a.up.sql", "2025090802_b.up.sql")))
+
+	require.Equal(t, []newscripts.VersionPattern{newscripts.VersionUnix},
+		newscripts.InferVersionPatterns(newTestMigrations(t, "1678693920_a.up.sql")))
+
+	// Mixed patterns match nothing // 混合模式不匹配任何模式
+	require.Empty(t, newscripts.InferVersionPatterns(newTestMigrations(t, "00007_a.up.sql", "20250908101010_b.up.sql")))
+}
@@ -10,19 +10,21 @@
 package previewmigrate
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/go-xlan/go-migrate/internal/outputs"
 	"github.com/go-xlan/go-migrate/internal/utils"
+	"github.com/go-xlan/go-migrate/migrationerrors"
 	"github.com/go-xlan/go-migrate/migrationparam"
 	"github.com/go-xlan/go-migrate/newscripts"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/yyle88/erero"
 	"github.com/yyle88/eroticgo"
-	"github.com/yyle88/osexistpath/osmustexist"
-	"github.com/yyle88/rese"
 	"github.com/yyle88/zaplog"
 	"gorm.io/gorm"
 )
@@ -42,10 +44,20 @@ func NewPreviewCmd(param *migrationparam.MigrationParam, scriptsPath string) *co
 		Args:  cobra.NoArgs,
 	}
 
+	outputs.AddFlag(rootCmd)
 	rootCmd.AddCommand(newPreviewIncCmd(param, scriptsPath))
 	return rootCmd
 }
 
+// PreviewResult contains outcome of previewing next script, written as JSON object of preview commands
+//
+// PreviewResult 包含预览下一个脚本的结果，作为 preview 命令的 JSON 对象输出
+type PreviewResult struct {
+	ScriptName string // Previewed up script name, empty when not found // 预览的升级脚本名称，未找到时为空
+	Success    bool   // Whether SQL ran without error before rollback // 回滚前 SQL 是否执行成功
+	Error      string // Preview error message // 预览错误信息
+}
+
 // newPreviewIncCmd creates command for previewing next migration step
 // Tests next migration SQL in transaction without applying changes to database
 //
@@ -53,22 +65,29 @@ func NewPreviewCmd(param *migrationparam.MigrationParam, scriptsPath string) *co
 // 在事务中测试下一个迁移 SQL 而不对数据库应用更改
 func newPreviewIncCmd(param *migrationparam.MigrationParam, scriptsPath string) *cobra.Command {
 	return &cobra.Command{
-		Use:   "inc",
-		Short: "Preview next migration step (+1)",
-		Long:  "Test next migration SQL without applying changes",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			migration, cleanup := param.GetMigration()
-			defer cleanup()
-
-			db, cleanup2 := param.GetDB()
-			defer cleanup2()
-			err := previewNextMigration(migration, db, scriptsPath)
-			if err != nil {
-				zaplog.SUG.Debugln(eroticgo.RED.Sprint("PREVIEW FAILED:"))
-				zaplog.SUG.Errorln(err)
-				return
-			}
+		Use:          "inc",
+		Short:        "Preview next migration step (+1)",
+		Long:         "Test next migration SQL without applying changes",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				migration, cleanup := param.GetMigration()
+				defer cleanup()
+
+				db, cleanup2 := param.GetDB()
+				defer cleanup2()
+				result := &PreviewResult{Success: true}
+				scriptName, err := previewNextMigration(migration, db, scriptsPath)
+				result.ScriptName = scriptName
+				if err != nil {
+					zaplog.SUG.Debugln(eroticgo.RED.Sprint("PREVIEW FAILED:"))
+					zaplog.SUG.Errorln(err)
+					result.Success = false
+					result.Error = err.Error()
+				}
+				return result, err
+			})
 		},
 	}
 }
@@ -80,27 +99,40 @@ func newPreviewIncCmd(param *migrationparam.MigrationParam, scriptsPath string)
 // previewNextMigration 预览下一个迁移而不应用它
 // 使用现有的 GetNewScriptInfo 找到下一个脚本并在回滚事务中测试执行
 // 提供关于 SQL 有效性和执行安全性的全面反馈
-func previewNextMigration(migration *migrate.Migrate, db *gorm.DB, scriptsPath string) error {
+// Returns the previewed up script name
+// 返回预览的升级脚本名称
+func previewNextMigration(migration *migrate.Migrate, db *gorm.DB, scriptsPath string) (string, error) {
 	// 1. Get current version
 	currentVersion, dirtyFlag, err := migration.Version()
-	utils.WhistleCause(err) // panic when cause is not expected
+	if err := utils.WhistleCauseE(err); err != nil {
+		return "", err
+	}
 	if dirtyFlag {
-		return erero.Errorf("DATABASE IS DIRTY AT VERSION %d", currentVersion)
+		return "", erero.Wrapf(migrationerrors.ErrDirty, "DATABASE IS DIRTY AT VERSION %d", currentVersion)
 	}
 
 	// 2. Use existing GetNewScriptInfo to find next script
 	options := newscripts.NewOptions(scriptsPath)
 	scriptNaming := newscripts.NewScriptNaming()
-	scriptInfo := newscripts.GetNewScriptInfo(migration, options, scriptNaming)
+	scriptInfo, err := newscripts.GetNewScriptInfo(migration, options, scriptNaming)
+	if err != nil {
+		return "", err
+	}
 
 	scriptNames := scriptInfo.GetScriptNames()
 
 	// Read the up script content
-	forwardScriptPath := osmustexist.FILE(filepath.Join(scriptsPath, scriptNames.ForwardName))
-	sqlContent := rese.V1(os.ReadFile(forwardScriptPath))
+	forwardScriptPath := filepath.Join(scriptsPath, scriptNames.ForwardName)
+	sqlContent, err := os.ReadFile(forwardScriptPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", erero.Wrapf(migrationerrors.ErrNoScripts, "NO NEXT SCRIPT %s", scriptNames.ForwardName)
+		}
+		return "", erero.Wro(err)
+	}
 	if len(strings.TrimSpace(string(sqlContent))) == 0 {
 		zaplog.SUG.Infoln(eroticgo.BLUE.Sprint("EMPTY MIGRATION FILE - PREVIEW SUCCESS"))
-		return nil
+		return scriptNames.ForwardName, nil
 	}
 
 	zaplog.SUG.Infof("PREVIEWING MIGRATION SCRIPT: %s", scriptNames.ForwardName)
@@ -108,7 +140,7 @@ func previewNextMigration(migration *migrate.Migrate, db *gorm.DB, scriptsPath s
 	// 3. Preview in transaction (always rollback)
 	tx := db.Begin()
 	if tx.Error != nil {
-		return erero.Errorf("FAILED TO BEGIN TRANSACTION: %v", tx.Error)
+		return scriptNames.ForwardName, erero.Errorf("FAILED TO BEGIN TRANSACTION: %v", tx.Error)
 	}
 
 	// Execute and always rollback
@@ -118,9 +150,9 @@ func previewNextMigration(migration *migrate.Migrate, db *gorm.DB, scriptsPath s
 	if err != nil {
 		zaplog.SUG.Debugln(eroticgo.RED.Sprint("PREVIEW FAILED - SQL EXEC ISSUE:"))
 		zaplog.SUG.Errorln(err)
-		return erero.Errorf("PREVIEW FAILED: %v", err)
+		return scriptNames.ForwardName, erero.Wrapf(migrationerrors.ErrPreviewFailed, "SQL EXEC ISSUE: %v", err)
 	}
 
 	zaplog.SUG.Infoln(eroticgo.GREEN.Sprint("PREVIEW SUCCESS"))
-	return nil
+	return scriptNames.ForwardName, nil
 }
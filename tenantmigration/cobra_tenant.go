@@ -0,0 +1,85 @@
+package tenantmigration
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-xlan/go-migrate/internal/outputs"
+	"github.com/spf13/cobra"
+	"github.com/yyle88/eroticgo"
+)
+
+// NewTenantCmd creates command tree running all and status across tenant schemas
+// Flags override parallelism and continue-on-error of config
+//
+// NewTenantCmd 创建在所有租户 schema 上运行 all 和 status 的命令树
+// 参数可覆盖配置中的并发数和出错继续设置
+func NewTenantCmd(config *Config) *cobra.Command {
+	// Create root command
+	var rootCmd = &cobra.Command{
+		Use:   "tenant",
+		Short: "Schema-per-tenant migration",
+		Long:  "Schema-per-tenant migration",
+		Args:  cobra.NoArgs,
+	}
+	outputs.AddFlag(rootCmd)
+	rootCmd.PersistentFlags().IntVar(&config.Parallelism, "parallelism", max(1, config.Parallelism), "max count of tenants running at once")
+	rootCmd.PersistentFlags().BoolVar(&config.ContinueOnError, "continue-on-error", config.ContinueOnError, "keep running other tenants after a failure")
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:          "all",
+		Short:        "Run all migration files of each tenant",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				results, err := MigrateTenants(config)
+				if results == nil {
+					return nil, err
+				}
+				ShowResults(writer, results)
+				return &TenantsResult{Tenants: results}, err
+			})
+		},
+	})
+	rootCmd.AddCommand(&cobra.Command{
+		Use:          "status",
+		Short:        "Show migration status of each tenant",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outputs.RunE(cmd, func(writer io.Writer) (any, error) {
+				results, err := GetTenantStatus(config)
+				if results == nil {
+					return nil, err
+				}
+				ShowResults(writer, results)
+				return &TenantsResult{Tenants: results}, err
+			})
+		},
+	})
+	return rootCmd
+}
+
+// ShowResults writes one line each tenant into writer with version, dirty flag, pending count and error
+//
+// ShowResults 为每个租户向 writer 写入一行，包含版本、脏标志、待执行数量和错误
+func ShowResults(writer io.Writer, results []*TenantResult) {
+	outputs.ShowMessage(writer, eroticgo.CYAN, "=== Tenant Results ===")
+	for _, result := range results {
+		line := fmt.Sprintf("%-24s version=%-16d dirty=%-5t duration=%s", result.Schema, result.Version, result.Dirty, result.Duration)
+		if result.Status != nil {
+			line += fmt.Sprintf(" pending=%d schema-diff=%d", result.Status.PendingCount, result.Status.SchemaDiffCount)
+		}
+		switch {
+		case result.Err != nil:
+			outputs.ShowMessage(writer, eroticgo.RED, line, "error:", result.Err)
+		case result.Dirty:
+			outputs.ShowMessage(writer, eroticgo.RED, line)
+		case result.Status != nil && !result.Status.HasUpToDate:
+			outputs.ShowMessage(writer, eroticgo.AMBER, line)
+		default:
+			outputs.ShowMessage(writer, eroticgo.GREEN, line)
+		}
+	}
+}
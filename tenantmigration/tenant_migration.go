@@ -0,0 +1,177 @@
+// Package tenantmigration: Schema-per-tenant migration fan-out
+// Enumerates tenant schemas through a callback and migrates each schema with its own migrate instance
+// Runs with bounded concurrency and reports results of each tenant, continuing past failures when asked
+//
+// tenantmigration: 每租户一个 schema 的迁移分发
+// 通过回调枚举租户 schema，并使用各自的迁移实例迁移每个 schema
+// 以有界并发运行并报告每个租户的结果，按需在失败后继续执行
+package tenantmigration
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-xlan/go-migrate/cobramigration"
+	"github.com/go-xlan/go-migrate/internal/fanout"
+	"github.com/go-xlan/go-migrate/migrationparam"
+	"github.com/go-xlan/go-migrate/migrationstate"
+	"github.com/go-xlan/go-migrate/newmigrate"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/pkg/errors"
+	"github.com/yyle88/erero"
+	"github.com/yyle88/rese"
+	"gorm.io/gorm"
+)
+
+// Config contains tenant enumeration and per-tenant connection factories
+//
+// Config 包含租户枚举和每个租户的连接工厂
+type Config struct {
+	ListSchemas     func() ([]string, error)                           // Enumerates tenant schemas // 枚举租户 schema
+	NewParam        func(schema string) *migrationparam.MigrationParam // Creates migration connection of schema // 创建 schema 的迁移连接
+	ScriptsInRoot   string                                             // Path to migration scripts DIR shared by tenants // 租户共用的迁移脚本 DIR 路径
+	Objects         []any                                              // GORM model objects used in schema comparison // 用于结构比较的 GORM 模型对象
+	Parallelism     int                                                // Max count of tenants running at once // 同时运行的最大租户数
+	ContinueOnError bool                                               // Keep running other tenants after a failure // 失败后继续运行其他租户
+}
+
+// TenantResult contains outcome of running one tenant
+//
+// TenantResult 包含单个租户的运行结果
+type TenantResult struct {
+	Schema   string                 // Tenant schema name // 租户 schema 名称
+	Version  uint                   // Database version after running // 运行后的数据库版本
+	Dirty    bool                   // Database dirty flag after running // 运行后的数据库脏标志
+	Status   *migrationstate.Status // Migration status, only set by status runs // 迁移状态，仅在状态运行时设置
+	Err      error                  `json:"-"` // Error of this tenant // 本租户的错误
+	Error    string                 // Message of Err in JSON output // JSON 输出中 Err 的信息
+	Duration time.Duration          // Time this tenant took, in nanoseconds in JSON output // 本租户的耗时，JSON 输出中以纳秒表示
+}
+
+// TenantsResult contains results of all tenants, written as JSON object of tenant commands
+//
+// TenantsResult 包含所有租户的结果，作为 tenant 命令的 JSON 对象输出
+type TenantsResult struct {
+	Tenants []*TenantResult // Results in schema sequence // 按 schema 顺序排列的结果
+}
+
+// SearchPathDSN sets search_path of Postgres DSN, supporting both URL and key=value formats
+//
+// SearchPathDSN 设置 Postgres DSN 的 search_path，支持 URL 和 key=value 两种格式
+func SearchPathDSN(dsn string, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		dsnURL, err := url.Parse(dsn)
+		if err == nil {
+			query := dsnURL.Query()
+			query.Set("search_path", schema)
+			dsnURL.RawQuery = query.Encode()
+			return dsnURL.String()
+		}
+	}
+	return strings.TrimSpace(dsn) + " search_path=" + schema
+}
+
+// NewPostgresParamFactory creates NewParam callback building Postgres connections of each schema
+// Connection uses search_path of the schema and migrate instance uses it as SchemaName
+//
+// NewPostgresParamFactory 创建为每个 schema 构建 Postgres 连接的 NewParam 回调
+// 连接使用该 schema 的 search_path，迁移实例将其用作 SchemaName
+func NewPostgresParamFactory(newDB func(dsn string) *gorm.DB, dsn string, scriptsInRoot string) func(schema string) *migrationparam.MigrationParam {
+	return func(schema string) *migrationparam.MigrationParam {
+		return migrationparam.NewMigrationParam(
+			func() *gorm.DB {
+				return newDB(SearchPathDSN(dsn, schema))
+			},
+			func(db *gorm.DB) *migrate.Migrate {
+				migrationDriver := rese.V1(postgres.WithInstance(rese.P1(db.DB()), &postgres.Config{
+					SchemaName: schema,
+				}))
+				return rese.P1(newmigrate.NewWithScriptsAndDatabase(&newmigrate.ScriptsAndDatabaseParam{
+					ScriptsInRoot:    scriptsInRoot,
+					DatabaseName:     "postgres",
+					DatabaseInstance: migrationDriver,
+				}))
+			},
+		)
+	}
+}
+
+// MigrateTenants runs all pending migrations of each tenant schema
+// Returns results of every tenant, with error wrapping the first failure when any tenant failed
+//
+// MigrateTenants 执行每个租户 schema 的所有待处理迁移
+// 返回每个租户的结果，任一租户失败时返回包装第一个失败的错误
+func MigrateTenants(config *Config) ([]*TenantResult, error) {
+	return runTenants(config, func(schema string, result *TenantResult) error {
+		param := config.NewParam(schema)
+		if err := cobramigration.MigrateAll(&cobramigration.Config{
+			Param:         param,
+			ScriptsInRoot: config.ScriptsInRoot,
+		}); err != nil {
+			return err
+		}
+
+		migration, cleanup := param.GetMigration()
+		defer cleanup()
+		version, dirtyFlag, err := migration.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return erero.Wro(err)
+		}
+		result.Version, result.Dirty = version, dirtyFlag
+		return nil
+	})
+}
+
+// GetTenantStatus collects migration status of each tenant schema
+// Returns results of every tenant, with error wrapping the first failure when any tenant failed
+//
+// GetTenantStatus 收集每个租户 schema 的迁移状态
+// 返回每个租户的结果，任一租户失败时返回包装第一个失败的错误
+func GetTenantStatus(config *Config) ([]*TenantResult, error) {
+	return runTenants(config, func(schema string, result *TenantResult) error {
+		param := config.NewParam(schema)
+		migration, cleanup := param.GetMigration()
+		defer cleanup()
+		db, _ := param.GetDB()
+
+		status, err := migrationstate.GetStatus(db, migration, config.ScriptsInRoot, config.Objects)
+		if err != nil {
+			return erero.Wro(err)
+		}
+		result.Status = status
+		result.Version, result.Dirty = status.DatabaseVersion, status.IsDirtyFlag
+		return nil
+	})
+}
+
+// runTenants enumerates schemas and runs task on each of them through fanout
+// Results are nil when schemas can not be listed
+//
+// runTenants 枚举 schema 并通过 fanout 在每个 schema 上运行任务
+// 无法列出 schema 时结果为 nil
+func runTenants(config *Config, task func(schema string, result *TenantResult) error) ([]*TenantResult, error) {
+	schemas, err := config.ListSchemas()
+	if err != nil {
+		return nil, erero.Wro(err)
+	}
+	tenantResults := make([]*TenantResult, len(schemas))
+	for idx, schema := range schemas {
+		tenantResults[idx] = &TenantResult{Schema: schema}
+	}
+	results := fanout.Run(tenantResults, &fanout.Options{
+		Parallelism: config.Parallelism,
+		StopOnError: !config.ContinueOnError,
+	}, func(result *TenantResult) error {
+		return task(result.Schema, result)
+	})
+	for _, result := range results {
+		result.Item.Err = result.Err
+		if result.Err != nil {
+			result.Item.Error = result.Err.Error()
+		}
+		result.Item.Duration = result.Duration
+	}
+	return tenantResults, fanout.CheckFailures(results, "tenants")
+}